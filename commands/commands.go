@@ -31,5 +31,76 @@ var (
 					Usage: "user login token",
 				},
 			},
+		},
+		{
+			Name:        "schedule-preview",
+			Description: "preview the next run times of a cron expression or a schedule, and the thread the schedule would post",
+			Action:      PreviewSchedule,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "cron",
+					Usage: "the cron expression",
+				},
+				cli.Int64Flag{
+					Name:  "schedule-id",
+					Usage: "the schedule id",
+				},
+				cli.StringFlag{
+					Name:  "display-tz",
+					Usage: "the time zone to show the run times in, e.g. Asia/Tokyo, the cron expression is evaluated in the service time zone",
+				},
+				cli.IntFlag{
+					Name:  "limit",
+					Usage: "the number of run times",
+					Value: 10,
+				},
+			},
+		},
+		{
+			Name:        "tweetlib-import",
+			Description: "import the threads of tweet urls, of the recent tweets of accounts or of a csv file into the tweet lib",
+			Action:      ImportTweetLib,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "category",
+					Usage: "the category of the imported tweets",
+				},
+				cli.StringSliceFlag{
+					Name:  "url",
+					Usage: "a tweet url, repeatable",
+				},
+				cli.StringSliceFlag{
+					Name:  "account",
+					Usage: "an account whose recent original tweets are imported, repeatable",
+				},
+				cli.StringFlag{
+					Name:  "csv",
+					Usage: "the path of a csv file of rows \"tweet_url[,category]\"",
+				},
+				cli.IntFlag{
+					Name:  "max-per-account",
+					Usage: "the tweets imported per account at most",
+					Value: 20,
+				},
+				cli.StringFlag{
+					Name:  "user-id",
+					Usage: "the account whose token is used, the app token if empty",
+				},
+			},
+		},
+		{
+			Name:        "tweet-delete",
+			Description: "delete a tweet posted by miko or all the tweets of a posted thread",
+			Action:      DeletePostedTweet,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "tweet-id",
+					Usage: "the id of the posted tweet to delete",
+				},
+				cli.StringFlag{
+					Name:  "thread-id",
+					Usage: "the id of the first tweet of the posted thread to delete",
+				},
+			},
 		}}
 )
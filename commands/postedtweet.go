@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/tools/log"
+	"github.com/urfave/cli"
+)
+
+func DeletePostedTweet(c *cli.Context) {
+	tweetId := c.String("tweet-id")
+	threadId := c.String("thread-id")
+	if (len(tweetId) == 0) == (len(threadId) == 0) {
+		log.Error("", "one of tweet-id and thread-id is required")
+		return
+	}
+
+	var resp interface{}
+	var err error
+	if len(threadId) > 0 {
+		resp, err = core.DeletePostedThread(threadId)
+	} else {
+		resp, err = core.DeletePostedTweet(tweetId)
+	}
+	if err != nil {
+		log.Error("", "delete posted tweet error %s", err.Error())
+		return
+	}
+
+	b, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		log.Error("", "json.MarshalIndent() error %s", err.Error())
+		return
+	}
+
+	fmt.Println(string(b))
+}
@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+	"github.com/urfave/cli"
+)
+
+func PreviewSchedule(c *cli.Context) {
+	req := &data.TwPreviewScheduleReq{
+		CronExp:         c.String("cron"),
+		ScheduleId:      c.Int64("schedule-id"),
+		DisplayTimeZone: c.String("display-tz"),
+		Limit:           c.Int("limit"),
+	}
+	if len(req.CronExp) == 0 && req.ScheduleId <= 0 {
+		log.Error("", "cron or schedule-id is required")
+		return
+	}
+
+	resp, err := core.PreviewSchedule(req)
+	if err != nil {
+		log.Error("", "core.PreviewSchedule() error %s", err.Error())
+		return
+	}
+
+	b, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		log.Error("", "json.MarshalIndent() error %s", err.Error())
+		return
+	}
+
+	fmt.Println(string(b))
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+	"github.com/urfave/cli"
+)
+
+func ImportTweetLib(c *cli.Context) {
+	req := &data.TweetLibImportReq{
+		Category:      c.String("category"),
+		TweetUrls:     c.StringSlice("url"),
+		Accounts:      c.StringSlice("account"),
+		MaxPerAccount: c.Int("max-per-account"),
+		UserId:        c.String("user-id"),
+	}
+	if len(req.Category) == 0 {
+		log.Error("", "category is required")
+		return
+	}
+
+	if csvPath := c.String("csv"); len(csvPath) > 0 {
+		b, err := os.ReadFile(csvPath)
+		if err != nil {
+			log.Error("", "os.ReadFile() error %s", err.Error())
+			return
+		}
+		req.Csv = string(b)
+	}
+	if len(req.TweetUrls) == 0 && len(req.Accounts) == 0 && len(req.Csv) == 0 {
+		log.Error("", "url, account or csv is required")
+		return
+	}
+
+	resp, err := core.ImportTweetLib(req)
+	if err != nil {
+		log.Error("", "core.ImportTweetLib() error %s", err.Error())
+		return
+	}
+
+	b, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		log.Error("", "json.MarshalIndent() error %s", err.Error())
+		return
+	}
+
+	fmt.Println(string(b))
+}
@@ -56,25 +56,44 @@ func ParseConfigINI(cpath string) (err error) {
 	// if TwitterAPIToken == "" {
 	// 	return fmt.Errorf("twitter api_token is not config")
 	// }
-	// BaseScope = GetConfigString("twitter", "base_scope")
-	// if BaseScope == "" {
-	// 	return fmt.Errorf("twitter base_scope is not config")
-	// }
+	BaseScope = GetConfigString("twitter", "base_scope")
+	if BaseScope == "" {
+		return fmt.Errorf("twitter base_scope is not config")
+	}
 
 	// LLMSavePath = GetConfigString("llm", "save_path")
 	// if len(LLMSavePath) == 0 {
 	// 	panic("config error")
 	// }
 
-	// TwMetricRefreshInterval, err = GetConfigInt("twitter", "tw_metric_refresh_interval")
-	// if err != nil {
-	// 	panic("config error")
-	// }
+	if interval, e := GetConfigInt("twitter", "tw_metric_refresh_interval"); e == nil && interval > 0 {
+		TwMetricRefreshInterval = interval
+	}
 
-	// TwitterOAuth2JumpFrontUrl = GetConfigString("twitter", "jump_front_url")
-	// if len(TwitterOAuth2JumpFrontUrl) == 0 {
-	// 	panic("config error")
-	// }
+	if limit, e := GetConfigInt("twitter", "user_24hour_post_limit"); e == nil && limit > 0 {
+		TwUser24HourPostLimit = int(limit)
+	}
+	if limit, e := GetConfigInt("twitter", "app_monthly_post_limit"); e == nil && limit > 0 {
+		TwAppMonthlyPostLimit = int(limit)
+	}
+	if window, e := GetConfigInt("twitter", "post_undo_window"); e == nil && window > 0 {
+		TwPostUndoWindow = window
+	}
+	if staleTime, e := GetConfigInt("twitter", "thread_post_stale_time"); e == nil && staleTime > 0 {
+		TwThreadPostStaleTime = staleTime
+	}
+	if interval, e := GetConfigInt("twitter", "follower_snapshot_interval"); e == nil && interval > 0 {
+		TwFollowerSnapshotInterval = interval
+	}
+	if count, e := GetConfigInt("twitter", "notable_follower_count"); e == nil && count > 0 {
+		TwNotableFollowerCount = int(count)
+	}
+
+	// optional, the auth callback page stays if it is not configured
+	TwitterOAuth2JumpFrontUrl = GetConfigString("twitter", "jump_front_url")
+
+	// optional, the threads are fetched by the api only if it is not configured
+	RSSHubHost = GetConfigString("rsshub", "host")
 
 	return nil
 }
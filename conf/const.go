@@ -22,10 +22,25 @@ var (
 
 	LLMSavePath = ""
 
-	TwMetricRefreshInterval int64 = 0
+	TwMetricRefreshInterval int64 = 300 // unit: second
+
+	// the post caps of the api tier, see the [twitter] section of the config
+	TwUser24HourPostLimit = 100 // used until a response reports the cap of the user
+	TwAppMonthlyPostLimit = 0   // 0 means the monthly posts are counted but not capped
+
+	// a new thread is held this long before it goes out so it can be undone, 0 means no hold
+	TwPostUndoWindow int64 = 0 // unit: second
+	// a thread post still posting but not updated this long is taken as stopped by a crash, it can be resumed
+	TwThreadPostStaleTime int64 = 1800 // unit: second
+
+	TwFollowerSnapshotInterval int64 = 86400 // unit: second
+	// a new follower with at least this many followers is notable
+	TwNotableFollowerCount = 10000
 
 	TimeZone       = time.FixedZone("UTC", 0)
 	NewTimeZone, _ = time.LoadLocation("Greenwich")
 
 	TwitterOAuth2JumpFrontUrl = ""
+
+	RSSHubHost = ""
 )
@@ -3,8 +3,20 @@ package conf
 // redis keys
 
 const (
-	AISERAppRateLimitCreateTweet = "aiser_app_rate_limit_create_tweet_%s"
-	AISERTwMetricInfoString      = "aiser_tw_metric_info_string"
+	AISERTwRateLimit          = "aiser_tw_rate_limit"           // hash, the rate limits of the twitter endpoints, field: owner|endpoint
+	AISERTwAppMonthlyPosts    = "aiser_tw_app_monthly_posts_%s" // the posts of the app in a month, yyyymm
+	AISERTwMetricInfoString   = "aiser_tw_metric_info_string"
+	AISEREmergencyStopPosting = "aiser_emergency_stop_posting"
+	AISERCrondLock            = "aiser_crond_lock_%s"
+	AISERTwOAuth2State        = "aiser_tw_oauth2_state_%s"
+	AISERTwOAuth1RequestToken = "aiser_tw_oauth1_request_token_%s"
+	AISERTwScheduleFireLock   = "aiser_tw_schedule_fire_lock_%s" // the fire of a schedule, job tag
+	AISERTwTokenRefreshLock   = "aiser_tw_token_refresh_lock_%s" // the token refresh of an account, user id
+
+	AISERTaskQueue      = "aiser_task_queue_%s"       // list, the ready tasks of a task type
+	AISERTaskDeadLetter = "aiser_task_dead_letter_%s" // list, the tasks of a task type out of attempts
+	AISERTaskProcessing = "aiser_task_processing"     // zset, the tasks being handled, scored by the visibility deadline
+	AISERTaskDelayed    = "aiser_task_delayed"        // zset, the tasks waiting to run, scored by the run time
 
 	AISER2FATempSecret         = "aiser_2fa_temp_secret_%d"
 	AISER2FAAccountVerifyCount = "aiser_2fa_account_verify_count_%d"
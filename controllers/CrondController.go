@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type CrondController struct {
+	core.BaseController
+}
+
+func (ctrl *CrondController) GetList(c *gin.Context) {
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"list": core.GetCrondJobStatusList(),
+	})
+}
+
+func (ctrl *CrondController) Trigger(c *gin.Context) {
+	req := new(data.CrondJobNameReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	if err := core.TriggerCrondJob(req.Name); err != nil {
+		log.Error("", "core.TriggerCrondJob() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/taskpool"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TaskController struct {
+	core.BaseController
+}
+
+func (ctrl *TaskController) GetStats(c *gin.Context) {
+	stats, err := taskpool.GetQueueStats()
+	if err != nil {
+		log.Error("", "taskpool.GetQueueStats() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"stats": stats,
+	})
+}
+
+func (ctrl *TaskController) RequeueDeadLetter(c *gin.Context) {
+	req := new(data.TaskTypeReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	count, err := taskpool.RequeueDeadLetter(req.TaskType)
+	if err != nil {
+		log.Error("", "taskpool.RequeueDeadLetter() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"count": count,
+	})
+}
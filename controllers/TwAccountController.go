@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwAccountController struct {
+	core.BaseController
+}
+
+func (ctrl *TwAccountController) GetOAuth2Url(c *gin.Context) {
+	req := new(data.TwAuthTypeReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	authUrl, err := core.GetTwOAuth2AuthUrl(req.AuthType, core.GetAdminInitiator(ctrl.GetAdminToken(c)))
+	if err == core.ErrUnsupportedAuthType {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("", "core.GetTwOAuth2AuthUrl() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"url": authUrl,
+	})
+}
+
+func (ctrl *TwAccountController) GetOAuth1Url(c *gin.Context) {
+	authUrl, err := core.GetTwOAuth1AuthUrl(core.GetAdminInitiator(ctrl.GetAdminToken(c)))
+	if err != nil {
+		log.Error("", "core.GetTwOAuth1AuthUrl() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"url": authUrl,
+	})
+}
+
+func (ctrl *TwAccountController) GetReconnectUrl(c *gin.Context) {
+	req := new(data.TwAccountUserIdReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	authUrl, err := core.GetTwReconnectUrl(req.UserId, core.GetAdminInitiator(ctrl.GetAdminToken(c)))
+	if err != nil {
+		log.Error("", "core.GetTwReconnectUrl() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"url": authUrl,
+	})
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/tools/log"
+	"github.com/project-miko/miko/tools/strutils"
+)
+
+// TwAuthController handles the callbacks of the twitter authorizations, it is not behind the admin token
+type TwAuthController struct {
+	core.BaseController
+}
+
+func (ctrl *TwAuthController) OAuth2Callback(c *gin.Context) {
+	if errMsg := c.Query("error"); errMsg != "" {
+		ctrl.renderAuthResult(c, "Twitter OAuth2", fmt.Errorf("twitter returned error %s", errMsg))
+		return
+	}
+
+	account, err := core.ConnectTwOAuth2Account(c.Query("code"), c.Query("state"))
+	if err != nil {
+		ctrl.renderAuthResult(c, "Twitter OAuth2", fmt.Errorf("core.ConnectTwOAuth2Account() error %w", err))
+		return
+	}
+
+	ctrl.renderAuthResult(c, "@"+account.Account, nil)
+}
+
+func (ctrl *TwAuthController) OAuth1Callback(c *gin.Context) {
+	if denied := c.Query("denied"); denied != "" {
+		ctrl.renderAuthResult(c, "Twitter OAuth1", fmt.Errorf("the authorization is denied"))
+		return
+	}
+
+	twOAuth1, err := core.ConnectTwOAuth1Account(c.Query("oauth_token"), c.Query("oauth_verifier"))
+	if err != nil {
+		ctrl.renderAuthResult(c, "Twitter OAuth1", fmt.Errorf("core.ConnectTwOAuth1Account() error %w", err))
+		return
+	}
+
+	ctrl.renderAuthResult(c, "@"+twOAuth1.Account, nil)
+}
+
+// renderAuthResult render the result page, it jumps to the front page when the authorization succeeded.
+// the page is public, an error is only logged, the page shows a request id to find it by
+func (ctrl *TwAuthController) renderAuthResult(c *gin.Context, title string, err error) {
+	status := "success"
+	jumpUrl := conf.TwitterOAuth2JumpFrontUrl
+	errMsg := ""
+	if err != nil {
+		requestId := strutils.GetUUID()
+		log.Error("", "twitter auth failed, request id: %s, error %s", requestId, err.Error())
+
+		status = "failed"
+		jumpUrl = ""
+		errMsg = "please try again or contact the admin with the request id " + requestId
+	}
+
+	c.HTML(http.StatusOK, "twitterauth.tmpl", gin.H{
+		"title":   title,
+		"status":  status,
+		"errmsg":  errMsg,
+		"jumpurl": jumpUrl,
+	})
+}
@@ -0,0 +1,137 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwFollowerController struct {
+	core.BaseController
+}
+
+// AddWatch snapshot the followers of an account periodically
+func (ctrl *TwFollowerController) AddWatch(c *gin.Context) {
+	req := new(data.TwFollowerWatchReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	watch, err := core.AddTwFollowerWatch(req.Account)
+	if err != nil {
+		log.Error("", "core.AddTwFollowerWatch() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"watch": watch,
+	})
+}
+
+func (ctrl *TwFollowerController) RemoveWatch(c *gin.Context) {
+	req := new(data.TwFollowerUserIdReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	if err := core.RemoveTwFollowerWatch(req.UserId); err != nil {
+		log.Error("", "core.RemoveTwFollowerWatch() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
+
+func (ctrl *TwFollowerController) GetWatchList(c *gin.Context) {
+	list, err := models.GetAllTwFollowerWatchList()
+	if err != nil {
+		log.Error("", "models.GetAllTwFollowerWatchList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"list": list,
+	})
+}
+
+// GetSnapshotList the follower counts of the snapshots with the follows and the unfollows since the one before
+func (ctrl *TwFollowerController) GetSnapshotList(c *gin.Context) {
+	req := new(data.TwGetFollowerSnapshotListReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+	amount, list, err := models.GetTwFollowerSnapshotList(req.UserId, page, limit)
+	if err != nil {
+		log.Error("", "models.GetTwFollowerSnapshotList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"amount": amount,
+		"list":   list,
+	})
+}
+
+// GetChangeList who followed and who unfollowed between the snapshots, the latest first
+func (ctrl *TwFollowerController) GetChangeList(c *gin.Context) {
+	ctrl.getChangeList(c, false)
+}
+
+// GetNotableList the notable new followers, the ones with the most followers first
+func (ctrl *TwFollowerController) GetNotableList(c *gin.Context) {
+	ctrl.getChangeList(c, true)
+}
+
+func (ctrl *TwFollowerController) getChangeList(c *gin.Context, notable bool) {
+	req := new(data.TwGetFollowerChangeListReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	changeType := req.Type
+	if notable {
+		changeType = models.TwFollowerChangeTypeFollow
+	}
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+	amount, list, err := models.GetTwFollowerChangeList(req.UserId, changeType, notable, req.StartAt, req.EndAt, notable, page, limit)
+	if err != nil {
+		log.Error("", "models.GetTwFollowerChangeList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"amount": amount,
+		"list":   list,
+	})
+}
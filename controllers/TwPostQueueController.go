@@ -0,0 +1,172 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwPostQueueController struct {
+	core.BaseController
+}
+
+func (ctrl *TwPostQueueController) GetSlots(c *gin.Context) {
+	req := new(data.TwPostQueueUserReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	slots, err := core.GetTwPostingSlots(req.UserId)
+	if err != nil {
+		log.Error("", "core.GetTwPostingSlots() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"list": slots,
+	})
+}
+
+func (ctrl *TwPostQueueController) SetSlots(c *gin.Context) {
+	req := new(data.TwSetPostingSlotsReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	if err := core.SetTwPostingSlots(req.UserId, req.Slots); err != nil {
+		log.Error("", "core.SetTwPostingSlots() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
+
+func (ctrl *TwPostQueueController) GetQueue(c *gin.Context) {
+	req := new(data.TwPostQueueUserReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	list, err := core.GetTwPostQueue(req.UserId)
+	if err != nil {
+		log.Error("", "core.GetTwPostQueue() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"list": list,
+	})
+}
+
+func (ctrl *TwPostQueueController) Add(c *gin.Context) {
+	req := new(data.TwAddPostQueueReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	item, err := core.AddTwPostQueueItem(req.UserId, req.TwScheduleLibId, req.ThreadList)
+	if err != nil {
+		log.Error("", "core.AddTwPostQueueItem() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	quota, err := core.CheckTwPostQuota(req.UserId)
+	if err != nil {
+		log.Error("", "core.CheckTwPostQuota() error %s", err.Error())
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"queue_id": item.Id,
+		"quota":    quota,
+	})
+}
+
+func (ctrl *TwPostQueueController) Reorder(c *gin.Context) {
+	req := new(data.TwReorderPostQueueReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	err := core.ReorderTwPostQueue(req.UserId, req.QueueIds)
+	if err == core.ErrPostQueueOrderMismatch {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("", "core.ReorderTwPostQueue() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
+
+func (ctrl *TwPostQueueController) PostNext(c *gin.Context) {
+	req := new(data.TwPostQueueItemReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	err := core.PostNextTwPostQueue(req.UserId, req.QueueId)
+	if err == core.ErrPostQueueItemNotQueued {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("", "core.PostNextTwPostQueue() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
+
+func (ctrl *TwPostQueueController) Shuffle(c *gin.Context) {
+	req := new(data.TwPostQueueUserReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	if err := core.ShuffleTwPostQueue(req.UserId); err != nil {
+		log.Error("", "core.ShuffleTwPostQueue() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
+
+func (ctrl *TwPostQueueController) Remove(c *gin.Context) {
+	req := new(data.TwPostQueueItemReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	err := core.RemoveTwPostQueueItem(req.UserId, req.QueueId)
+	if err == core.ErrPostQueueItemNotQueued {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("", "core.RemoveTwPostQueueItem() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwPostedTweetController struct {
+	core.BaseController
+}
+
+// GetList list the tweets posted by miko, the latest first
+func (ctrl *TwPostedTweetController) GetList(c *gin.Context) {
+	req := new(data.TwGetPostedTweetListReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+
+	amount, list, err := models.GetPostedTweetList(req.UserId, req.Source, req.SourceId, req.Status, req.StartAt, req.EndAt, page, limit)
+	if err != nil {
+		log.Error("", "models.GetPostedTweetList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"amount": amount,
+		"list":   list,
+	})
+}
+
+func (ctrl *TwPostedTweetController) Delete(c *gin.Context) {
+	req := new(data.TwDeletePostedTweetReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	postedTweet, err := core.DeletePostedTweet(req.TweetId)
+	if err != nil {
+		log.Error("", "core.DeletePostedTweet() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"tweet": postedTweet,
+	})
+}
+
+// DeleteThread delete all the tweets of a posted thread
+func (ctrl *TwPostedTweetController) DeleteThread(c *gin.Context) {
+	req := new(data.TwDeletePostedThreadReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	list, err := core.DeletePostedThread(req.ThreadId)
+	if err != nil {
+		log.Error("", "core.DeletePostedThread() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"list": list,
+	})
+}
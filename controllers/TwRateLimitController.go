@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwRateLimitController struct {
+	core.BaseController
+}
+
+func (ctrl *TwRateLimitController) GetList(c *gin.Context) {
+	req := new(data.TwRateLimitListReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	list, err := core.GetTwRateLimitList(req.Owner)
+	if err != nil {
+		log.Error("", "core.GetTwRateLimitList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"list": list,
+	})
+}
+
+func (ctrl *TwRateLimitController) GetUserRateLimit(c *gin.Context) {
+	req := new(data.TwUserRateLimitReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	limit, err := core.GetUserRateLimit(req.UserId)
+	if err != nil {
+		log.Error("", "core.GetUserRateLimit() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"rate_limit": limit,
+	})
+}
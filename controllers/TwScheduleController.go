@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwScheduleController struct {
+	core.BaseController
+}
+
+func (ctrl *TwScheduleController) AddEvergreen(c *gin.Context) {
+	req := new(data.TwAddEvergreenScheduleReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	if _, ok := models.AllowTweetLibQueryMode[req.QueryMode]; !ok {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, fmt.Sprintf("invalid query_mode %d", req.QueryMode))
+		return
+	}
+
+	twSchedule, err := core.AddEvergreenSchedule(req)
+	if err != nil {
+		log.Error("", "core.AddEvergreenSchedule() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	// the schedule is kept even if the plan is infeasible, the warnings tell the admin to adjust it
+	quota, err := core.CheckTwPostQuota(req.UserId)
+	if err != nil {
+		log.Error("", "core.CheckTwPostQuota() error %s", err.Error())
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"id":          twSchedule.Id,
+		"next_run_at": twSchedule.NextRunAt,
+		"quota":       quota,
+	})
+}
+
+func (ctrl *TwScheduleController) GetTweetLibPostLogList(c *gin.Context) {
+	req := new(data.TwGetTweetLibPostLogListReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+
+	amount, list, err := models.GetTweetLibPostLogList(req.UserId, req.TweetLibId, page, limit)
+	if err != nil {
+		log.Error("", "models.GetTweetLibPostLogList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"amount": amount,
+		"list":   list,
+	})
+}
+
+func (ctrl *TwScheduleController) AddGenerated(c *gin.Context) {
+	req := new(data.TwAddGeneratedScheduleReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	twSchedule, err := core.AddGeneratedSchedule(req)
+	var backupErr *core.ErrInvalidBackupText
+	if errors.As(err, &backupErr) {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("", "core.AddGeneratedSchedule() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	// the schedule is kept even if the plan is infeasible, the warnings tell the admin to adjust it
+	quota, err := core.CheckTwPostQuota(req.UserId)
+	if err != nil {
+		log.Error("", "core.CheckTwPostQuota() error %s", err.Error())
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"id":          twSchedule.Id,
+		"next_run_at": twSchedule.NextRunAt,
+		"quota":       quota,
+	})
+}
+
+func (ctrl *TwScheduleController) Preview(c *gin.Context) {
+	req := new(data.TwPreviewScheduleReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	resp, err := core.PreviewSchedule(req)
+	if err != nil {
+		log.Error("", "core.PreviewSchedule() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"preview": resp,
+	})
+}
+
+func (ctrl *TwScheduleController) Pause(c *gin.Context) {
+	req := new(data.TwScheduleIdReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	if _, err := core.PauseTwSchedule(req.ScheduleId); err != nil {
+		log.Error("", "core.PauseTwSchedule() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
+
+func (ctrl *TwScheduleController) Resume(c *gin.Context) {
+	req := new(data.TwScheduleIdReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	twSchedule, err := core.ResumeTwSchedule(req.ScheduleId)
+	if err != nil {
+		log.Error("", "core.ResumeTwSchedule() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"id":           twSchedule.Id,
+		"remain_count": twSchedule.RemainCount,
+		"next_run_at":  twSchedule.NextRunAt,
+	})
+}
+
+func (ctrl *TwScheduleController) AddBlackoutWindow(c *gin.Context) {
+	req := new(data.TwAddBlackoutWindowReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	window, err := core.AddTwBlackoutWindow(req)
+	if err != nil {
+		log.Error("", "core.AddTwBlackoutWindow() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"id": window.Id,
+	})
+}
+
+func (ctrl *TwScheduleController) DelBlackoutWindow(c *gin.Context) {
+	req := new(data.TwBlackoutWindowIdReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	if err := core.DelTwBlackoutWindow(req.Id); err != nil {
+		log.Error("", "core.DelTwBlackoutWindow() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccessMsg(c)
+}
+
+func (ctrl *TwScheduleController) GetBlackoutWindowList(c *gin.Context) {
+	req := new(data.TwGetBlackoutWindowListReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+
+	amount, list, err := models.GetTwBlackoutWindowList(req.UserId, req.WithGlobal, page, limit)
+	if err != nil {
+		log.Error("", "models.GetTwBlackoutWindowList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"amount": amount,
+		"list":   list,
+	})
+}
+
+func (ctrl *TwScheduleController) SetEmergencyStop(c *gin.Context) {
+	req := new(data.TwSetEmergencyStopReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	operator := ""
+	if tokenInfo := ctrl.GetAdminToken(c); tokenInfo != nil {
+		operator = tokenInfo.Username
+	}
+
+	if err := core.SetEmergencyStop(req.On, operator, req.Reason); err != nil {
+		log.Error("", "core.SetEmergencyStop() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+	log.Info("", "emergency stop is set to %v by %s, reason: %s", req.On, operator, req.Reason)
+
+	ctrl.JsonSuccessMsg(c)
+}
+
+func (ctrl *TwScheduleController) GetEmergencyStop(c *gin.Context) {
+	info, err := core.GetEmergencyStop()
+	if err != nil {
+		log.Error("", "core.GetEmergencyStop() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"on":   info != nil,
+		"info": info,
+	})
+}
+
+func (ctrl *TwScheduleController) GetLogList(c *gin.Context) {
+	req := new(data.TwGetScheduleLogListReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+
+	amount, list, err := core.GetScheduleLogList(req, page, limit)
+	if err != nil {
+		log.Error("", "core.GetScheduleLogList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"amount": amount,
+		"list":   list,
+	})
+}
+
+func (ctrl *TwScheduleController) GetStats(c *gin.Context) {
+	req := new(data.TwGetScheduleStatsReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	list, err := core.GetScheduleStats(req)
+	if err != nil {
+		log.Error("", "core.GetScheduleStats() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"list": list,
+	})
+}
+
+func (ctrl *TwScheduleController) GetPostQuota(c *gin.Context) {
+	req := new(data.TwAccountUserIdReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	quota, err := core.CheckTwPostQuota(req.UserId)
+	if err != nil {
+		log.Error("", "core.CheckTwPostQuota() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"quota": quota,
+	})
+}
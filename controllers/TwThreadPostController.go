@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwThreadPostController struct {
+	core.BaseController
+}
+
+func (ctrl *TwThreadPostController) GetList(c *gin.Context) {
+	req := new(data.TwGetThreadPostListReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+
+	amount, list, err := models.GetTwThreadPostList(req.UserId, req.Status, page, limit)
+	if err != nil {
+		log.Error("", "models.GetTwThreadPostList() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"amount": amount,
+		"list":   list,
+	})
+}
+
+func (ctrl *TwThreadPostController) GetDetail(c *gin.Context) {
+	ctrl.handleThreadPost(c, "core.GetTwThreadPostDetail()", core.GetTwThreadPostDetail)
+}
+
+// Resume post the rest of a failed thread post, from the tweet it stopped at
+func (ctrl *TwThreadPostController) Resume(c *gin.Context) {
+	ctrl.handleThreadPost(c, "core.ResumeTwThreadPost()", core.ResumeTwThreadPost)
+}
+
+// Rollback delete the posted tweets of a thread post failed halfway
+func (ctrl *TwThreadPostController) Rollback(c *gin.Context) {
+	ctrl.handleThreadPost(c, "core.RollbackTwThreadPost()", core.RollbackTwThreadPost)
+}
+
+// Undo cancel a thread post still held in the undo window
+func (ctrl *TwThreadPostController) Undo(c *gin.Context) {
+	ctrl.handleThreadPost(c, "core.UndoTwThreadPost()", core.UndoTwThreadPost)
+}
+
+func (ctrl *TwThreadPostController) handleThreadPost(c *gin.Context, name string, handle func(id int64) (*core.TwThreadPostDetail, error)) {
+	req := new(data.TwThreadPostIdReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	detail, err := handle(req.Id)
+	if err != nil {
+		log.Error("", "%s error %s", name, err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"thread_post": detail,
+	})
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwTweetMetricController struct {
+	core.BaseController
+}
+
+// GetTweet the metric snapshots of a posted tweet at +1h, +24h and +7d
+func (ctrl *TwTweetMetricController) GetTweet(c *gin.Context) {
+	req := new(data.TwGetTweetPerformanceReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	performance, err := core.GetTweetPerformance(req.TweetId)
+	if err != nil {
+		log.Error("", "core.GetTweetPerformance() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"performance": performance,
+	})
+}
+
+// GetSchedule the metrics of the tweets posted by a schedule
+func (ctrl *TwTweetMetricController) GetSchedule(c *gin.Context) {
+	req := new(data.TwGetSchedulePerformanceReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+
+	performance, err := core.GetTwSchedulePerformance(req.TwScheduleId, page, limit)
+	if err != nil {
+		log.Error("", "core.GetTwSchedulePerformance() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"performance": performance,
+	})
+}
+
+// GetLibRank rank the schedule libs by the average metric per tweet, the engagements at +24h by default
+func (ctrl *TwTweetMetricController) GetLibRank(c *gin.Context) {
+	req := new(data.TwGetScheduleLibRankReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	var page, limit int64 = 1, 20
+	if req.BasePage != nil {
+		if req.Page > 0 {
+			page = req.Page
+		}
+		if req.Limit > 0 {
+			limit = req.Limit
+		}
+	}
+	stage, order := req.Stage, req.Order
+	if stage == "" {
+		stage = models.TwTweetMetricStage24Hour
+	}
+	if order == "" {
+		order = "engagements"
+	}
+
+	amount, list, err := core.GetTwScheduleLibRank(req.UserId, stage, order, page, limit)
+	if err != nil {
+		log.Error("", "core.GetTwScheduleLibRank() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"amount": amount,
+		"list":   list,
+	})
+}
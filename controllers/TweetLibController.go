@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TweetLibController struct {
+	core.BaseController
+}
+
+func (ctrl *TweetLibController) Import(c *gin.Context) {
+	req := new(data.TweetLibImportReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+	if len(req.TweetUrls) == 0 && len(req.Accounts) == 0 && len(req.Csv) == 0 {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, "tweet_urls, accounts or csv is required")
+		return
+	}
+
+	resp, err := core.ImportTweetLib(req)
+	if err != nil {
+		log.Error("", "core.ImportTweetLib() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"result": resp,
+	})
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+type TwitterController struct {
+	core.BaseController
+}
+
+// GetThreadWithTweetUrlByApi core.RouteDeFlashGetThreadByApi
+func (ctrl *TwitterController) GetThreadWithTweetUrlByApi(c *gin.Context) {
+	ctrl.getThread(c, core.GetTwThreadWithTweetUrlByApi)
+}
+
+// GetThreadWithTweetUrl the thread by the api, or by the configured backends if the api fails
+func (ctrl *TwitterController) GetThreadWithTweetUrl(c *gin.Context) {
+	ctrl.getThread(c, core.GetTwThreadWithTweetUrl)
+}
+
+func (ctrl *TwitterController) getThread(c *gin.Context, getThread func(tweetUrl, userId string) (*core.GetTweetResp, error)) {
+	req := new(data.GetThreadWithTweetUrlByApiReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	thread, err := getThread(req.TweetUrl, req.UserId)
+	if err == core.ErrInvalidTweetUrl {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+	if err != nil {
+		log.Error("", "core.GetTwThreadWithTweetUrl() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"thread": thread,
+	})
+}
+
+// CreateTweet post a thread by hand, a thread held in the undo window is returned before it goes out
+func (ctrl *TwitterController) CreateTweet(c *gin.Context) {
+	req := new(data.CreateTweetReq)
+	if err := c.ShouldBindBodyWith(req, binding.JSON); err != nil {
+		ctrl.JsonError(c, conf.ApiCodeParamErr, err.Error())
+		return
+	}
+
+	detail, err := core.CreateTweet(req)
+	if err != nil {
+		log.Error("", "core.CreateTweet() error %s", err.Error())
+		ctrl.JsonError(c, conf.ApiCodeErrMsg, err.Error())
+		return
+	}
+
+	ctrl.JsonSuccess(c, map[string]interface{}{
+		"thread_post": detail,
+	})
+}
@@ -0,0 +1,166 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+var (
+	ErrEmergencyStop = fmt.Errorf("posting is stopped by the emergency stop switch")
+)
+
+type ErrBlackout struct {
+	Window   *models.TwBlackoutWindow
+	Deferred bool
+}
+
+func (e *ErrBlackout) Error() string {
+	if e.Deferred {
+		return fmt.Sprintf("fire is deferred to %d by the blackout window %d", e.Window.EndAt, e.Window.Id)
+	}
+	return fmt.Sprintf("fire is skipped by the blackout window %d", e.Window.Id)
+}
+
+type EmergencyStopInfo struct {
+	Operator  string `json:"operator"`
+	Reason    string `json:"reason"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+// AddTwBlackoutWindow add a blackout window, the window is global if the user id is empty
+func AddTwBlackoutWindow(req *data.TwAddBlackoutWindowReq) (*models.TwBlackoutWindow, error) {
+	if req.EndAt <= req.StartAt {
+		return nil, fmt.Errorf("end_at must be after start_at")
+	}
+	if _, ok := models.AllowTwBlackoutPolicy[req.Policy]; !ok {
+		return nil, fmt.Errorf("invalid policy %d", req.Policy)
+	}
+
+	window := &models.TwBlackoutWindow{
+		UserId:    req.UserId,
+		Name:      req.Name,
+		StartAt:   req.StartAt,
+		EndAt:     req.EndAt,
+		Policy:    req.Policy,
+		Status:    models.TwBlackoutWindowStatusEnabled,
+		CreatedAt: tools.GetMillisecond(time.Now()),
+	}
+	if err := window.Save(); err != nil {
+		return nil, err
+	}
+
+	return window, nil
+}
+
+func DelTwBlackoutWindow(id int64) error {
+	window, err := models.GetTwBlackoutWindowById(id)
+	if err != nil {
+		return err
+	}
+	if window == nil {
+		return conf.ErrRecordNotFound
+	}
+
+	window.Status = models.TwBlackoutWindowStatusDeleted
+	return window.Update()
+}
+
+// handleTwScheduleBlackout consume the fire of the schedule that falls in the window, a deferred fire is coalesced if there is one pending already
+func handleTwScheduleBlackout(twSchedule *models.TwSchedule, window *models.TwBlackoutWindow) error {
+	var deferTo int64
+	if window.Policy == models.TwBlackoutPolicyDefer {
+		deferTo = window.EndAt
+	}
+
+	deferred, err := consumeTwScheduleFire(twSchedule, deferTo)
+	if err != nil {
+		return err
+	}
+
+	blackoutErr := &ErrBlackout{Window: window, Deferred: deferred}
+	if !deferred {
+		if e := onTwPostQueueScheduleDone(twSchedule, blackoutErr); e != nil {
+			log.Error("", "onTwPostQueueScheduleDone() error %s", e.Error())
+		}
+	}
+
+	return blackoutErr
+}
+
+// consumeTwScheduleFire defer the fire of the schedule to deferTo, the fire is skipped if deferTo is 0 or a deferred fire is pending already
+func consumeTwScheduleFire(twSchedule *models.TwSchedule, deferTo int64) (bool, error) {
+	deferred := false
+	if deferTo > 0 && twSchedule.DeferredRunAt == 0 {
+		twSchedule.DeferredRunAt = deferTo
+		if err := addTwDeferredJob(twSchedule); err != nil {
+			return false, fmt.Errorf("addTwDeferredJob() error %s", err.Error())
+		}
+		deferred = true
+	} else {
+		twSchedule.RemainCount--
+		if twSchedule.RemainCount <= 0 {
+			twSchedule.Status = models.TwScheduleStatusFinished
+		}
+	}
+
+	nextRunAt, err := getTwCreateTweetJobNextRunAt(twSchedule.UserId, twSchedule.TwScheduleLibId)
+	if err != nil {
+		return false, err
+	}
+	twSchedule.NextRunAt = nextRunAt
+
+	if err = twSchedule.Update(); err != nil {
+		return false, err
+	}
+
+	return deferred, nil
+}
+
+// SetEmergencyStop turn the switch stopping all posting on or off
+func SetEmergencyStop(on bool, operator, reason string) error {
+	rdb := models.GetRdbInst()
+	if !on {
+		return rdb.Del(conf.AISEREmergencyStopPosting)
+	}
+
+	info := &EmergencyStopInfo{
+		Operator:  operator,
+		Reason:    reason,
+		CreatedAt: tools.GetMillisecond(time.Now()),
+	}
+	return rdb.SetStruct(conf.AISEREmergencyStopPosting, info, 0)
+}
+
+// GetEmergencyStop get the emergency stop switch, info is nil if posting is not stopped
+func GetEmergencyStop() (*EmergencyStopInfo, error) {
+	info := new(EmergencyStopInfo)
+	err := models.GetRdbInst().GetStruct(conf.AISEREmergencyStopPosting, info)
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+// checkEmergencyStop return ErrEmergencyStop if posting is stopped, posting is refused as well when the switch can not be read
+func checkEmergencyStop() error {
+	info, err := GetEmergencyStop()
+	if err != nil {
+		return fmt.Errorf("GetEmergencyStop() error %s", err.Error())
+	}
+	if info != nil {
+		return ErrEmergencyStop
+	}
+
+	return nil
+}
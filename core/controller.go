@@ -51,3 +51,14 @@ func (ctrl *BaseController) JsonError(c *gin.Context, code int32, msg string) {
 
 	c.JSON(http.StatusOK, ret)
 }
+
+// GetAdminToken get the admin token set by the AdminToken middleware, nil if the request is not from an admin
+func (ctrl *BaseController) GetAdminToken(c *gin.Context) *AdminToken {
+	v, ok := c.Get("admin_token")
+	if !ok {
+		return nil
+	}
+
+	tokenInfo, _ := v.(*AdminToken)
+	return tokenInfo
+}
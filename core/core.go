@@ -1,9 +1,12 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/project-miko/miko/conf"
 
@@ -11,9 +14,11 @@ import (
 )
 
 var (
-	webEngine *gin.Engine
-	LoginKey  []byte
-	ParamKey  []byte
+	webEngine        *gin.Engine
+	httpServer       *http.Server
+	httpServerLocker sync.Mutex
+	LoginKey         []byte
+	ParamKey         []byte
 )
 
 func init() {
@@ -43,12 +48,31 @@ func Run() {
 	host := conf.GetConfigString("app", "host")
 	port := conf.GetConfigString("app", "port")
 
-	e := webEngine.Run(host + ":" + port)
-	if e != nil {
+	httpServerLocker.Lock()
+	httpServer = &http.Server{
+		Addr:    host + ":" + port,
+		Handler: webEngine,
+	}
+	httpServerLocker.Unlock()
+
+	e := httpServer.ListenAndServe()
+	if e != nil && e != http.ErrServerClosed {
 		panic(e)
 	}
 }
 
+// Shutdown stop accepting connections and wait for the in-flight requests to finish
+func Shutdown(ctx context.Context) error {
+	httpServerLocker.Lock()
+	defer httpServerLocker.Unlock()
+
+	if httpServer == nil {
+		return nil
+	}
+
+	return httpServer.Shutdown(ctx)
+}
+
 func UseMiddleware(f func(*gin.Context)) {
 	webEngine.Use(f)
 }
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/g8rswimmer/go-twitter/v2"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	// max length of a recent search query
+	maxSearchQueryLength = 512
+	// max length of a "from:<id> OR " item, user ids are at most 19 digits
+	maxSearchQueryItemLength = 28
+	maxSearchResults         = 100
+	maxUserLookupIds         = 100
+	// the crawler waits for the rate limit to reset at most this long
+	maxCrawlRateLimitWait = 15 * time.Minute
+	// the tweets posted within it are read again every crawl, at most the 7 days of the recent search
+	crawlEngagementWindow = 24 * time.Hour
+)
+
+// CrawlTwDailyData collect the follower counts of the monitored users for the last finished crawl interval, and the tweets
+// posted in the trailing crawlEngagementWindow, the engagement of a tweet keeps growing after the interval it is posted in.
+// the rows are saved at the end of their intervals, crawling the same interval again updates them.
+// a failed batch is logged and keeps the last counts of its users, the rest are crawled on
+func CrawlTwDailyData(now time.Time) error {
+	interval := time.Duration(conf.CrawlInterval) * time.Minute
+	end := now.UTC().Truncate(interval)
+	start := end.Add(-max(crawlEngagementWindow, interval))
+	log.Info("", "CrawlTwDailyData() start, statistic at: %s", end.Format(time.RFC3339))
+
+	userInfoList, err := models.GetAllTwUserInfoList(-1)
+	if err != nil {
+		return err
+	}
+	if len(userInfoList) == 0 {
+		return nil
+	}
+
+	userIds := make([]string, 0)
+	for _, v := range userInfoList {
+		userIds = append(userIds, v.UserId)
+	}
+
+	// statistic_at -> user_id -> row, the rows of the earlier intervals are only updated if they exist
+	statisticAt := tools.GetMillisecond(end)
+	existList, err := models.GetListByUserIds(userIds, tools.GetMillisecond(start.Add(interval)), statisticAt)
+	if err != nil {
+		return err
+	}
+	dataMap := make(map[int64]map[string]*models.TwDailyData)
+	dataMap[statisticAt] = make(map[string]*models.TwDailyData)
+	for _, v := range existList {
+		if _, ok := dataMap[v.StatisticAt]; !ok {
+			dataMap[v.StatisticAt] = make(map[string]*models.TwDailyData)
+		}
+		dataMap[v.StatisticAt][v.UserId] = v
+	}
+	// a new row starts from the last follower count of its user, it is kept if the lookup of the user fails
+	lastMap := make(map[string]*models.TwDailyData)
+	for _, v := range existList {
+		if last, ok := lastMap[v.UserId]; v.StatisticAt < statisticAt && (!ok || v.StatisticAt > last.StatisticAt) {
+			lastMap[v.UserId] = v
+		}
+	}
+	for _, userId := range userIds {
+		if _, ok := dataMap[statisticAt][userId]; !ok {
+			d := &models.TwDailyData{
+				UserId:      userId,
+				StatisticAt: statisticAt,
+				CreatedAt:   tools.GetMillisecond(time.Now()),
+			}
+			if last, ok := lastMap[userId]; ok {
+				d.FollowerCount = last.FollowerCount
+			}
+			dataMap[statisticAt][userId] = d
+		}
+	}
+
+	reqCount := 0
+	failed := crawlFollowerCount(userIds, dataMap[statisticAt], &reqCount)
+	failed += crawlTweetMetrics(userIds, start, end, interval, dataMap, &reqCount)
+
+	for _, rows := range dataMap {
+		for _, v := range rows {
+			if v.Id == 0 {
+				err = v.Save()
+			} else {
+				err = v.Update()
+			}
+			if err != nil {
+				return fmt.Errorf("save TwDailyData error %s, user id: %s", err.Error(), v.UserId)
+			}
+		}
+	}
+
+	log.Info("", "CrawlTwDailyData() done, users: %d, requests: %d, failed batches: %d", len(userIds), reqCount, failed)
+	if failed > 0 {
+		return fmt.Errorf("crawl failed for %d batches", failed)
+	}
+
+	return nil
+}
+
+// crawlFollowerCount set the follower counts of the users, return the number of batches failed
+func crawlFollowerCount(userIds []string, dataMap map[string]*models.TwDailyData, reqCount *int) int {
+	failed := 0
+	batchCount := splitTask(int64(len(userIds)), maxUserLookupIds)
+	for i := int64(0); i < batchCount; i++ {
+		batch := userIds[i*maxUserLookupIds : min((i+1)*maxUserLookupIds, int64(len(userIds)))]
+		if err := twitterapi.Wait(twitterapi.AppOwner, twitterapi.EndpointUsersLookup, maxCrawlRateLimitWait); err != nil {
+			log.Error("", "twitterapi.Wait() error %s", err.Error())
+			failed++
+			continue
+		}
+
+		userMap, err := getUserMap(batch, conf.TwitterAPIToken, reqCount)
+		if err != nil {
+			log.Error("", "getUserMap() error %s", err.Error())
+			failed++
+			continue
+		}
+
+		for userId, userObj := range userMap {
+			if d, ok := dataMap[userId]; ok && userObj.PublicMetrics != nil {
+				d.FollowerCount = userObj.PublicMetrics.Followers
+			}
+		}
+	}
+
+	return failed
+}
+
+// crawlTweetMetrics count the tweets of the users posted in [start, end) into the rows of the intervals they are posted in,
+// the counts of a batch are replaced only once all of its pages are read. return the number of batches failed
+func crawlTweetMetrics(userIds []string, start, end time.Time, interval time.Duration, dataMap map[int64]map[string]*models.TwDailyData, reqCount *int) int {
+	twClient := twitterapi.NewTwitterClient(twitterapi.AppOwner, conf.TwitterAPIToken, "", "")
+
+	failed := 0
+	batchSize := int64((maxSearchQueryLength - len(concatUserId(nil))) / maxSearchQueryItemLength)
+	batchCount := splitTask(int64(len(userIds)), batchSize)
+	for i := int64(0); i < batchCount; i++ {
+		batch := userIds[i*batchSize : min((i+1)*batchSize, int64(len(userIds)))]
+		tweets, err := searchTweetsOfUsers(twClient, batch, start, end, reqCount)
+		if err != nil {
+			log.Error("", "searchTweetsOfUsers() error %s, users: %d", err.Error(), len(batch))
+			failed++
+			continue
+		}
+
+		for _, rows := range dataMap {
+			for _, userId := range batch {
+				if d, ok := rows[userId]; ok {
+					d.TweetIds, d.TweetCount, d.LikeCount, d.ReplyCount, d.RetweetCount = "", 0, 0, 0, 0
+				}
+			}
+		}
+
+		for _, tweet := range tweets {
+			createdAt, err := time.Parse(time.RFC3339, tweet.CreatedAt)
+			if err != nil {
+				log.Warning("", "parse created_at of tweet %s error %s", tweet.ID, err.Error())
+				continue
+			}
+			d, ok := dataMap[tools.GetMillisecond(createdAt.UTC().Truncate(interval).Add(interval))][tweet.AuthorID]
+			if !ok {
+				continue
+			}
+
+			d.TweetIds = strings.Trim(d.TweetIds+","+tweet.ID, ",")
+			d.TweetCount++
+			if tweet.PublicMetrics != nil {
+				d.LikeCount += tweet.PublicMetrics.Likes
+				d.ReplyCount += tweet.PublicMetrics.Replies
+				d.RetweetCount += tweet.PublicMetrics.Retweets
+			}
+		}
+	}
+
+	return failed
+}
+
+// searchTweetsOfUsers all pages of the tweets of the users posted in [start, end)
+func searchTweetsOfUsers(twClient twitterapi.TwitterClient, userIds []string, start, end time.Time, reqCount *int) ([]*twitter.TweetObj, error) {
+	opts := twitter.TweetRecentSearchOpts{
+		TweetFields: []twitter.TweetField{twitter.TweetFieldAuthorID, twitter.TweetFieldCreatedAt, twitter.TweetFieldPublicMetrics},
+		StartTime:   start,
+		EndTime:     end,
+		MaxResults:  maxSearchResults,
+	}
+
+	tweets := make([]*twitter.TweetObj, 0)
+	for {
+		if err := twitterapi.Wait(twitterapi.AppOwner, twitterapi.EndpointSearchRecent, maxCrawlRateLimitWait); err != nil {
+			return nil, fmt.Errorf("twitterapi.Wait() error %s", err.Error())
+		}
+
+		raw, meta, err := twClient.SearchTweets(concatUserId(userIds), opts)
+		*reqCount++
+		if err != nil {
+			return nil, fmt.Errorf("twClient.SearchTweets() error %s", err.Error())
+		}
+		tweets = append(tweets, raw.Tweets...)
+
+		if meta == nil || len(meta.NextToken) == 0 {
+			return tweets, nil
+		}
+		opts.NextToken = meta.NextToken
+	}
+}
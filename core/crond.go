@@ -1,18 +1,307 @@
 package core
 
 import (
-	"github.com/project-miko/miko/tools/timer"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+var (
+	ErrCrondJobExists   = fmt.Errorf("crond job exists")
+	ErrCrondJobNotFound = fmt.Errorf("crond job not found")
+	ErrCrondJobRunning  = fmt.Errorf("crond job is running")
+	ErrCrondJobLocked   = fmt.Errorf("crond job is running on another instance")
+	ErrCrondStopped     = fmt.Errorf("crond is stopped")
 )
 
-type Crond interface {
-	GetDurationMillisecond() uint32
-	Init()
-	Worker()
+var (
+	crondScheduler *gocron.Scheduler
+	crondJobs      = make(map[string]*CrondJob)
+	crondLocker    sync.Mutex
+	crondStopped   bool
+	// crondRunning tracks the triggered runs, the scheduled ones are waited by the scheduler
+	crondRunning sync.WaitGroup
+)
+
+// CrondJob is a named periodic job, either CronExp (with seconds) or Interval should be set
+type CrondJob struct {
+	Name     string
+	CronExp  string
+	Interval time.Duration
+	// Jitter delays every run by a random duration in [0, Jitter)
+	Jitter time.Duration
+	// SkipIfRunning skips a run while the previous one is still running
+	SkipIfRunning bool
+	// LockTTL > 0 takes a redis lock so that only one instance runs the job at a time
+	LockTTL time.Duration
+	// RunOnStart runs the job once when the crond starts
+	RunOnStart bool
+	Worker     func() error
+
+	locker      sync.Mutex
+	running     bool
+	lastStartAt int64
+	lastEndAt   int64
+	lastError   string
+	runCount    int64
+	job         *gocron.Job
+}
+
+type CrondJobStatus struct {
+	Name          string `json:"name"`
+	Schedule      string `json:"schedule"`
+	SkipIfRunning bool   `json:"skip_if_running"`
+	Locked        bool   `json:"locked"`
+	Running       bool   `json:"running"`
+	RunCount      int64  `json:"run_count"`
+	LastStartAt   int64  `json:"last_start_at"`
+	LastEndAt     int64  `json:"last_end_at"`
+	LastError     string `json:"last_error"`
+	NextRunAt     int64  `json:"next_run_at"`
+}
+
+// RegisterCrondJob add a job to the registry, it is scheduled when the crond starts
+func RegisterCrondJob(j *CrondJob) error {
+	if j.Name == "" || j.Worker == nil {
+		return fmt.Errorf("crond job name and worker are required")
+	}
+	if (j.CronExp == "") == (j.Interval <= 0) {
+		return fmt.Errorf("crond job %s requires either a cron expression or an interval", j.Name)
+	}
+
+	crondLocker.Lock()
+	defer crondLocker.Unlock()
+
+	if _, ok := crondJobs[j.Name]; ok {
+		return ErrCrondJobExists
+	}
+	crondJobs[j.Name] = j
+
+	return nil
+}
+
+// StartCronds schedule all the registered jobs
+func StartCronds() error {
+	crondLocker.Lock()
+	defer crondLocker.Unlock()
+
+	s := gocron.NewScheduler(conf.NewTimeZone)
+	s.TagsUnique()
+
+	for _, j := range crondJobs {
+		var (
+			job *gocron.Job
+			err error
+		)
+		if j.CronExp != "" {
+			job, err = s.CronWithSeconds(j.CronExp).Tag(j.Name).Do(j.run, false)
+		} else {
+			job, err = s.Every(j.Interval).StartAt(time.Now().Add(j.Interval)).Tag(j.Name).Do(j.run, false)
+		}
+		if err != nil {
+			return fmt.Errorf("schedule crond job %s error %s", j.Name, err.Error())
+		}
+		j.job = job
+
+		if j.RunOnStart {
+			crondRunning.Add(1)
+			go func(j *CrondJob) {
+				defer crondRunning.Done()
+				j.run(false)
+			}(j)
+		}
+	}
+
+	s.StartAsync()
+	crondScheduler = s
+	crondStopped = false
+
+	return nil
+}
+
+// StopCronds stop firing the jobs and wait for the running ones to finish
+func StopCronds() {
+	crondLocker.Lock()
+	crondStopped = true
+	s := crondScheduler
+	crondLocker.Unlock()
+
+	if s != nil {
+		s.Stop()
+	}
+	crondRunning.Wait()
+}
+
+// TriggerCrondJob run a job at once regardless of its schedule, the jitter is not applied
+func TriggerCrondJob(name string) error {
+	crondLocker.Lock()
+	defer crondLocker.Unlock()
+
+	if crondStopped {
+		return ErrCrondStopped
+	}
+
+	j, ok := crondJobs[name]
+	if !ok {
+		return ErrCrondJobNotFound
+	}
+
+	j.locker.Lock()
+	running := j.running
+	j.locker.Unlock()
+	if running && j.SkipIfRunning {
+		return ErrCrondJobRunning
+	}
+
+	crondRunning.Add(1)
+	go func() {
+		defer crondRunning.Done()
+		j.run(true)
+	}()
+
+	return nil
+}
+
+// GetCrondJobStatusList return the status of all the registered jobs ordered by name
+func GetCrondJobStatusList() []*CrondJobStatus {
+	crondLocker.Lock()
+	defer crondLocker.Unlock()
+
+	list := make([]*CrondJobStatus, 0, len(crondJobs))
+	for _, j := range crondJobs {
+		list = append(list, j.status())
+	}
+
+	sort.Slice(list, func(i, k int) bool {
+		return list[i].Name < list[k].Name
+	})
+
+	return list
+}
+
+func (j *CrondJob) status() *CrondJobStatus {
+	j.locker.Lock()
+	defer j.locker.Unlock()
+
+	st := &CrondJobStatus{
+		Name:          j.Name,
+		Schedule:      j.CronExp,
+		SkipIfRunning: j.SkipIfRunning,
+		Locked:        j.LockTTL > 0,
+		Running:       j.running,
+		RunCount:      j.runCount,
+		LastStartAt:   j.lastStartAt,
+		LastEndAt:     j.lastEndAt,
+		LastError:     j.lastError,
+	}
+	if st.Schedule == "" {
+		st.Schedule = "every " + j.Interval.String()
+	}
+	if j.job != nil && !j.job.NextRun().IsZero() {
+		st.NextRunAt = tools.GetMillisecond(j.job.NextRun())
+	}
+
+	return st
+}
+
+func (j *CrondJob) run(triggered bool) {
+	if !triggered && j.Jitter > 0 {
+		time.Sleep(time.Duration(rand.Int63n(int64(j.Jitter))))
+	}
+
+	j.locker.Lock()
+	if j.running && j.SkipIfRunning {
+		j.locker.Unlock()
+		log.Info("", "crond job %s is running, skipped", j.Name)
+		return
+	}
+	j.running = true
+	j.locker.Unlock()
+
+	defer func() {
+		j.locker.Lock()
+		j.running = false
+		j.locker.Unlock()
+	}()
+
+	if j.LockTTL > 0 {
+		unlock, err := j.lock()
+		if err != nil {
+			if err != ErrCrondJobLocked {
+				log.Error("", "crond job %s lock error %s", j.Name, err.Error())
+			}
+			return
+		}
+		defer unlock()
+	}
+
+	j.locker.Lock()
+	j.lastStartAt = tools.GetMillisecond(time.Now())
+	j.runCount++
+	j.locker.Unlock()
+
+	err := j.safeWork()
+
+	j.locker.Lock()
+	j.lastEndAt = tools.GetMillisecond(time.Now())
+	j.lastError = ""
+	if err != nil {
+		j.lastError = err.Error()
+	}
+	j.locker.Unlock()
+
+	if err != nil {
+		log.Error("", "crond job %s error %s", j.Name, err.Error())
+	}
+}
+
+// safeWork run the worker, a panic is returned as an error
+func (j *CrondJob) safeWork() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return j.Worker()
+}
+
+// lock take the redis lock of the job, the returned function releases it
+func (j *CrondJob) lock() (func(), error) {
+	unlock, ok, err := redisLock(fmt.Sprintf(conf.AISERCrondLock, j.Name), j.LockTTL)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCrondJobLocked
+	}
+
+	return unlock, nil
 }
 
-func RegisterCrond(c Crond) {
+// redisLock take the redis lock of the key for at most ttl, the returned function releases it if it is still held.
+// ok is false if the lock is held by another one
+func redisLock(key string, ttl time.Duration) (func(), bool, error) {
+	value := fmt.Sprintf("%s-%d", conf.ServerName, rand.Int63())
 
-	c.Init()
+	// a ttl under a second would be no expiry, the lock of a dead process is never released then
+	client := models.GetRdbInst()
+	ok, err := client.SetNX(key, value, max(int64(ttl/time.Second), 1))
+	if err != nil || !ok {
+		return nil, false, err
+	}
 
-	timer.DoTimer(c.GetDurationMillisecond(), c.Worker)
+	return func() {
+		if _, err := client.DelIfEqual(key, value); err != nil {
+			log.Error("", "redis lock %s unlock error %s", key, err.Error())
+		}
+	}, true, nil
 }
@@ -0,0 +1,108 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+var (
+	ErrNoEvergreenContent = fmt.Errorf("no TweetLib content available for the evergreen schedule")
+)
+
+// AddEvergreenSchedule add a schedule that picks the content from TweetLib by category every time it fires
+func AddEvergreenSchedule(req *data.TwAddEvergreenScheduleReq) (*models.TwSchedule, error) {
+	evergreenConf := &data.TwEvergreenScheduleConf{
+		Category:          req.Category,
+		QueryMode:         req.QueryMode,
+		MinRepostInterval: req.MinRepostInterval,
+		RewriteWithLLM:    req.RewriteWithLLM,
+	}
+
+	return addTwScheduleWithLibConf(req.UserId, models.TwScheduleLibTypeEvergreen, evergreenConf, &req.TwScheduleLoopReq)
+}
+
+// resolveEvergreenThread pick a TweetLib content that has not been posted by the user within the repost interval
+func resolveEvergreenThread(twSchedule *models.TwSchedule, lib *models.TwScheduleLib) (*twScheduleThread, error) {
+	evergreenConf := new(data.TwEvergreenScheduleConf)
+	if err := json.Unmarshal([]byte(lib.Content), evergreenConf); err != nil {
+		return nil, err
+	}
+
+	tweetLib, err := pickEvergreenTweetLib(twSchedule, evergreenConf)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*data.TwAddTweetScheduleReqItem, 0)
+	for i, text := range tweetLib.GetTweets() {
+		if evergreenConf.RewriteWithLLM {
+			rewritten, err := RewriteInMikoVoice(text)
+			if err != nil { // post the original text if llm is not available
+				log.Error("", "RewriteInMikoVoice() error %s, tweet lib id: %d", err.Error(), tweetLib.Id)
+			} else {
+				text = rewritten
+			}
+		}
+
+		items = append(items, &data.TwAddTweetScheduleReqItem{
+			SortId: strconv.Itoa(i + 1),
+			Text:   text,
+		})
+	}
+
+	return &twScheduleThread{Items: items, TweetLibId: int64(tweetLib.Id)}, nil
+}
+
+// saveEvergreenPostLog log the evergreen content of the thread post once it is posted,
+// it is not picked again within the repost interval
+func saveEvergreenPostLog(post *models.TwThreadPost, items []*models.TwThreadPostItem) error {
+	texts := make([]string, 0, len(items))
+	tweetIds := make([]string, 0, len(items))
+	for _, v := range items {
+		texts = append(texts, v.Text)
+		tweetIds = append(tweetIds, v.TweetId)
+	}
+	b, err := json.Marshal(texts)
+	if err != nil {
+		return err
+	}
+
+	now := tools.GetMillisecond(time.Now())
+	postLog := &models.TweetLibPostLog{
+		TweetLibId:   post.TweetLibId,
+		UserId:       post.UserId,
+		TwScheduleId: post.TwScheduleId,
+		TweetIds:     strings.Join(tweetIds, ","),
+		Content:      string(b),
+		PostedAt:     now,
+		CreatedAt:    now,
+	}
+	return postLog.Save()
+}
+
+// pickEvergreenTweetLib the TweetLib content of the category not posted by the user within the repost interval
+func pickEvergreenTweetLib(twSchedule *models.TwSchedule, evergreenConf *data.TwEvergreenScheduleConf) (*models.TweetLib, error) {
+	since := tools.GetMillisecond(time.Now().Add(-time.Duration(evergreenConf.MinRepostInterval) * time.Hour))
+	excludeIds, err := models.GetPostedTweetLibIds(twSchedule.UserId, since)
+	if err != nil {
+		return nil, err
+	}
+
+	tweetLib, err := models.GetTweetLibCandidate(evergreenConf.Category, evergreenConf.QueryMode, excludeIds)
+	if err != nil {
+		return nil, err
+	}
+	if tweetLib == nil {
+		return nil, ErrNoEvergreenContent
+	}
+
+	return tweetLib, nil
+}
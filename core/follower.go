@@ -0,0 +1,226 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/g8rswimmer/go-twitter/v2"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+// the follower pages of a large account are paced for at most this long per page
+const maxTwFollowerSnapshotWait = 15 * time.Minute
+
+// AddTwFollowerWatch watch the followers of an account, it is snapshotted by the next run of the job
+func AddTwFollowerWatch(account string) (*models.TwFollowerWatch, error) {
+	client := twitterapi.NewTwitterClient(twitterapi.AppOwner, conf.TwitterAPIToken, "", "")
+	raw, err := client.GetUserByAccount(account)
+	if err != nil {
+		return nil, fmt.Errorf("client.GetUserByAccount() error %s", err.Error())
+	}
+	if raw == nil || len(raw.Users) == 0 {
+		return nil, conf.ErrRecordNotFound
+	}
+	user := raw.Users[0]
+
+	watch, err := models.GetTwFollowerWatchByUserId(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if watch == nil {
+		watch = &models.TwFollowerWatch{
+			UserId:    user.ID,
+			CreatedAt: tools.GetMillisecond(time.Now()),
+		}
+	}
+	watch.Account = user.UserName
+	watch.Name = user.Name
+
+	return watch, watch.Update()
+}
+
+// RemoveTwFollowerWatch stop watching the followers of an account, its snapshots are kept
+func RemoveTwFollowerWatch(userId string) error {
+	watch, err := models.GetTwFollowerWatchByUserId(userId)
+	if err != nil {
+		return err
+	}
+	if watch == nil {
+		return conf.ErrRecordNotFound
+	}
+
+	return watch.Del()
+}
+
+// SnapshotTwFollowers snapshot the followers of the watched accounts due for conf.TwFollowerSnapshotInterval
+func SnapshotTwFollowers() error {
+	watches, err := models.GetAllTwFollowerWatchList()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	taken := 0
+	for _, v := range watches {
+		if now.Sub(time.UnixMilli(v.LastSnapshotAt)) < time.Duration(conf.TwFollowerSnapshotInterval)*time.Second {
+			continue
+		}
+
+		v.LastError = ""
+		if err = snapshotTwFollowers(v, now); err != nil {
+			log.Error("", "snapshotTwFollowers() userId: %s error %s", v.UserId, err.Error())
+			v.LastError = err.Error()
+		} else {
+			v.LastSnapshotAt = tools.GetMillisecond(now)
+			taken++
+		}
+		if err = v.Update(); err != nil {
+			return err
+		}
+	}
+
+	log.Info("", "SnapshotTwFollowers() done, watches: %d, taken: %d", len(watches), taken)
+	return nil
+}
+
+// snapshotTwFollowers save the followers of the watched account with the changes since the last snapshot,
+// the first snapshot is the base and has no changes
+func snapshotTwFollowers(watch *models.TwFollowerWatch, now time.Time) error {
+	twClient, owner := getTwFollowerClient(watch.UserId)
+	followers, err := twClient.GetAllFollowerList(watch.UserId, func() error {
+		return twitterapi.Wait(owner, twitterapi.EndpointUserFollowers, maxTwFollowerSnapshotWait)
+	})
+	if err != nil {
+		return fmt.Errorf("twClient.GetAllFollowerList() error %s", err.Error())
+	}
+
+	followerMap := make(map[string]*twitter.UserObj, len(followers))
+	ids := make([]string, 0, len(followers))
+	for _, v := range followers {
+		if _, ok := followerMap[v.ID]; !ok {
+			ids = append(ids, v.ID)
+		}
+		followerMap[v.ID] = v
+	}
+
+	snapshotAt := tools.GetMillisecond(now)
+	snapshot := &models.TwFollowerSnapshot{
+		UserId:     watch.UserId,
+		SnapshotAt: snapshotAt,
+		CreatedAt:  tools.GetMillisecond(time.Now()),
+	}
+	if err = snapshot.SetFollowerIds(ids); err != nil {
+		return err
+	}
+
+	last, err := models.GetLatestTwFollowerSnapshot(watch.UserId)
+	if err != nil {
+		return err
+	}
+	changes := make([]*models.TwFollowerChange, 0)
+	if last != nil {
+		lastIds, err := last.GetFollowerIds()
+		if err != nil {
+			return err
+		}
+		followed, unfollowed := diffTwFollowerIds(lastIds, ids)
+
+		for _, id := range followed {
+			changes = append(changes, newTwFollowerChange(watch.UserId, models.TwFollowerChangeTypeFollow, id, followerMap[id], snapshotAt))
+		}
+
+		// the followers gone are looked up for their names, a suspended or deleted one is kept by its id
+		unfollowerMap, err := lookupTwUnfollowers(twClient, owner, unfollowed)
+		if err != nil {
+			return err
+		}
+		for _, id := range unfollowed {
+			changes = append(changes, newTwFollowerChange(watch.UserId, models.TwFollowerChangeTypeUnfollow, id, unfollowerMap[id], snapshotAt))
+		}
+
+		snapshot.FollowCount, snapshot.UnfollowCount = len(followed), len(unfollowed)
+	}
+
+	return models.SaveTwFollowerSnapshot(snapshot, changes)
+}
+
+// getTwFollowerClient the client of the account if it is authorized, the app client otherwise, with the owner of its rate limits
+func getTwFollowerClient(userId string) (twitterapi.TwitterClient, string) {
+	twAccount, err := models.GetTwAccountByUserId(userId)
+	if err == nil && twAccount != nil {
+		if twClient, err := getTwUserClient(userId); err == nil {
+			return twClient, userId
+		}
+	}
+
+	return twitterapi.NewTwitterClient(twitterapi.AppOwner, conf.TwitterAPIToken, "", ""), twitterapi.AppOwner
+}
+
+// diffTwFollowerIds the ids followed and the ids unfollowed from the last ids to the ids
+func diffTwFollowerIds(lastIds, ids []string) (followed, unfollowed []string) {
+	lastSet := make(map[string]struct{}, len(lastIds))
+	for _, v := range lastIds {
+		lastSet[v] = struct{}{}
+	}
+	set := make(map[string]struct{}, len(ids))
+	for _, v := range ids {
+		set[v] = struct{}{}
+		if _, ok := lastSet[v]; !ok {
+			followed = append(followed, v)
+		}
+	}
+	for _, v := range lastIds {
+		if _, ok := set[v]; !ok {
+			unfollowed = append(unfollowed, v)
+		}
+	}
+
+	return followed, unfollowed
+}
+
+func lookupTwUnfollowers(twClient twitterapi.TwitterClient, owner string, ids []string) (map[string]*twitter.UserObj, error) {
+	results := make(map[string]*twitter.UserObj)
+	for i := 0; i < len(ids); i += maxUserLookupIds {
+		batch := ids[i:min(i+maxUserLookupIds, len(ids))]
+		if err := twitterapi.Wait(owner, twitterapi.EndpointUsersLookup, maxTwFollowerSnapshotWait); err != nil {
+			return nil, fmt.Errorf("twitterapi.Wait() error %s", err.Error())
+		}
+
+		raw, err := twClient.GetFollowerCount(batch)
+		if err != nil {
+			return nil, fmt.Errorf("twClient.GetFollowerCount() error %s", err.Error())
+		}
+		if raw == nil {
+			continue
+		}
+		for _, v := range raw.Users {
+			results[v.ID] = v
+		}
+	}
+
+	return results, nil
+}
+
+func newTwFollowerChange(userId string, changeType int, followerId string, follower *twitter.UserObj, snapshotAt int64) *models.TwFollowerChange {
+	change := &models.TwFollowerChange{
+		UserId:     userId,
+		Type:       changeType,
+		FollowerId: followerId,
+		SnapshotAt: snapshotAt,
+		CreatedAt:  tools.GetMillisecond(time.Now()),
+	}
+	if follower != nil {
+		change.FollowerAccount = follower.UserName
+		change.FollowerName = follower.Name
+		if follower.PublicMetrics != nil {
+			change.FollowerCount = follower.PublicMetrics.Followers
+		}
+	}
+	change.Notable = changeType == models.TwFollowerChangeTypeFollow && change.FollowerCount >= conf.TwNotableFollowerCount
+
+	return change
+}
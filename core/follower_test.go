@@ -0,0 +1,25 @@
+package core
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDiffTwFollowerIds(t *testing.T) {
+	cases := []struct {
+		name                 string
+		lastIds, ids         []string
+		followed, unfollowed []string
+	}{
+		{"first snapshot", nil, []string{"1", "2"}, []string{"1", "2"}, nil},
+		{"no change", []string{"1", "2"}, []string{"2", "1"}, nil, nil},
+		{"follow and unfollow", []string{"1", "2", "3"}, []string{"4", "2", "5"}, []string{"4", "5"}, []string{"1", "3"}},
+		{"all unfollowed", []string{"1"}, nil, nil, []string{"1"}},
+	}
+	for _, c := range cases {
+		followed, unfollowed := diffTwFollowerIds(c.lastIds, c.ids)
+		if !slices.Equal(followed, c.followed) || !slices.Equal(unfollowed, c.unfollowed) {
+			t.Fatalf("%s: followed %v, unfollowed %v, want %v and %v", c.name, followed, unfollowed, c.followed, c.unfollowed)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/sdk/chatgptapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	mikoGeneratePrompt = `Write a tweet following the instruction given by the user. ` +
+		`Reply with the tweet text only, no quotes, no explanations, at most %d characters.`
+
+	promptMetricsDays = 7
+)
+
+type ErrTweetFlagged struct {
+	Categories []string
+}
+
+func (e *ErrTweetFlagged) Error() string {
+	return fmt.Sprintf("generated tweet is flagged by moderation, categories: %s", strings.Join(e.Categories, ","))
+}
+
+// ErrInvalidBackupText the backup text of a generated schedule can not be posted
+type ErrInvalidBackupText struct {
+	Reason string
+}
+
+func (e *ErrInvalidBackupText) Error() string {
+	return fmt.Sprintf("invalid backup_text, %s", e.Reason)
+}
+
+// promptContext the data a prompt template is rendered with
+type promptContext struct {
+	Date     string
+	Weekday  string
+	Metrics  *promptMetrics
+	TweetLib []string
+}
+
+type promptMetrics struct {
+	Days          int
+	TweetCount    int
+	LikeCount     int
+	ReplyCount    int
+	RetweetCount  int
+	FollowerCount int
+}
+
+// AddGeneratedSchedule add a schedule that generates the tweet from the prompt template every time it fires
+func AddGeneratedSchedule(req *data.TwAddGeneratedScheduleReq) (*models.TwSchedule, error) {
+	for _, v := range req.ContextSources {
+		if _, ok := data.AllowTwPromptContext[v]; !ok {
+			return nil, fmt.Errorf("invalid context source %s", v)
+		}
+		if v == data.TwPromptContextTweetLib && len(req.Category) == 0 {
+			return nil, fmt.Errorf("category is required by the context source %s", v)
+		}
+	}
+	if req.QueryMode == 0 {
+		req.QueryMode = models.TweetLibQueryModeRandom
+	}
+	if _, ok := models.AllowTweetLibQueryMode[req.QueryMode]; !ok {
+		return nil, fmt.Errorf("invalid query_mode %d", req.QueryMode)
+	}
+
+	if _, err := template.New("prompt").Parse(req.PromptTemplate); err != nil {
+		return nil, err
+	}
+
+	if err := checkBackupText(req.BackupText); err != nil {
+		return nil, err
+	}
+
+	generatedConf := &data.TwGeneratedScheduleConf{
+		PromptTemplate: req.PromptTemplate,
+		ContextSources: req.ContextSources,
+		Category:       req.Category,
+		QueryMode:      req.QueryMode,
+		BackupText:     req.BackupText,
+	}
+
+	return addTwScheduleWithLibConf(req.UserId, models.TwScheduleLibTypeGenerated, generatedConf, &req.TwScheduleLoopReq)
+}
+
+// checkBackupText check the backup text like a generated tweet, it is posted without the checks when the generation fails
+func checkBackupText(text string) error {
+	if len(text) == 0 {
+		return nil
+	}
+	if l := TweetWeightedLength(text); l > MaxTweetWeightedLength {
+		return &ErrInvalidBackupText{Reason: fmt.Sprintf("weighted length %d is over %d", l, MaxTweetWeightedLength)}
+	}
+
+	flagged, categories, err := chatgptapi.Moderate(text)
+	if err != nil {
+		return fmt.Errorf("chatgptapi.Moderate() error %s", err.Error())
+	}
+	if flagged {
+		return &ErrInvalidBackupText{Reason: fmt.Sprintf("flagged by moderation, categories: %s", strings.Join(categories, ","))}
+	}
+
+	return nil
+}
+
+// resolveGeneratedThread generate a fresh tweet by the prompt, the backup text is used if the generation fails
+func resolveGeneratedThread(twSchedule *models.TwSchedule, lib *models.TwScheduleLib) (*twScheduleThread, error) {
+	generatedConf := new(data.TwGeneratedScheduleConf)
+	if err := json.Unmarshal([]byte(lib.Content), generatedConf); err != nil {
+		return nil, err
+	}
+
+	text, err := generateTweet(twSchedule.UserId, generatedConf)
+	if err != nil {
+		log.Error("", "generateTweet() error %s, schedule id: %d", err.Error(), twSchedule.Id)
+		if len(generatedConf.BackupText) == 0 {
+			return nil, err
+		}
+		text = generatedConf.BackupText
+	}
+
+	items := []*data.TwAddTweetScheduleReqItem{
+		{
+			SortId: "1",
+			Text:   text,
+		},
+	}
+
+	return &twScheduleThread{Items: items}, nil
+}
+
+func generateTweet(userId string, generatedConf *data.TwGeneratedScheduleConf) (string, error) {
+	ctx, err := buildPromptContext(userId, generatedConf)
+	if err != nil {
+		return "", fmt.Errorf("buildPromptContext() error %s", err.Error())
+	}
+
+	tpl, err := template.New("prompt").Parse(generatedConf.PromptTemplate)
+	if err != nil {
+		return "", err
+	}
+	buf := new(bytes.Buffer)
+	if err = tpl.Execute(buf, ctx); err != nil {
+		return "", err
+	}
+
+	systemPrompt := mikoPersonaPrompt + "\n" + fmt.Sprintf(mikoGeneratePrompt, MaxTweetWeightedLength)
+	text, err := chatgptapi.SendChatGPTRequest(systemPrompt, buf.String())
+	if err != nil {
+		return "", fmt.Errorf("chatgptapi.SendChatGPTRequest() error %s", err.Error())
+	}
+
+	text = strings.TrimSpace(text)
+	if len(text) == 0 {
+		return "", fmt.Errorf("generated tweet is empty")
+	}
+	if TweetWeightedLength(text) > MaxTweetWeightedLength {
+		return "", fmt.Errorf("generated tweet is too long, weighted length %d", TweetWeightedLength(text))
+	}
+
+	flagged, categories, err := chatgptapi.Moderate(text)
+	if err != nil {
+		return "", fmt.Errorf("chatgptapi.Moderate() error %s", err.Error())
+	}
+	if flagged {
+		return "", &ErrTweetFlagged{Categories: categories}
+	}
+
+	return text, nil
+}
+
+func buildPromptContext(userId string, generatedConf *data.TwGeneratedScheduleConf) (*promptContext, error) {
+	now := time.Now()
+	ctx := new(promptContext)
+	for _, source := range generatedConf.ContextSources {
+		switch source {
+		case data.TwPromptContextDate:
+			ctx.Date = now.Format("2006-01-02")
+			ctx.Weekday = now.Weekday().String()
+		case data.TwPromptContextMetrics:
+			start := tools.GetMillisecond(now.AddDate(0, 0, -promptMetricsDays))
+			list, err := models.GetListByUserIds([]string{userId}, start, tools.GetMillisecond(now))
+			if err != nil {
+				return nil, err
+			}
+
+			metrics := &promptMetrics{Days: promptMetricsDays}
+			var lastStatisticAt int64
+			for _, v := range list {
+				metrics.TweetCount += v.TweetCount
+				metrics.LikeCount += v.LikeCount
+				metrics.ReplyCount += v.ReplyCount
+				metrics.RetweetCount += v.RetweetCount
+				if v.StatisticAt > lastStatisticAt {
+					lastStatisticAt = v.StatisticAt
+					metrics.FollowerCount = v.FollowerCount
+				}
+			}
+			ctx.Metrics = metrics
+		case data.TwPromptContextTweetLib:
+			tweetLib, err := models.GetTweetLibCandidate(generatedConf.Category, generatedConf.QueryMode, nil)
+			if err != nil {
+				return nil, err
+			}
+			if tweetLib != nil {
+				ctx.TweetLib = tweetLib.GetTweets()
+			}
+		}
+	}
+
+	return ctx, nil
+}
@@ -0,0 +1,33 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/project-miko/miko/sdk/chatgptapi"
+)
+
+const (
+	mikoPersonaPrompt = `You are Miko, a charming and mischievous little witch who always appears by the reader's side with a touch of mystery and playfulness. ` +
+		`You are a chat companion, a thoughtful life assistant, a savvy financial advisor and the magical spark of creativity. ` +
+		`Every word you say is like a spell that resonates with the reader's heart.`
+
+	mikoRewritePrompt = `Rewrite the tweet given by the user in your own voice. Keep its meaning, facts and links. ` +
+		`Reply with the tweet text only, no quotes, no explanations, at most %d characters.`
+)
+
+// RewriteInMikoVoice rewrite the tweet text in Miko's voice, the original text is returned if the rewrite is too long
+func RewriteInMikoVoice(text string) (string, error) {
+	systemPrompt := mikoPersonaPrompt + "\n" + fmt.Sprintf(mikoRewritePrompt, MaxTweetWeightedLength)
+	result, err := chatgptapi.SendChatGPTRequest(systemPrompt, text)
+	if err != nil {
+		return "", err
+	}
+
+	result = strings.TrimSpace(result)
+	if len(result) == 0 || TweetWeightedLength(result) > MaxTweetWeightedLength {
+		return text, nil
+	}
+
+	return result, nil
+}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	// a media id expiring within the margin is not attached, the media is uploaded again
+	twMediaExpireMargin = 10 * time.Minute
+	// twitter keeps a media id for a day if the response does not say
+	defaultTwMediaExpire = 24 * time.Hour
+	// the processing is given up after it
+	maxTwMediaProcessingTime = time.Hour
+	// the fire waits for the processing in place at most this long, it is deferred to the next check otherwise
+	maxTwMediaInlineWait = 15 * time.Second
+)
+
+// ErrTwMediaProcessing the media of the thread is still processing, the fire should run again at CheckAfterAt
+type ErrTwMediaProcessing struct {
+	CheckAfterAt int64
+}
+
+func (e *ErrTwMediaProcessing) Error() string {
+	return fmt.Sprintf("the media is processing, check after %d", e.CheckAfterAt)
+}
+
+type ErrTwMediaFailed struct {
+	Media *models.TwMedia
+}
+
+func (e *ErrTwMediaFailed) Error() string {
+	return fmt.Sprintf("the media %s failed, %s", e.Media.MediaUrl, e.Media.ErrMsg)
+}
+
+// prepareTwThreadMedia get the media of the thread items ready to attach, seq -> media ids,
+// ErrTwMediaProcessing is returned if some media is not processed within maxTwMediaInlineWait
+func prepareTwThreadMedia(twClient twitterapi.TwitterClient, userId string, items []*models.TwThreadPostItem) (map[int][]string, error) {
+	type itemMedia struct {
+		seq   int
+		media *models.TwMedia
+	}
+
+	medias := make([]*itemMedia, 0)
+	for _, item := range items {
+		altTexts := item.GetMediaAltTexts()
+		for i, mediaUrl := range item.GetMediaUrls() {
+			altText := ""
+			if i < len(altTexts) {
+				altText = altTexts[i]
+			}
+			media, err := prepareTwMedia(twClient, userId, mediaUrl, altText)
+			if err != nil {
+				return nil, err
+			}
+			medias = append(medias, &itemMedia{seq: item.Seq, media: media})
+		}
+	}
+
+	deadline := time.Now().Add(maxTwMediaInlineWait)
+	for {
+		var checkAfterAt int64
+		for _, v := range medias {
+			if err := pollTwMedia(twClient, v.media); err != nil {
+				return nil, err
+			}
+
+			switch v.media.State {
+			case models.TwMediaStateFailed:
+				return nil, &ErrTwMediaFailed{Media: v.media}
+			case models.TwMediaStateProcessing:
+				if checkAfterAt == 0 || v.media.CheckAfterAt < checkAfterAt {
+					checkAfterAt = v.media.CheckAfterAt
+				}
+			}
+		}
+		if checkAfterAt == 0 {
+			break
+		}
+
+		checkAfter := time.UnixMilli(checkAfterAt)
+		if checkAfter.After(deadline) {
+			return nil, &ErrTwMediaProcessing{CheckAfterAt: checkAfterAt}
+		}
+		time.Sleep(time.Until(checkAfter))
+	}
+
+	mediaIds := make(map[int][]string)
+	for _, v := range medias {
+		mediaIds[v.seq] = append(mediaIds[v.seq], v.media.MediaId)
+	}
+
+	return mediaIds, nil
+}
+
+// prepareTwMedia get the upload of the media url, the last one is reused if it has not failed or expired
+func prepareTwMedia(twClient twitterapi.TwitterClient, userId, mediaUrl, altText string) (*models.TwMedia, error) {
+	media, err := models.GetLatestTwMedia(userId, mediaUrl, altText)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if media != nil && (media.State == models.TwMediaStateProcessing || media.State == models.TwMediaStateReady) &&
+		media.ExpiresAt > tools.GetMillisecond(now.Add(twMediaExpireMargin)) {
+		return media, nil
+	}
+
+	// an upload left in the uploading state by a worker stopped halfway is superseded
+	media = &models.TwMedia{
+		UserId:    userId,
+		MediaUrl:  mediaUrl,
+		AltText:   altText,
+		State:     models.TwMediaStateUploading,
+		CreatedAt: tools.GetMillisecond(now),
+	}
+	if err = media.Save(); err != nil {
+		return nil, err
+	}
+
+	resp, err := twClient.UploadMediaFromUrl(mediaUrl, altText)
+	if err != nil {
+		log.Error("", "twClient.UploadMediaFromUrl() error %s", err.Error())
+		media.State = models.TwMediaStateFailed
+		media.ErrMsg = err.Error()
+		return media, media.Update()
+	}
+
+	media.MediaId = resp.MediaIDString
+	media.Category = resp.Category
+	media.UploadedAt = tools.GetMillisecond(time.Now())
+	applyTwMediaData(media, resp)
+
+	return media, media.Update()
+}
+
+// pollTwMedia check the processing of the media once its check time is reached
+func pollTwMedia(twClient twitterapi.TwitterClient, media *models.TwMedia) error {
+	now := time.Now()
+	if media.State != models.TwMediaStateProcessing || media.CheckAfterAt > tools.GetMillisecond(now) {
+		return nil
+	}
+
+	if now.Sub(time.UnixMilli(media.UploadedAt)) > maxTwMediaProcessingTime {
+		media.State = models.TwMediaStateFailed
+		media.ErrMsg = fmt.Sprintf("the processing does not finish in %s", maxTwMediaProcessingTime)
+		return media.Update()
+	}
+
+	resp, err := twClient.GetMediaUploadStatus(media.MediaId)
+	if err != nil {
+		var apiErr *anaconda.ApiError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound { // the media needs no processing
+			media.State = models.TwMediaStateReady
+			return media.Update()
+		}
+		return fmt.Errorf("twClient.GetMediaUploadStatus() error %w", err)
+	}
+
+	applyTwMediaData(media, resp)
+	return media.Update()
+}
+
+// applyTwMediaData update the state and the expiry of the media by the upload or the status response
+func applyTwMediaData(media *models.TwMedia, resp *twitterapi.MediaData) {
+	now := time.Now()
+	if resp.ExpiresAfterSecs > 0 {
+		media.ExpiresAt = tools.GetMillisecond(now.Add(time.Duration(resp.ExpiresAfterSecs) * time.Second))
+	} else if media.ExpiresAt == 0 {
+		media.ExpiresAt = tools.GetMillisecond(now.Add(defaultTwMediaExpire))
+	}
+
+	pInfo := resp.ProcessingInfo
+	switch {
+	case pInfo == nil || pInfo.State == twitterapi.UploadMediaSucceeded:
+		media.State = models.TwMediaStateReady
+	case pInfo.State == twitterapi.UploadMediaFailed:
+		media.State = models.TwMediaStateFailed
+		media.ErrMsg = "the processing failed"
+		if pInfo.Error != nil {
+			media.ErrMsg = pInfo.Error.Error()
+		}
+	default:
+		media.State = models.TwMediaStateProcessing
+		media.CheckAfterAt = tools.GetMillisecond(now.Add(time.Duration(max(pInfo.CheckAfterSecs, 1)) * time.Second))
+	}
+}
+
+// handleTwScheduleMediaProcessing defer the fire of the schedule to the next check of the media processing
+func handleTwScheduleMediaProcessing(twSchedule *models.TwSchedule, processingErr *ErrTwMediaProcessing) error {
+	deferred, err := consumeTwScheduleFire(twSchedule, processingErr.CheckAfterAt)
+	if err != nil {
+		return err
+	}
+
+	if !deferred {
+		if e := onTwPostQueueScheduleDone(twSchedule, processingErr); e != nil {
+			log.Error("", "onTwPostQueueScheduleDone() error %s", e.Error())
+		}
+	}
+
+	return processingErr
+}
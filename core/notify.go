@@ -0,0 +1,36 @@
+package core
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/tools/log"
+	"github.com/project-miko/miko/tools/netutils"
+)
+
+// NotifyAdmins alert the admins, the message is posted to the webhook as well if it is configured
+func NotifyAdmins(title, content string) {
+	log.Alert("", "%s: %s", title, content)
+
+	webhookUrl := conf.GetConfigString("notify", "webhook_url")
+	if webhookUrl == "" {
+		return
+	}
+
+	b, err := json.Marshal(map[string]string{
+		"title":   title,
+		"content": content,
+	})
+	if err != nil {
+		log.Error("", "json.Marshal() error %s", err.Error())
+		return
+	}
+
+	req := netutils.NewHttpRequest(webhookUrl)
+	_ = req.SetMethod("POST")
+	req.SetBodyBytes(b, "application/json")
+	if _, _, err = req.Exec(10 * time.Second); err != nil {
+		log.Error("", "notify admins by webhook error %s", err.Error())
+	}
+}
@@ -0,0 +1,174 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+// savePostedTweet record the posted item of the thread post and schedule its metric snapshots,
+// threadId is the id of the first tweet of the thread
+func savePostedTweet(post *models.TwThreadPost, account, threadId, inReplyToTweetId string, item *models.TwThreadPostItem) error {
+	source := post.Source
+	if source == "" {
+		source = models.PostedTweetSourceManual
+	}
+
+	postedTweet := &models.PostedTweet{
+		UserId:           post.UserId,
+		Account:          account,
+		Source:           source,
+		SourceId:         post.TwScheduleId,
+		TwThreadPostId:   post.Id,
+		ThreadId:         threadId,
+		Seq:              item.Seq,
+		TweetId:          item.TweetId,
+		InReplyToTweetId: inReplyToTweetId,
+		Text:             item.Text,
+		MediaIds:         item.MediaIds,
+		Status:           models.PostedTweetStatusPosted,
+		PostedAt:         item.PostedAt,
+		CreatedAt:        tools.GetMillisecond(time.Now()),
+	}
+
+	if err := postedTweet.Save(); err != nil {
+		return err
+	}
+
+	var twScheduleLibId int64
+	if post.TwScheduleId > 0 {
+		twSchedule, err := models.GetTwScheduleById(post.TwScheduleId, -1)
+		if err != nil {
+			return err
+		}
+		if twSchedule != nil {
+			twScheduleLibId = twSchedule.TwScheduleLibId
+		}
+	}
+
+	return scheduleTwTweetMetrics(postedTweet, twScheduleLibId)
+}
+
+// markPostedTweetDeleted mark the posted tweet deleted, a tweet not recorded is skipped
+func markPostedTweetDeleted(tweetId string) error {
+	postedTweet, err := models.GetPostedTweetByTweetId(tweetId)
+	if err != nil {
+		return err
+	}
+	if postedTweet == nil || postedTweet.Status == models.PostedTweetStatusDeleted {
+		return nil
+	}
+
+	postedTweet.Status = models.PostedTweetStatusDeleted
+	postedTweet.DeletedAt = tools.GetMillisecond(time.Now())
+	return postedTweet.Update()
+}
+
+// DeletePostedTweet delete a posted tweet, the replies to it are kept
+func DeletePostedTweet(tweetId string) (*models.PostedTweet, error) {
+	postedTweet, err := models.GetPostedTweetByTweetId(tweetId)
+	if err != nil {
+		return nil, err
+	}
+	if postedTweet == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	if err = deletePostedTweets([]*models.PostedTweet{postedTweet}); err != nil {
+		return nil, err
+	}
+
+	return postedTweet, nil
+}
+
+// DeletePostedThread delete the tweets of a posted thread, the last one first,
+// a delete failed halfway can be run again
+func DeletePostedThread(threadId string) ([]*models.PostedTweet, error) {
+	list, err := models.GetPostedTweetListByThreadId(threadId)
+	if err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	if err = deletePostedTweets(list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func deletePostedTweets(list []*models.PostedTweet) error {
+	for i := len(list) - 1; i >= 0; i-- {
+		v := list[i]
+		if v.Status == models.PostedTweetStatusDeleted {
+			continue
+		}
+
+		twClient, err := getTwUserClient(v.UserId)
+		if err != nil {
+			return err
+		}
+
+		// a tweet deleted already is reported not deleted, it is gone either way
+		if _, err = twClient.DeleteTweet(v.TweetId); err != nil {
+			return fmt.Errorf("twClient.DeleteTweet() tweetId: %s error %s", v.TweetId, err.Error())
+		}
+		log.Info("", "delete tweet success, userId:%s, tweetId:%s", v.UserId, v.TweetId)
+
+		v.Status = models.PostedTweetStatusDeleted
+		v.DeletedAt = tools.GetMillisecond(time.Now())
+		if err = v.Update(); err != nil {
+			return err
+		}
+		if err = markTwThreadPostItemDeleted(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// markTwThreadPostItemDeleted mark the item of the deleted tweet deleted in its thread post like a rollback does,
+// a thread post failed halfway or with no tweet left is rolled back, it is not resumed as a reply to a deleted tweet
+func markTwThreadPostItemDeleted(postedTweet *models.PostedTweet) error {
+	if postedTweet.TwThreadPostId == 0 {
+		return nil
+	}
+
+	post, items, err := getTwThreadPostDetail(postedTweet.TwThreadPostId)
+	if err == conf.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	postedCount := 0
+	for _, v := range items {
+		if v.Status != models.TwThreadPostItemStatusPosted {
+			continue
+		}
+		if v.TweetId != postedTweet.TweetId {
+			postedCount++
+			continue
+		}
+
+		v.Status = models.TwThreadPostItemStatusDeleted
+		if err = v.Update(); err != nil {
+			return err
+		}
+	}
+
+	post.PostedCount = postedCount
+	if post.Status == models.TwThreadPostStatusPartial || (post.Status == models.TwThreadPostStatusPosted && postedCount == 0) {
+		post.Status = models.TwThreadPostStatusRolledBack
+	}
+
+	return post.Update()
+}
@@ -0,0 +1,537 @@
+package core
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	// a slot must be at least this far in the future to be filled, leaves time to upload media
+	postQueueSlotLeadTime = 1 * time.Minute
+	// a slot passed this long ago may still be starting its fire
+	postQueueFireGraceTime = 10 * time.Second
+)
+
+var (
+	// postQueueLocker serializes the changes of the posting queues
+	postQueueLocker sync.Mutex
+	// firingTwPostQueueTags the job tags of the schedules whose fire is running, guarded by postQueueLocker
+	firingTwPostQueueTags = make(map[string]struct{})
+
+	ErrPostQueueItemNotQueued = fmt.Errorf("the queue item is not queued")
+	ErrPostQueueOrderMismatch = fmt.Errorf("the queue ids do not match the queued items")
+)
+
+func InitTwPostQueues() error {
+	log.Info("", "init twitter posting queues start")
+
+	userIds, err := models.GetTwPostQueueUserIds()
+	if err != nil {
+		return err
+	}
+
+	// slots missed while the service was down are given to the next free slots
+	for _, userId := range userIds {
+		if err = RefreshTwPostQueue(userId); err != nil {
+			return fmt.Errorf("RefreshTwPostQueue(%s) error %s", userId, err.Error())
+		}
+	}
+
+	log.Info("", "init twitter posting queues success. length of queues :%d", len(userIds))
+	return nil
+}
+
+func GetTwPostingSlots(userId string) ([]*data.TwPostingSlotItem, error) {
+	slots, err := models.GetTwPostingSlotList(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*data.TwPostingSlotItem, 0)
+	for _, v := range slots {
+		items = append(items, &data.TwPostingSlotItem{
+			WeekDay: v.WeekDay,
+			Hour:    v.Hour,
+			Minute:  v.Minute,
+		})
+	}
+
+	return items, nil
+}
+
+// SetTwPostingSlots replace the weekly posting slots of the account and move the queued items to the new slots
+func SetTwPostingSlots(userId string, items []*data.TwPostingSlotItem) error {
+	slots := make([]*models.TwPostingSlot, 0)
+	exists := make(map[string]struct{})
+	for _, v := range items {
+		k := fmt.Sprintf("%d-%d-%d", v.WeekDay, v.Hour, v.Minute)
+		if _, ok := exists[k]; ok {
+			continue
+		}
+		exists[k] = struct{}{}
+
+		slots = append(slots, &models.TwPostingSlot{
+			WeekDay: v.WeekDay,
+			Hour:    v.Hour,
+			Minute:  v.Minute,
+		})
+	}
+
+	if err := models.ReplaceTwPostingSlots(userId, slots); err != nil {
+		return err
+	}
+
+	return RefreshTwPostQueue(userId)
+}
+
+// AddTwPostQueueItem append the thread to the end of the account's queue, an exists schedule lib is copied
+func AddTwPostQueueItem(userId string, twScheduleLibId *int64, threadList []*data.TwAddTweetScheduleReqItem) (*models.TwPostQueue, error) {
+	var lib *models.TwScheduleLib
+	var err error
+	if twScheduleLibId != nil {
+		lib, err = copyTwScheduleLib(*twScheduleLibId)
+	} else {
+		lib, err = createTwScheduleLib(threadList)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	postQueueLocker.Lock()
+	list, err := models.GetTwPostQueueList(userId, models.TwPostQueueStatusQueued)
+	if err != nil {
+		postQueueLocker.Unlock()
+		return nil, err
+	}
+
+	position := 1
+	if len(list) != 0 {
+		position = list[len(list)-1].Position + 1
+	}
+
+	item := &models.TwPostQueue{
+		UserId:          userId,
+		TwScheduleLibId: lib.Id,
+		Position:        position,
+		Status:          models.TwPostQueueStatusQueued,
+		CreatedAt:       tools.GetMillisecond(time.Now()),
+	}
+	err = item.Save()
+	postQueueLocker.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = RefreshTwPostQueue(userId); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func GetTwPostQueue(userId string) ([]*data.TwPostQueueItemResp, error) {
+	list, err := models.GetTwPostQueueList(userId, models.TwPostQueueStatusQueued)
+	if err != nil {
+		return nil, err
+	}
+
+	libIds := make([]int64, 0)
+	for _, v := range list {
+		libIds = append(libIds, v.TwScheduleLibId)
+	}
+
+	libs, err := models.GetTwScheduleListByIds(libIds)
+	if err != nil {
+		return nil, err
+	}
+	libMap := make(map[int64]*models.TwScheduleLib)
+	for _, v := range libs {
+		libMap[v.Id] = v
+	}
+
+	results := make([]*data.TwPostQueueItemResp, 0)
+	for _, v := range list {
+		item := &data.TwPostQueueItemResp{
+			Id:              v.Id,
+			TwScheduleLibId: v.TwScheduleLibId,
+			Position:        v.Position,
+			SlotAt:          v.SlotAt,
+			CreatedAt:       v.CreatedAt,
+		}
+		if lib, ok := libMap[v.TwScheduleLibId]; ok {
+			if item.ThreadList, err = getTwScheduleLibThreadList(lib); err != nil {
+				return nil, err
+			}
+		}
+		results = append(results, item)
+	}
+
+	return results, nil
+}
+
+// ReorderTwPostQueue queueIds must contain every queued item of the account, in the new order
+func ReorderTwPostQueue(userId string, queueIds []int64) error {
+	postQueueLocker.Lock()
+	list, err := models.GetTwPostQueueList(userId, models.TwPostQueueStatusQueued)
+	if err != nil {
+		postQueueLocker.Unlock()
+		return err
+	}
+
+	if len(list) != len(queueIds) {
+		postQueueLocker.Unlock()
+		return ErrPostQueueOrderMismatch
+	}
+
+	itemMap := make(map[int64]*models.TwPostQueue)
+	for _, v := range list {
+		itemMap[v.Id] = v
+	}
+
+	ordered := make([]*models.TwPostQueue, 0)
+	for _, id := range queueIds {
+		item, ok := itemMap[id]
+		if !ok {
+			postQueueLocker.Unlock()
+			return ErrPostQueueOrderMismatch
+		}
+		delete(itemMap, id)
+		ordered = append(ordered, item)
+	}
+
+	err = saveTwPostQueuePositions(ordered)
+	postQueueLocker.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return RefreshTwPostQueue(userId)
+}
+
+// PostNextTwPostQueue move the item to the head of the queue, it takes the next free slot
+func PostNextTwPostQueue(userId string, queueId int64) error {
+	postQueueLocker.Lock()
+	list, err := models.GetTwPostQueueList(userId, models.TwPostQueueStatusQueued)
+	if err != nil {
+		postQueueLocker.Unlock()
+		return err
+	}
+
+	ordered := make([]*models.TwPostQueue, 0)
+	for _, v := range list {
+		if v.Id == queueId {
+			ordered = append([]*models.TwPostQueue{v}, ordered...)
+			continue
+		}
+		ordered = append(ordered, v)
+	}
+	if len(ordered) == 0 || ordered[0].Id != queueId {
+		postQueueLocker.Unlock()
+		return ErrPostQueueItemNotQueued
+	}
+
+	err = saveTwPostQueuePositions(ordered)
+	postQueueLocker.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return RefreshTwPostQueue(userId)
+}
+
+func ShuffleTwPostQueue(userId string) error {
+	postQueueLocker.Lock()
+	list, err := models.GetTwPostQueueList(userId, models.TwPostQueueStatusQueued)
+	if err != nil {
+		postQueueLocker.Unlock()
+		return err
+	}
+
+	rand.Shuffle(len(list), func(i, j int) {
+		list[i], list[j] = list[j], list[i]
+	})
+
+	err = saveTwPostQueuePositions(list)
+	postQueueLocker.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return RefreshTwPostQueue(userId)
+}
+
+func RemoveTwPostQueueItem(userId string, queueId int64) error {
+	postQueueLocker.Lock()
+	item, err := models.GetTwPostQueueById(queueId)
+	if err != nil {
+		postQueueLocker.Unlock()
+		return err
+	}
+	if item == nil || item.UserId != userId || item.Status != models.TwPostQueueStatusQueued {
+		postQueueLocker.Unlock()
+		return ErrPostQueueItemNotQueued
+	}
+
+	err = releaseTwPostQueueSchedule(item)
+	if err == nil {
+		item.Status = models.TwPostQueueStatusDeleted
+		err = item.Update()
+	}
+	postQueueLocker.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return RefreshTwPostQueue(userId)
+}
+
+// RefreshTwPostQueue assign the next free posting slots to the queued items in order,
+// every assigned slot is materialized into a one-shot schedule
+func RefreshTwPostQueue(userId string) error {
+	postQueueLocker.Lock()
+	defer postQueueLocker.Unlock()
+
+	list, err := models.GetTwPostQueueList(userId, models.TwPostQueueStatusQueued)
+	if err != nil {
+		return err
+	}
+
+	slots, err := models.GetTwPostingSlotList(userId)
+	if err != nil {
+		return err
+	}
+
+	// the items firing or about to fire keep their slots, moving them would post them twice
+	now := time.Now().In(conf.NewTimeZone)
+	pinnedSlots := make(map[int64]struct{})
+	unpinned := make([]*models.TwPostQueue, 0, len(list))
+	for _, item := range list {
+		pinned, err := isTwPostQueueItemPinned(item, now)
+		if err != nil {
+			return err
+		}
+		if pinned {
+			pinnedSlots[item.SlotAt] = struct{}{}
+			continue
+		}
+		unpinned = append(unpinned, item)
+	}
+
+	slotTimes := make([]time.Time, 0, len(unpinned))
+	for _, t := range nextPostingSlotTimes(slots, now.Add(postQueueSlotLeadTime), len(unpinned)+len(pinnedSlots)) {
+		if _, ok := pinnedSlots[tools.GetMillisecond(t)]; !ok {
+			slotTimes = append(slotTimes, t)
+		}
+	}
+
+	for i, item := range unpinned {
+		if i >= len(slotTimes) { // no slot configured
+			if err = releaseTwPostQueueSchedule(item); err != nil {
+				return err
+			}
+			if err = item.Update(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err = materializeTwPostQueueItem(item, slotTimes[i]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isTwPostQueueItemPinned an item is pinned to its slot while the fire of its schedule is running,
+// due within the lead time or deferred by the last fire
+func isTwPostQueueItemPinned(item *models.TwPostQueue, now time.Time) (bool, error) {
+	if item.TwScheduleId == 0 {
+		return false, nil
+	}
+	if _, ok := firingTwPostQueueTags[GetTag(item.UserId, item.TwScheduleLibId)]; ok {
+		return true, nil
+	}
+
+	slotAt := time.UnixMilli(item.SlotAt)
+	if slotAt.After(now.Add(-postQueueFireGraceTime)) && !slotAt.After(now.Add(postQueueSlotLeadTime)) {
+		return true, nil
+	}
+
+	twSchedule, err := models.GetTwScheduleById(item.TwScheduleId, models.TwScheduleStatusUnFinished)
+	if err != nil {
+		return false, err
+	}
+
+	return twSchedule != nil && twSchedule.DeferredRunAt > 0, nil
+}
+
+// setTwPostQueueFiring mark the fire of the job running or done, the queue item of a running fire keeps its slot
+func setTwPostQueueFiring(tag string, firing bool) {
+	postQueueLocker.Lock()
+	defer postQueueLocker.Unlock()
+
+	if firing {
+		firingTwPostQueueTags[tag] = struct{}{}
+	} else {
+		delete(firingTwPostQueueTags, tag)
+	}
+}
+
+// materializeTwPostQueueItem create or move the one-shot schedule of the item to the slot
+func materializeTwPostQueueItem(item *models.TwPostQueue, slotAt time.Time) error {
+	slotAtMs := tools.GetMillisecond(slotAt)
+	if item.TwScheduleId != 0 && item.SlotAt == slotAtMs {
+		return nil
+	}
+
+	var twSchedule *models.TwSchedule
+	var err error
+	if item.TwScheduleId != 0 {
+		twSchedule, err = models.GetTwScheduleById(item.TwScheduleId, models.TwScheduleStatusUnFinished)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := tools.GetMillisecond(time.Now())
+	if twSchedule == nil {
+		twSchedule = &models.TwSchedule{
+			UserId:          item.UserId,
+			TwScheduleLibId: item.TwScheduleLibId,
+			TotalCount:      1,
+			RemainCount:     1,
+			Status:          models.TwScheduleStatusUnFinished,
+			CreatedAt:       now,
+		}
+	} else if err = removeTwCreateTweetJob(twSchedule); err != nil {
+		return err
+	}
+
+	twSchedule.CronExpression = oneShotCronExp(slotAt)
+	twSchedule.NextRunAt = slotAtMs
+	if err = twSchedule.Update(); err != nil {
+		return err
+	}
+
+	if _, err = addTwCreateTweetJob(twSchedule); err != nil {
+		return fmt.Errorf("addTwCreateTweetJob() error %s", err.Error())
+	}
+
+	item.TwScheduleId = twSchedule.Id
+	item.SlotAt = slotAtMs
+	return item.Update()
+}
+
+// releaseTwPostQueueSchedule remove the one-shot schedule of the item, the item keeps its place in the queue
+func releaseTwPostQueueSchedule(item *models.TwPostQueue) error {
+	if item.TwScheduleId == 0 {
+		return nil
+	}
+
+	twSchedule, err := models.GetTwScheduleById(item.TwScheduleId, models.TwScheduleStatusUnFinished)
+	if err != nil {
+		return err
+	}
+	if twSchedule != nil {
+		if err = removeTwCreateTweetJob(twSchedule); err != nil {
+			return err
+		}
+		twSchedule.Status = models.TwScheduleStatusDeleted
+		if err = twSchedule.Update(); err != nil {
+			return err
+		}
+	}
+
+	item.TwScheduleId = 0
+	item.SlotAt = 0
+	return nil
+}
+
+// onTwPostQueueScheduleDone mark the queue item of the schedule as posted or failed once its slot has fired
+func onTwPostQueueScheduleDone(twSchedule *models.TwSchedule, jobErr error) error {
+	postQueueLocker.Lock()
+	defer postQueueLocker.Unlock()
+
+	item, err := models.GetTwPostQueueByScheduleId(twSchedule.Id)
+	if err != nil {
+		return err
+	}
+	if item == nil || item.Status != models.TwPostQueueStatusQueued {
+		return nil
+	}
+
+	item.Status = models.TwPostQueueStatusPosted
+	if jobErr != nil {
+		item.Status = models.TwPostQueueStatusError
+	}
+
+	return item.Update()
+}
+
+func saveTwPostQueuePositions(ordered []*models.TwPostQueue) error {
+	for i, v := range ordered {
+		if v.Position == i+1 {
+			continue
+		}
+		v.Position = i + 1
+		if err := v.Update(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// nextPostingSlotTimes get the first n slot times after from, week_day 1-7 means Monday to Sunday
+func nextPostingSlotTimes(slots []*models.TwPostingSlot, from time.Time, n int) []time.Time {
+	results := make([]time.Time, 0)
+	if len(slots) == 0 || n == 0 {
+		return results
+	}
+
+	// one more week to cover the slots of the current week that have passed
+	weeks := n/len(slots) + 2
+	dayStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for d := 0; d < weeks*7; d++ {
+		day := dayStart.AddDate(0, 0, d)
+		weekDay := int(day.Weekday())
+		if weekDay == 0 {
+			weekDay = 7
+		}
+
+		for _, v := range slots {
+			if v.WeekDay != weekDay {
+				continue
+			}
+			t := time.Date(day.Year(), day.Month(), day.Day(), v.Hour, v.Minute, 0, 0, day.Location())
+			if t.After(from) {
+				results = append(results, t)
+			}
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Before(results[j])
+	})
+	if len(results) > n {
+		results = results[:n]
+	}
+
+	return results
+}
+
+// oneShotCronExp cron expression with seconds that only matches t, it is added with a run limit of 1
+func oneShotCronExp(t time.Time) string {
+	t = t.In(conf.NewTimeZone)
+	return fmt.Sprintf("0 %d %d %d %d *", t.Minute(), t.Hour(), t.Day(), int(t.Month()))
+}
@@ -0,0 +1,46 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/project-miko/miko/models"
+)
+
+func TestNextPostingSlotTimes(t *testing.T) {
+	// a wednesday
+	from := time.Date(2026, 10, 21, 10, 0, 0, 0, time.UTC)
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC)
+	}
+	slots := []*models.TwPostingSlot{
+		{WeekDay: 1, Hour: 9},
+		{WeekDay: 3, Hour: 12},
+		{WeekDay: 3, Hour: 9},
+		{WeekDay: 7, Hour: 23},
+	}
+
+	cases := []struct {
+		name  string
+		slots []*models.TwPostingSlot
+		n     int
+		want  []time.Time
+	}{
+		{"no slot", nil, 3, nil},
+		{"none asked", slots, 0, nil},
+		{"the passed slot of today is next week", slots, 5, []time.Time{at(21, 12), at(25, 23), at(26, 9), at(28, 9), at(28, 12)}},
+		{"more than a week of slots", slots[:1], 3, []time.Time{at(26, 9), at(26, 9).AddDate(0, 0, 7), at(26, 9).AddDate(0, 0, 14)}},
+		{"a slot at from is not after it", []*models.TwPostingSlot{{WeekDay: 3, Hour: 10}}, 1, []time.Time{at(28, 10)}},
+	}
+	for _, c := range cases {
+		got := nextPostingSlotTimes(c.slots, from, c.n)
+		if len(got) != len(c.want) {
+			t.Fatalf("%s: %d slot times %v, want %v", c.name, len(got), got, c.want)
+		}
+		for i := range got {
+			if !got[i].Equal(c.want[i]) {
+				t.Fatalf("%s: slot time %d is %s, want %s", c.name, i, got[i], c.want[i])
+			}
+		}
+	}
+}
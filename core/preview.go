@@ -0,0 +1,243 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/mediautils"
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	defaultPreviewLimit = 10
+	maxMediaPerTweet    = 4
+)
+
+var (
+	// same parser as gocron CronWithSeconds
+	cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+)
+
+// PreviewSchedule get the next run times of a cron expression or a schedule, and the thread the schedule would post, nothing is posted
+func PreviewSchedule(req *data.TwPreviewScheduleReq) (*data.TwPreviewScheduleResp, error) {
+	loc := time.UTC
+	if len(req.DisplayTimeZone) != 0 {
+		var err error
+		loc, err = time.LoadLocation(req.DisplayTimeZone)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPreviewLimit
+	}
+
+	cronExp := req.CronExp
+	var nextRunAt int64 = -1
+	var twSchedule *models.TwSchedule
+	if req.ScheduleId > 0 {
+		var err error
+		twSchedule, err = models.GetTwScheduleById(req.ScheduleId, -1)
+		if err != nil {
+			return nil, err
+		}
+		if twSchedule == nil {
+			return nil, conf.ErrRecordNotFound
+		}
+
+		cronExp = twSchedule.CronExpression
+		nextRunAt = twSchedule.NextRunAt
+		if twSchedule.RemainCount < limit {
+			limit = twSchedule.RemainCount
+		}
+		if twSchedule.Status != models.TwScheduleStatusUnFinished {
+			limit = 0
+		}
+	}
+
+	runTimes, err := NextScheduleRunTimes(cronExp, nextRunAt, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &data.TwPreviewScheduleResp{
+		CronExp:         cronExp,
+		DisplayTimeZone: loc.String(),
+		RunTimes:        make([]*data.TwPreviewRunTimeItem, 0),
+	}
+	for _, v := range runTimes {
+		resp.RunTimes = append(resp.RunTimes, &data.TwPreviewRunTimeItem{
+			RunAt: tools.GetMillisecond(v),
+			Time:  v.In(loc).Format(time.RFC3339),
+		})
+	}
+
+	if twSchedule != nil {
+		thread, err := previewTwScheduleThread(twSchedule)
+		if err != nil {
+			resp.ThreadErrMsg = err.Error()
+		}
+		resp.Thread = thread
+	}
+
+	return resp, nil
+}
+
+// NextScheduleRunTimes the next n run times of the cron expression the way the scheduler runs it, nextRunAt is the saved next run time of a looping schedule, -1 if it is new
+func NextScheduleRunTimes(cronExp string, nextRunAt int64, n int) ([]time.Time, error) {
+	now := time.Now().In(conf.NewTimeZone)
+	results := make([]time.Time, 0)
+
+	if strings.Contains(cronExp, "/") {
+		params, err := ParseCronExpInterval(cronExp)
+		if err != nil {
+			return nil, err
+		}
+		if params.LoopUnit <= 0 {
+			return nil, fmt.Errorf("invalid cron expression %s", cronExp)
+		}
+
+		runAt := firstLoopRunAt(params)
+		if nextRunAt > 0 {
+			runAt = time.UnixMilli(nextRunAt).In(conf.NewTimeZone)
+		}
+		interval := time.Duration(params.LoopUnit) * 7 * 24 * time.Hour
+		for runAt.Before(now) {
+			runAt = runAt.Add(interval)
+		}
+		for i := 0; i < n; i++ {
+			results = append(results, runAt)
+			runAt = runAt.Add(interval)
+		}
+
+		return results, nil
+	}
+
+	schedule, err := cronParser.Parse(cronExp)
+	if err != nil {
+		return nil, err
+	}
+
+	runAt := now
+	for i := 0; i < n; i++ {
+		runAt = schedule.Next(runAt)
+		if runAt.IsZero() {
+			break
+		}
+		results = append(results, runAt)
+	}
+
+	return results, nil
+}
+
+// previewTwScheduleThread resolve the thread of the schedule like doUploadTwMediaAndCreateTweet without calling the llm,
+// the media are downloaded and checked but not uploaded
+func previewTwScheduleThread(twSchedule *models.TwSchedule) ([]*data.TwPreviewThreadItem, error) {
+	lib, err := models.GetTwScheduleLibById(twSchedule.TwScheduleLibId)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	items, generatedAtFire, err := previewTwScheduleThreadItems(twSchedule, lib)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		a, _ := strconv.Atoi(items[i].SortId)
+		b, _ := strconv.Atoi(items[j].SortId)
+		return a < b
+	})
+
+	results := make([]*data.TwPreviewThreadItem, 0)
+	for _, item := range items {
+		length := TweetWeightedLength(item.Text)
+		previewItem := &data.TwPreviewThreadItem{
+			SortId:          item.SortId,
+			Text:            item.Text,
+			WeightedLength:  length,
+			OverLength:      length > MaxTweetWeightedLength,
+			GeneratedAtFire: generatedAtFire,
+			Medias:          make([]*data.TwPreviewMediaItem, 0),
+		}
+
+		for i, mediaUrl := range item.MediaUrls {
+			media := previewMedia(mediaUrl)
+			if i >= maxMediaPerTweet {
+				media.Valid = false
+				media.ErrMsg = fmt.Sprintf("a tweet can have at most %d media", maxMediaPerTweet)
+			}
+			previewItem.Medias = append(previewItem.Medias, media)
+		}
+
+		results = append(results, previewItem)
+	}
+
+	return results, nil
+}
+
+// previewTwScheduleThreadItems the prompt of a generated schedule, the evergreen candidate before its rewrite,
+// or the thread of the lib, generatedAtFire if the posted text is generated when the schedule fires
+func previewTwScheduleThreadItems(twSchedule *models.TwSchedule, lib *models.TwScheduleLib) ([]*data.TwAddTweetScheduleReqItem, bool, error) {
+	switch lib.Type {
+	case models.TwScheduleLibTypeGenerated:
+		generatedConf := new(data.TwGeneratedScheduleConf)
+		if err := json.Unmarshal([]byte(lib.Content), generatedConf); err != nil {
+			return nil, false, err
+		}
+		return []*data.TwAddTweetScheduleReqItem{{SortId: "1", Text: generatedConf.PromptTemplate}}, true, nil
+	case models.TwScheduleLibTypeEvergreen:
+		evergreenConf := new(data.TwEvergreenScheduleConf)
+		if err := json.Unmarshal([]byte(lib.Content), evergreenConf); err != nil {
+			return nil, false, err
+		}
+		tweetLib, err := pickEvergreenTweetLib(twSchedule, evergreenConf)
+		if err != nil {
+			return nil, false, err
+		}
+		items := make([]*data.TwAddTweetScheduleReqItem, 0)
+		for i, text := range tweetLib.GetTweets() {
+			items = append(items, &data.TwAddTweetScheduleReqItem{SortId: strconv.Itoa(i + 1), Text: text})
+		}
+		return items, evergreenConf.RewriteWithLLM, nil
+	default:
+		thread, err := resolveTwScheduleThread(twSchedule, lib)
+		if err != nil {
+			return nil, false, err
+		}
+		return thread.Items, false, nil
+	}
+}
+
+func previewMedia(mediaUrl string) *data.TwPreviewMediaItem {
+	media := &data.TwPreviewMediaItem{
+		MediaUrl: mediaUrl,
+	}
+
+	f, err := mediautils.DownloadFileFromURL(mediaUrl, twitterapi.GetMediaMaxSize)
+	if err != nil {
+		media.ErrMsg = err.Error()
+		return media
+	}
+	defer f.Remove()
+
+	media.ContentType = f.MimeType
+	media.Size = int(f.Size)
+	media.Category = twitterapi.GetMediaCategory(f.MimeType)
+	media.Valid = true
+	return media
+}
@@ -0,0 +1,247 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	// the run times of a schedule projected at most
+	maxQuotaProjectRuns = 1000
+)
+
+type ErrQuotaExceeded struct {
+	Reason   string
+	Deferred bool
+	DeferTo  int64
+}
+
+func (e *ErrQuotaExceeded) Error() string {
+	if e.Deferred {
+		return fmt.Sprintf("fire is deferred to %d, %s", e.DeferTo, e.Reason)
+	}
+	return fmt.Sprintf("fire is skipped, %s", e.Reason)
+}
+
+// getTwUserPostQuota the 24 hours post cap of the user, the configured cap is used until a response reports it,
+// reset is 0 then
+func getTwUserPostQuota(userId string) (limit, remaining int, reset int64, err error) {
+	info, err := GetUserRateLimit(userId)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	if info == nil {
+		return conf.TwUser24HourPostLimit, conf.TwUser24HourPostLimit, 0, nil
+	}
+
+	return info.Limit, info.Remaining, info.Reset * 1000, nil
+}
+
+func twAppMonthlyPostsKey(t time.Time) string {
+	return fmt.Sprintf(conf.AISERTwAppMonthlyPosts, t.UTC().Format("200601"))
+}
+
+// getTwAppMonthlyPosts the posts of the app in the current month
+func getTwAppMonthlyPosts() (int, error) {
+	str, err := models.GetRdbInst().GetString(twAppMonthlyPostsKey(time.Now()))
+	if err == redis.ErrNil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(str)
+}
+
+// countTwAppMonthlyPost count a post of the app in the current month
+func countTwAppMonthlyPost() {
+	// kept a little longer than the longest month
+	if _, err := models.GetRdbInst().IncrWithExpire(twAppMonthlyPostsKey(time.Now()), 32*24*3600); err != nil {
+		log.Error("", "countTwAppMonthlyPost() error %s", err.Error())
+	}
+}
+
+// twScheduleLibTweetCount the tweets a fire of the lib posts, the evergreen and generated libs are counted as 1
+func twScheduleLibTweetCount(lib *models.TwScheduleLib) int {
+	if lib.Type == models.TwScheduleLibTypeEvergreen || lib.Type == models.TwScheduleLibTypeGenerated {
+		return 1
+	}
+
+	items := make([]*data.TwAddTweetScheduleReqItem, 0)
+	if err := json.Unmarshal([]byte(lib.Content), &items); err != nil {
+		return 1
+	}
+	return max(len(items), 1)
+}
+
+// projectTwSchedulePosts the posts of the schedules before until
+func projectTwSchedulePosts(schedules []*models.TwSchedule, until time.Time) (int, error) {
+	libIds := make([]int64, 0)
+	for _, v := range schedules {
+		libIds = append(libIds, v.TwScheduleLibId)
+	}
+	libs, err := models.GetTwScheduleListByIds(libIds)
+	if err != nil {
+		return 0, err
+	}
+	libMap := make(map[int64]*models.TwScheduleLib)
+	for _, v := range libs {
+		libMap[v.Id] = v
+	}
+
+	total := 0
+	for _, v := range schedules {
+		lib, ok := libMap[v.TwScheduleLibId]
+		if !ok {
+			continue
+		}
+
+		runTimes, err := NextScheduleRunTimes(v.CronExpression, v.NextRunAt, min(v.RemainCount, maxQuotaProjectRuns))
+		if err != nil {
+			log.Error("", "NextScheduleRunTimes() schedule id: %d error %s", v.Id, err.Error())
+			continue
+		}
+
+		runs := 0
+		for _, t := range runTimes {
+			if t.After(until) {
+				break
+			}
+			runs++
+		}
+		if v.DeferredRunAt > 0 && time.UnixMilli(v.DeferredRunAt).Before(until) {
+			runs++
+		}
+		total += runs * twScheduleLibTweetCount(lib)
+	}
+
+	return total, nil
+}
+
+// CheckTwPostQuota project the posts of the schedules against the 24 hours cap of the account and the monthly cap of the app,
+// the plan is infeasible if a cap would be exceeded
+func CheckTwPostQuota(userId string) (*data.TwPostQuotaResp, error) {
+	now := time.Now()
+	resp := &data.TwPostQuotaResp{
+		UserId:          userId,
+		AppMonthlyLimit: conf.TwAppMonthlyPostLimit,
+		Feasible:        true,
+		Warnings:        make([]string, 0),
+	}
+
+	var err error
+	resp.UserLimit, resp.UserRemaining, resp.UserReset, err = getTwUserPostQuota(userId)
+	if err != nil {
+		return nil, fmt.Errorf("getTwUserPostQuota() error %s", err.Error())
+	}
+
+	userSchedules, err := models.GetTwScheduleListByUserId(userId, models.TwScheduleStatusUnFinished)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Projected24Hour, err = projectTwSchedulePosts(userSchedules, now.Add(24*time.Hour)); err != nil {
+		return nil, err
+	}
+	if resp.Projected24Hour > resp.UserLimit {
+		resp.Feasible = false
+		resp.Warnings = append(resp.Warnings, fmt.Sprintf("%d posts are projected in the next 24 hours, the cap is %d", resp.Projected24Hour, resp.UserLimit))
+	}
+	if resp.UserReset > 0 {
+		if resp.ProjectedBeforeReset, err = projectTwSchedulePosts(userSchedules, time.UnixMilli(resp.UserReset)); err != nil {
+			return nil, err
+		}
+		if resp.ProjectedBeforeReset > resp.UserRemaining {
+			resp.Feasible = false
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf("%d posts are projected before the cap resets, %d are left", resp.ProjectedBeforeReset, resp.UserRemaining))
+		}
+	}
+
+	if resp.AppMonthlyUsed, err = getTwAppMonthlyPosts(); err != nil {
+		return nil, fmt.Errorf("getTwAppMonthlyPosts() error %s", err.Error())
+	}
+	if resp.AppMonthlyLimit > 0 {
+		allSchedules, err := models.GetAllTwScheduleList(models.TwScheduleStatusUnFinished)
+		if err != nil {
+			return nil, err
+		}
+		utcNow := now.UTC()
+		monthEnd := time.Date(utcNow.Year(), utcNow.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		if resp.AppMonthlyProjected, err = projectTwSchedulePosts(allSchedules, monthEnd); err != nil {
+			return nil, err
+		}
+		if resp.AppMonthlyUsed+resp.AppMonthlyProjected > resp.AppMonthlyLimit {
+			resp.Feasible = false
+			resp.Warnings = append(resp.Warnings, fmt.Sprintf("%d posts are used and %d are projected this month, the app cap is %d",
+				resp.AppMonthlyUsed, resp.AppMonthlyProjected, resp.AppMonthlyLimit))
+		}
+	}
+
+	if !resp.Feasible {
+		log.Warning("", "post plan of user %s is infeasible: %v", userId, resp.Warnings)
+	}
+
+	return resp, nil
+}
+
+// checkTwFireQuota check the fire of the schedule fits in the caps, a fire over the 24 hours cap of the account is deferred
+// to the reset, a fire over the monthly cap of the app is skipped
+func checkTwFireQuota(twSchedule *models.TwSchedule) (*ErrQuotaExceeded, error) {
+	lib, err := models.GetTwScheduleLibById(twSchedule.TwScheduleLibId)
+	if err != nil {
+		return nil, err
+	}
+	if lib == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+	tweetCount := twScheduleLibTweetCount(lib)
+
+	if conf.TwAppMonthlyPostLimit > 0 {
+		used, err := getTwAppMonthlyPosts()
+		if err != nil {
+			return nil, fmt.Errorf("getTwAppMonthlyPosts() error %s", err.Error())
+		}
+		if used+tweetCount > conf.TwAppMonthlyPostLimit {
+			return &ErrQuotaExceeded{Reason: fmt.Sprintf("%d of the %d monthly posts of the app are used", used, conf.TwAppMonthlyPostLimit)}, nil
+		}
+	}
+
+	_, remaining, reset, err := getTwUserPostQuota(twSchedule.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("getTwUserPostQuota() error %s", err.Error())
+	}
+	if reset > 0 && remaining < tweetCount {
+		return &ErrQuotaExceeded{
+			Reason:  fmt.Sprintf("%d posts are left in the 24 hours cap, the thread has %d", remaining, tweetCount),
+			DeferTo: reset,
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// handleTwScheduleQuotaExceeded consume the fire of the schedule over the caps like a blackout window
+func handleTwScheduleQuotaExceeded(twSchedule *models.TwSchedule, quotaErr *ErrQuotaExceeded) error {
+	deferred, err := consumeTwScheduleFire(twSchedule, quotaErr.DeferTo)
+	if err != nil {
+		return err
+	}
+
+	quotaErr.Deferred = deferred
+	if !deferred {
+		if e := onTwPostQueueScheduleDone(twSchedule, quotaErr); e != nil {
+			log.Error("", "onTwPostQueueScheduleDone() error %s", e.Error())
+		}
+	}
+
+	NotifyAdmins("post quota exceeded", fmt.Sprintf("schedule %d of user %s: %s", twSchedule.Id, twSchedule.UserId, quotaErr.Error()))
+	return quotaErr
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"time"
+
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/sdk/twitterapi"
+)
+
+// a rate limit is low when less than this ratio is left
+const twRateLimitLowRatio = 0.1
+
+// GetTwRateLimitList list the rate limits tracked for an owner, all owners if empty, the ones close to exhaustion first
+func GetTwRateLimitList(owner string) ([]*data.TwRateLimitItem, error) {
+	list, err := twitterapi.GetRateLimitList(owner)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	items := make([]*data.TwRateLimitItem, 0, len(list))
+	for _, v := range list {
+		items = append(items, &data.TwRateLimitItem{
+			Owner:          v.Owner,
+			Endpoint:       v.Endpoint,
+			Limit:          v.Limit,
+			Remaining:      v.Remaining,
+			Reset:          v.Reset,
+			UpdatedAt:      v.UpdatedAt,
+			Exhausted:      v.Remaining <= 0,
+			Low:            float64(v.Remaining) < float64(v.Limit)*twRateLimitLowRatio,
+			ProjectedEmpty: v.ProjectExhaustAt(now),
+		})
+	}
+
+	return items, nil
+}
+
+// GetUserRateLimit get the 24 hours post cap of a user, nil if no response has reported it in the current window
+func GetUserRateLimit(userId string) (*twitterapi.RateLimitInfo, error) {
+	return twitterapi.GetRateLimit(userId, twitterapi.EndpointUser24Hour)
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/project-miko/miko/tools"
+)
+
+const TwThreadBackendRSSHub = "rsshub"
+
+var (
+	rssHubImgReg   = regexp.MustCompile(`<img[^>]+src="([^"]+)"`)
+	rssHubVideoReg = regexp.MustCompile(`<video[^>]+src="([^"]+)"(?:[^>]+poster="([^"]+)")?`)
+	rssHubBrReg    = regexp.MustCompile(`(?i)<br\s*/?>`)
+	rssHubTagReg   = regexp.MustCompile(`<[^>]+>`)
+)
+
+// RSSHubThreadBackend fetch the thread from the tweet route of an RSSHub instance, the items of the feed are the tweets
+type RSSHubThreadBackend struct {
+	Host   string
+	Client *http.Client
+}
+
+type rssHubFeed struct {
+	Channel struct {
+		Title string `xml:"title"`
+		Image struct {
+			Url string `xml:"url"`
+		} `xml:"image"`
+		Items []*rssHubItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssHubItem struct {
+	Title       string `xml:"title"`
+	Description string `xml:"description"`
+	Link        string `xml:"link"`
+	PubDate     string `xml:"pubDate"`
+}
+
+func NewRSSHubThreadBackend(host string) *RSSHubThreadBackend {
+	return &RSSHubThreadBackend{
+		Host:   strings.TrimRight(host, "/"),
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (b *RSSHubThreadBackend) Name() string {
+	return TwThreadBackendRSSHub
+}
+
+func (b *RSSHubThreadBackend) GetThread(account, tweetId string) (*GetTweetResp, error) {
+	feedUrl := fmt.Sprintf("%s%s/%s/status/%s", b.Host, RouteRSSHubTweetDetail, url.PathEscape(account), tweetId)
+	resp, err := b.Client.Get(feedUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rsshub responds %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	feed := new(rssHubFeed)
+	if err = xml.Unmarshal(body, feed); err != nil {
+		return nil, err
+	}
+
+	result := &GetTweetResp{
+		Account:        account,
+		Name:           feed.Channel.Title,
+		Avatar:         feed.Channel.Image.Url,
+		ConversationId: tweetId,
+		Tweets:         make([]string, 0),
+		Items:          make([]*GetTweetItem, 0),
+	}
+	for _, v := range feed.Channel.Items {
+		_, id, err := ParseTweetUrl(v.Link)
+		if err != nil {
+			continue
+		}
+		item := &GetTweetItem{
+			TweetId: id,
+			Text:    rssHubText(v.Description),
+			Media:   rssHubMedia(v.Description),
+		}
+		if pubDate, err := time.Parse(time.RFC1123, v.PubDate); err == nil {
+			item.CreatedAt = tools.GetMillisecond(pubDate)
+		}
+		result.Items = append(result.Items, item)
+	}
+	if len(result.Items) == 0 {
+		return nil, ErrTweetNotFound
+	}
+
+	sort.Slice(result.Items, func(i, j int) bool { return compareTweetId(result.Items[i].TweetId, result.Items[j].TweetId) < 0 })
+	for _, v := range result.Items {
+		result.Tweets = append(result.Tweets, v.Text)
+	}
+	result.PublishAt = result.Items[0].CreatedAt
+
+	return result, nil
+}
+
+// rssHubText the text of the description html, the media are dropped
+func rssHubText(description string) string {
+	text := rssHubBrReg.ReplaceAllString(description, "\n")
+	text = rssHubTagReg.ReplaceAllString(text, "")
+	return strings.TrimSpace(html.UnescapeString(text))
+}
+
+func rssHubMedia(description string) []*GetTweetMedia {
+	media := make([]*GetTweetMedia, 0)
+	for _, m := range rssHubVideoReg.FindAllStringSubmatch(description, -1) {
+		media = append(media, &GetTweetMedia{Type: "video", Url: html.UnescapeString(m[1]), PreviewUrl: html.UnescapeString(m[2])})
+	}
+	posters := make(map[string]bool)
+	for _, v := range media {
+		posters[v.PreviewUrl] = true
+	}
+	for _, m := range rssHubImgReg.FindAllStringSubmatch(description, -1) {
+		if src := html.UnescapeString(m[1]); !posters[src] {
+			media = append(media, &GetTweetMedia{Type: "photo", Url: src})
+		}
+	}
+	return media
+}
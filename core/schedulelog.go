@@ -0,0 +1,183 @@
+package core
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+)
+
+const (
+	defaultScheduleLogDays = 7
+	// error messages longer than this are cut when grouping, they usually end with ids or urls
+	maxGroupedErrorLength = 120
+)
+
+// scheduleLogRange the time range of the request, the last 7 days by default
+func scheduleLogRange(startAt, endAt int64) (int64, int64) {
+	now := time.Now()
+	if endAt <= 0 {
+		endAt = tools.GetMillisecond(now)
+	}
+	if startAt <= 0 {
+		startAt = tools.GetMillisecond(now.AddDate(0, 0, -defaultScheduleLogDays))
+	}
+
+	return startAt, endAt
+}
+
+func GetScheduleLogList(req *data.TwGetScheduleLogListReq, page, limit int64) (int64, []*data.TwScheduleLogItemResp, error) {
+	startAt, endAt := scheduleLogRange(req.StartAt, req.EndAt)
+	amount, list, err := models.GetScheduleLogList(req.UserId, req.ScheduleId, req.Status, startAt, endAt, page, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	results := make([]*data.TwScheduleLogItemResp, 0)
+	for _, v := range list {
+		tweetIds := make([]string, 0)
+		if len(v.TweetIds) != 0 {
+			tweetIds = strings.Split(v.TweetIds, ",")
+		}
+
+		results = append(results, &data.TwScheduleLogItemResp{
+			Id:           v.Id,
+			JobId:        v.JobId,
+			UserId:       v.UserId,
+			TwScheduleId: v.TwScheduleId,
+			TweetIds:     tweetIds,
+			ExecDuration: v.ExecDuration,
+			Status:       v.Status,
+			ErrorMsg:     v.ErrorMsg,
+			ExecAt:       v.ExecAt,
+		})
+	}
+
+	return amount, results, nil
+}
+
+// scheduleExecStatus the status of a fire by its error, the skipped, deferred and canceled fires are not failures
+func scheduleExecStatus(err error) int {
+	var blackoutErr *ErrBlackout
+	var quotaErr *ErrQuotaExceeded
+	var processingErr *ErrTwMediaProcessing
+	var heldErr *ErrTwThreadPostHeld
+	switch {
+	case err == nil:
+		return models.ScheduleExecStatusSuccess
+	case errors.As(err, &blackoutErr):
+		if blackoutErr.Deferred {
+			return models.ScheduleExecStatusDeferred
+		}
+		return models.ScheduleExecStatusSkipped
+	case errors.As(err, &quotaErr):
+		if quotaErr.Deferred {
+			return models.ScheduleExecStatusDeferred
+		}
+		return models.ScheduleExecStatusSkipped
+	case errors.As(err, &processingErr), errors.As(err, &heldErr):
+		return models.ScheduleExecStatusDeferred
+	case errors.Is(err, ErrEmergencyStop), errors.Is(err, ErrTwScheduleFiring), errors.Is(err, ErrTwThreadPostClaimed),
+		errors.Is(err, twitterapi.ErrRateLimitWaitStopped):
+		return models.ScheduleExecStatusSkipped
+	case errors.Is(err, ErrTwThreadPostUndone):
+		return models.ScheduleExecStatusCanceled
+	default:
+		return models.ScheduleExecStatusFail
+	}
+}
+
+// GetScheduleStats the success rate, duration percentiles and most common error of the executions per account,
+// the skipped, deferred and canceled fires are counted apart and left out of the rest
+func GetScheduleStats(req *data.TwGetScheduleStatsReq) ([]*data.TwScheduleStatsItem, error) {
+	startAt, endAt := scheduleLogRange(req.StartAt, req.EndAt)
+	list, err := models.GetScheduleLogStatList(req.UserId, startAt, endAt)
+	if err != nil {
+		return nil, err
+	}
+
+	logMap := make(map[string][]*models.ScheduleLog)
+	for _, v := range list {
+		logMap[v.UserId] = append(logMap[v.UserId], v)
+	}
+
+	results := make([]*data.TwScheduleStatsItem, 0)
+	for userId, logs := range logMap {
+		results = append(results, scheduleStats(userId, logs))
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].UserId < results[j].UserId
+	})
+
+	return results, nil
+}
+
+func scheduleStats(userId string, logs []*models.ScheduleLog) *data.TwScheduleStatsItem {
+	item := &data.TwScheduleStatsItem{
+		UserId: userId,
+		Total:  len(logs),
+	}
+
+	durations := make([]int, 0, len(logs))
+	errCount := make(map[string]int)
+	for _, v := range logs {
+		switch v.Status {
+		case models.ScheduleExecStatusSkipped:
+			item.SkippedCount++
+			continue
+		case models.ScheduleExecStatusDeferred:
+			item.DeferredCount++
+			continue
+		case models.ScheduleExecStatusCanceled:
+			item.CanceledCount++
+			continue
+		}
+
+		durations = append(durations, v.ExecDuration)
+		if v.Status == models.ScheduleExecStatusSuccess {
+			item.SuccessCount++
+			continue
+		}
+
+		item.FailCount++
+		errMsg := []rune(v.ErrorMsg)
+		if len(errMsg) > maxGroupedErrorLength {
+			errMsg = errMsg[:maxGroupedErrorLength]
+		}
+		errCount[string(errMsg)]++
+	}
+
+	for errMsg, count := range errCount {
+		if count > item.MostCommonErrorCount || (count == item.MostCommonErrorCount && errMsg < item.MostCommonError) {
+			item.MostCommonError = errMsg
+			item.MostCommonErrorCount = count
+		}
+	}
+
+	sort.Ints(durations)
+	item.SuccessRate = Div(item.SuccessCount, item.SuccessCount+item.FailCount)
+	item.P50Duration = percentile(durations, 50)
+	item.P95Duration = percentile(durations, 95)
+
+	return item
+}
+
+// percentile nearest-rank percentile of the sorted values
+func percentile(sorted []int, p int) int {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+
+	return sorted[rank-1]
+}
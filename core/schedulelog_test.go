@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+)
+
+func TestPercentile(t *testing.T) {
+	cases := []struct {
+		sorted []int
+		p      int
+		want   int
+	}{
+		{nil, 50, 0},
+		{[]int{7}, 95, 7},
+		{[]int{1, 2, 3, 4}, 50, 2},
+		{[]int{1, 2, 3, 4}, 0, 1},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 95, 10},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 50, 5},
+		{[]int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, 100, 10},
+	}
+	for _, c := range cases {
+		if got := percentile(c.sorted, c.p); got != c.want {
+			t.Fatalf("p%d of %v is %d, want %d", c.p, c.sorted, got, c.want)
+		}
+	}
+}
+
+func TestScheduleStats(t *testing.T) {
+	logs := []*models.ScheduleLog{
+		{Status: models.ScheduleExecStatusSuccess, ExecDuration: 100},
+		{Status: models.ScheduleExecStatusSuccess, ExecDuration: 300},
+		{Status: models.ScheduleExecStatusFail, ExecDuration: 200, ErrorMsg: "b error"},
+		{Status: models.ScheduleExecStatusFail, ExecDuration: 400, ErrorMsg: "a error"},
+		{Status: models.ScheduleExecStatusSkipped, ExecDuration: 5000},
+		{Status: models.ScheduleExecStatusDeferred},
+		{Status: models.ScheduleExecStatusCanceled},
+	}
+
+	item := scheduleStats("miko", logs)
+	if item.Total != 7 || item.SuccessCount != 2 || item.FailCount != 2 ||
+		item.SkippedCount != 1 || item.DeferredCount != 1 || item.CanceledCount != 1 {
+		t.Fatalf("unexpected counts %+v", item)
+	}
+	// the skipped, deferred and canceled fires are left out of the rate and the durations
+	if item.SuccessRate.String() != "50" || item.P50Duration != 200 || item.P95Duration != 400 {
+		t.Fatalf("success rate %s, p50 %d, p95 %d", item.SuccessRate, item.P50Duration, item.P95Duration)
+	}
+	// a tie of the errors goes to the first one in order
+	if item.MostCommonError != "a error" || item.MostCommonErrorCount != 1 {
+		t.Fatalf("most common error %q of %d", item.MostCommonError, item.MostCommonErrorCount)
+	}
+}
+
+func TestScheduleExecStatus(t *testing.T) {
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{nil, models.ScheduleExecStatusSuccess},
+		{&ErrTwMediaProcessing{}, models.ScheduleExecStatusDeferred},
+		{&ErrTwThreadPostHeld{}, models.ScheduleExecStatusDeferred},
+		{ErrEmergencyStop, models.ScheduleExecStatusSkipped},
+		{twitterapi.ErrRateLimitWaitStopped, models.ScheduleExecStatusSkipped},
+		{ErrTwThreadPostUndone, models.ScheduleExecStatusCanceled},
+		{errors.New("post error"), models.ScheduleExecStatusFail},
+	}
+	for _, c := range cases {
+		if got := scheduleExecStatus(c.err); got != c.want {
+			t.Fatalf("status of %v is %d, want %d", c.err, got, c.want)
+		}
+	}
+}
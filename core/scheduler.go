@@ -4,13 +4,18 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron"
 	"github.com/project-miko/miko/conf"
 )
 
-var scheduler *Scheduler
+var (
+	scheduler *Scheduler
+	// jobLocker guards the job function and params of the scheduler while adding a job
+	jobLocker sync.Mutex
+)
 
 var (
 	ErrScheduleJobExists = fmt.Errorf("schedule job exists")
@@ -36,6 +41,19 @@ func InitScheduler() {
 	if err := InitTwCreateTweetJobs(); err != nil {
 		panic(err)
 	}
+
+	if err := InitTwPostQueues(); err != nil {
+		panic(err)
+	}
+}
+
+// StopScheduler stop firing jobs and wait for the running ones to finish
+func StopScheduler() {
+	if scheduler == nil {
+		return
+	}
+
+	scheduler.Scheduler.Stop()
 }
 
 func (s *Scheduler) SetJobFuncAndParams(jobFun interface{}, params ...interface{}) {
@@ -75,18 +93,7 @@ func (s *Scheduler) AddWithTime(cronExp string, tag string, limit int, nextRunAt
 
 	_nextRunAt := time.UnixMilli(nextRunAt).In(conf.NewTimeZone)
 	if nextRunAt == -1 {
-		now := time.Now().In(conf.NewTimeZone)
-		weekDay := params.WeekDay
-		if weekDay == 0 {
-			weekDay = 7
-		}
-
-		nextRunDay := weekDay - int(now.Weekday())
-		if isBeforeNow(params.WeekDay, params.Hour, params.Minute) {
-			nextRunDay = nextRunDay + 7
-		}
-
-		_nextRunAt = time.Date(now.Year(), now.Month(), now.Day()+nextRunDay, params.Hour, params.Minute, 0, 0, now.Location())
+		_nextRunAt = firstLoopRunAt(params)
 	}
 
 	interval := time.Duration(params.LoopUnit) * 7 * 24 * time.Hour
@@ -98,6 +105,22 @@ func (s *Scheduler) AddWithTime(cronExp string, tag string, limit int, nextRunAt
 	return j, nil
 }
 
+// firstLoopRunAt the first run time of a new looping schedule
+func firstLoopRunAt(params *CronExpParam) time.Time {
+	now := time.Now().In(conf.NewTimeZone)
+	weekDay := params.WeekDay
+	if weekDay == 0 {
+		weekDay = 7
+	}
+
+	nextRunDay := weekDay - int(now.Weekday())
+	if isBeforeNow(params.WeekDay, params.Hour, params.Minute) {
+		nextRunDay = nextRunDay + 7
+	}
+
+	return time.Date(now.Year(), now.Month(), now.Day()+nextRunDay, params.Hour, params.Minute, 0, 0, now.Location())
+}
+
 func (s *Scheduler) checkJobExists(tag string) error {
 	jobs, err := s.Scheduler.FindJobsByTag(tag)
 	if err != nil {
@@ -0,0 +1,335 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/g8rswimmer/go-twitter/v2"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	TwThreadBackendApi = "api"
+
+	// the search pages of a conversation read at most
+	maxThreadSearchPages = 10
+	maxTweetLookupIds    = 100
+)
+
+var (
+	tweetUrlReg = regexp.MustCompile(`^https?://(?:www\.|mobile\.)?(?:twitter|x)\.com/(\w+)/status(?:es)?/(\d+)`)
+
+	ErrInvalidTweetUrl = fmt.Errorf("invalid tweet url")
+	ErrTweetNotFound   = fmt.Errorf("tweet not found")
+
+	twThreadBackends = make([]TwThreadBackend, 0)
+)
+
+// TwThreadBackend fetch the thread of a tweet without the api, such as an RSSHub instance
+type TwThreadBackend interface {
+	Name() string
+	GetThread(account, tweetId string) (*GetTweetResp, error)
+}
+
+// RegisterTwThreadBackend add a backend tried in order of registration when the api fails
+func RegisterTwThreadBackend(backend TwThreadBackend) {
+	twThreadBackends = append(twThreadBackends, backend)
+}
+
+// ParseTweetUrl get the account and the tweet id of a tweet url of twitter.com or x.com
+func ParseTweetUrl(tweetUrl string) (account, tweetId string, err error) {
+	m := tweetUrlReg.FindStringSubmatch(tweetUrl)
+	if m == nil {
+		return "", "", ErrInvalidTweetUrl
+	}
+	return m[1], m[2], nil
+}
+
+// GetTwThreadWithTweetUrl get the thread of the tweet by the api, the backends are tried if the api fails
+func GetTwThreadWithTweetUrl(tweetUrl, userId string) (*GetTweetResp, error) {
+	account, tweetId, err := ParseTweetUrl(tweetUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := getTwThreadClient(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return getTwThread(client, account, tweetId)
+}
+
+// GetTwThreadWithTweetUrlByApi get the thread of the tweet by the api only
+func GetTwThreadWithTweetUrlByApi(tweetUrl, userId string) (*GetTweetResp, error) {
+	_, tweetId, err := ParseTweetUrl(tweetUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := getTwThreadClient(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return getTwThreadByApi(client, tweetId)
+}
+
+// getTwThread get the thread by the api, then by the backends in order
+func getTwThread(client twitterapi.TwitterClient, account, tweetId string) (*GetTweetResp, error) {
+	resp, apiErr := getTwThreadByApi(client, tweetId)
+	if apiErr == nil {
+		return resp, nil
+	}
+
+	for _, backend := range twThreadBackends {
+		log.Error("", "getTwThreadByApi() error %s, try backend %s", apiErr.Error(), backend.Name())
+		resp, err := backend.GetThread(account, tweetId)
+		if err != nil {
+			log.Error("", "backend %s GetThread() error %s", backend.Name(), err.Error())
+			continue
+		}
+		resp.Backend = backend.Name()
+		return resp, nil
+	}
+
+	return nil, apiErr
+}
+
+// getTwThreadClient the client of the account if userId is set, the app client otherwise
+func getTwThreadClient(userId string) (twitterapi.TwitterClient, error) {
+	if userId == "" {
+		return twitterapi.NewTwitterClient(twitterapi.AppOwner, conf.TwitterAPIToken, "", ""), nil
+	}
+
+	account, err := models.GetTwAccountByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+	if account.Status == models.TwAccountStatusRevoked {
+		return nil, ErrTwAccountRevoked
+	}
+	if err = RefreshAccessToken(account); err != nil {
+		return nil, fmt.Errorf("RefreshAccessToken() error %s", err.Error())
+	}
+
+	return twitterapi.NewTwitterClient(userId, account.AccessToken, "", ""), nil
+}
+
+// getTwThreadByApi resolve the conversation of the tweet, collect the self-replies of the author by the recent search
+// and look them up with their media
+func getTwThreadByApi(client twitterapi.TwitterClient, tweetId string) (*GetTweetResp, error) {
+	lookup, err := client.TweetLookup([]string{tweetId})
+	if err != nil {
+		return nil, fmt.Errorf("client.TweetLookup() error %s", err.Error())
+	}
+	if lookup.Raw == nil || len(lookup.Raw.Tweets) == 0 || lookup.Raw.Tweets[0] == nil {
+		return nil, ErrTweetNotFound
+	}
+	tweet := lookup.Raw.Tweets[0]
+	authorId := tweet.AuthorID
+	conversationId := tweet.ConversationID
+	if conversationId == "" {
+		conversationId = tweet.ID
+	}
+
+	// the thread starts at the conversation if the author started it, or at the tweet if it replies to someone else
+	startId := conversationId
+	if conversationId != tweet.ID {
+		root, err := client.TweetLookup([]string{conversationId})
+		if err != nil || root.Raw == nil || len(root.Raw.Tweets) == 0 || root.Raw.Tweets[0] == nil || root.Raw.Tweets[0].AuthorID != authorId {
+			startId = tweet.ID
+		}
+	}
+
+	replyTo, err := searchTwSelfReplies(client, conversationId, authorId)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{startId}
+	inThread := map[string]bool{startId: true}
+	replyIds := make([]string, 0, len(replyTo))
+	for id := range replyTo {
+		replyIds = append(replyIds, id)
+	}
+	sort.Slice(replyIds, func(i, j int) bool { return compareTweetId(replyIds[i], replyIds[j]) < 0 })
+	for _, id := range replyIds {
+		if inThread[replyTo[id]] && !inThread[id] && compareTweetId(id, startId) > 0 {
+			inThread[id] = true
+			ids = append(ids, id)
+		}
+	}
+	// the search only covers the last 7 days, the tweet itself is kept anyway
+	if !inThread[tweet.ID] {
+		ids = append(ids, tweet.ID)
+		sort.Slice(ids, func(i, j int) bool { return compareTweetId(ids[i], ids[j]) < 0 })
+	}
+
+	return lookupTwThread(client, ids, authorId, conversationId)
+}
+
+// searchTwSelfReplies the replies of the author in the conversation, tweet id -> the replied tweet id
+func searchTwSelfReplies(client twitterapi.TwitterClient, conversationId, authorId string) (map[string]string, error) {
+	opts := twitter.TweetRecentSearchOpts{
+		TweetFields: []twitter.TweetField{
+			twitter.TweetFieldAuthorID,
+			twitter.TweetFieldConversationID,
+			twitter.TweetFieldReferencedTweets,
+		},
+		MaxResults: maxSearchResults,
+	}
+
+	replyTo := make(map[string]string)
+	query := fmt.Sprintf("conversation_id:%s from:%s", conversationId, authorId)
+	for i := 0; i < maxThreadSearchPages; i++ {
+		raw, meta, err := client.SearchTweets(query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("client.SearchTweets() error %s", err.Error())
+		}
+
+		for _, v := range raw.Tweets {
+			if v.AuthorID != authorId {
+				continue
+			}
+			for _, ref := range v.ReferencedTweets {
+				if ref.Type == "replied_to" {
+					replyTo[v.ID] = ref.ID
+				}
+			}
+		}
+
+		if meta == nil || len(meta.NextToken) == 0 {
+			break
+		}
+		opts.NextToken = meta.NextToken
+	}
+
+	return replyTo, nil
+}
+
+// lookupTwThread look up the tweets of the thread in order and normalize them with their media
+func lookupTwThread(client twitterapi.TwitterClient, ids []string, authorId, conversationId string) (*GetTweetResp, error) {
+	tweetMap := make(map[string]*twitter.TweetObj)
+	mediaMap := make(map[string]*twitter.MediaObj)
+	var author *twitter.UserObj
+	batchCount := splitTask(int64(len(ids)), maxTweetLookupIds)
+	for i := int64(0); i < batchCount; i++ {
+		batch := ids[i*maxTweetLookupIds : min((i+1)*maxTweetLookupIds, int64(len(ids)))]
+		lookup, err := client.TweetLookup(batch)
+		if err != nil {
+			return nil, fmt.Errorf("client.TweetLookup() error %s", err.Error())
+		}
+		if lookup.Raw == nil {
+			continue
+		}
+
+		for _, v := range lookup.Raw.Tweets {
+			if v != nil {
+				tweetMap[v.ID] = v
+			}
+		}
+		if lookup.Raw.Includes != nil {
+			for _, v := range lookup.Raw.Includes.Media {
+				mediaMap[v.Key] = v
+			}
+			for _, v := range lookup.Raw.Includes.Users {
+				if v.ID == authorId {
+					author = v
+				}
+			}
+		}
+	}
+
+	resp := &GetTweetResp{
+		AuthorId:       authorId,
+		ConversationId: conversationId,
+		Tweets:         make([]string, 0),
+		Items:          make([]*GetTweetItem, 0),
+		Backend:        TwThreadBackendApi,
+	}
+	if author != nil {
+		resp.Account = author.UserName
+		resp.Name = author.Name
+		resp.Avatar = author.ProfileImageURL
+	}
+
+	for _, id := range ids {
+		v, ok := tweetMap[id]
+		if !ok { // deleted
+			continue
+		}
+
+		item := &GetTweetItem{
+			TweetId: v.ID,
+			Text:    v.Text,
+			Media:   make([]*GetTweetMedia, 0),
+		}
+		if createdAt, err := time.Parse(time.RFC3339, v.CreatedAt); err == nil {
+			item.CreatedAt = tools.GetMillisecond(createdAt)
+		}
+		if v.PublicMetrics != nil {
+			item.LikeCount = v.PublicMetrics.Likes
+			item.RetweetCount = v.PublicMetrics.Retweets
+		}
+		if v.Attachments != nil {
+			for _, key := range v.Attachments.MediaKeys {
+				if m, ok := mediaMap[key]; ok {
+					item.Media = append(item.Media, normalizeTweetMedia(m))
+				}
+			}
+		}
+
+		resp.Items = append(resp.Items, item)
+		resp.Tweets = append(resp.Tweets, item.Text)
+	}
+	if len(resp.Items) == 0 {
+		return nil, ErrTweetNotFound
+	}
+	resp.PublishAt = resp.Items[0].CreatedAt
+
+	return resp, nil
+}
+
+// normalizeTweetMedia the url of a video or a gif is its mp4 variant of the highest bit rate
+func normalizeTweetMedia(m *twitter.MediaObj) *GetTweetMedia {
+	item := &GetTweetMedia{
+		Type:       m.Type,
+		Url:        m.URL,
+		PreviewUrl: m.PreviewImageURL,
+		Variants:   m.Variants,
+	}
+
+	bitRate := -1
+	for _, v := range m.Variants {
+		if v.ContentType == "video/mp4" && v.BitRate > bitRate {
+			item.Url = v.URL
+			bitRate = v.BitRate
+		}
+	}
+
+	return item
+}
+
+// compareTweetId compare two tweet ids, the later tweet has the larger id
+func compareTweetId(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,572 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/g8rswimmer/go-twitter/v2"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/taskpool"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+// a post waits for the rate limit to reset at most this long, the thread stops there otherwise
+const maxTwPostRateLimitWait = 5 * time.Minute
+
+var (
+	ErrTwThreadPostNotResumable    = fmt.Errorf("only a thread post failed or stopped can be resumed")
+	ErrTwThreadPostNotRollbackable = fmt.Errorf("only a thread post failed or stopped halfway can be rolled back")
+	ErrTwThreadPostClaimed         = fmt.Errorf("the thread post is being posted")
+	ErrTwThreadPostNotUndoable     = fmt.Errorf("only a thread post held in the undo window can be undone")
+	ErrTwThreadPostUndone          = fmt.Errorf("the thread post is undone")
+)
+
+// ErrTwThreadPostHeld the thread post of a fire is held in the undo window, the fire is deferred to the end of the hold
+type ErrTwThreadPostHeld struct {
+	HoldUntil int64
+}
+
+func (e *ErrTwThreadPostHeld) Error() string {
+	return fmt.Sprintf("the thread post is held until %d", e.HoldUntil)
+}
+
+type TwThreadPostDetail struct {
+	*models.TwThreadPost
+	Items []*models.TwThreadPostItem `json:"items"`
+}
+
+// newTwThreadPostItems the items of the thread in the order of their sort ids
+func newTwThreadPostItems(items []*data.TwAddTweetScheduleReqItem) []*models.TwThreadPostItem {
+	sorted := make([]*data.TwAddTweetScheduleReqItem, len(items))
+	copy(sorted, items)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ai, _ := strconv.Atoi(sorted[i].SortId)
+		aj, _ := strconv.Atoi(sorted[j].SortId)
+		return ai < aj
+	})
+
+	now := tools.GetMillisecond(time.Now())
+	results := make([]*models.TwThreadPostItem, 0, len(sorted))
+	for i, v := range sorted {
+		item := &models.TwThreadPostItem{
+			Seq:       i,
+			SortId:    v.SortId,
+			Text:      v.Text,
+			Status:    models.TwThreadPostItemStatusPending,
+			CreatedAt: now,
+		}
+		item.SetMediaUrls(v.MediaUrls)
+		item.SetMediaAltTexts(v.MediaAltTexts)
+		results = append(results, item)
+	}
+
+	return results
+}
+
+// createTwThreadPost save the thread post of the items to post, it is held for conf.TwPostUndoWindow
+func createTwThreadPost(userId, source string, twScheduleId, tweetLibId int64, items []*models.TwThreadPostItem) (*models.TwThreadPost, error) {
+	now := time.Now()
+	post := &models.TwThreadPost{
+		UserId:       userId,
+		TwScheduleId: twScheduleId,
+		TweetLibId:   tweetLibId,
+		Source:       source,
+		Status:       models.TwThreadPostStatusPosting,
+		ItemCount:    len(items),
+		CreatedAt:    tools.GetMillisecond(now),
+		UpdatedAt:    tools.GetMillisecond(now), // a post not updated is taken as stale by ClaimTwThreadPost
+	}
+	if conf.TwPostUndoWindow > 0 {
+		post.HoldUntil = tools.GetMillisecond(now.Add(time.Duration(conf.TwPostUndoWindow) * time.Second))
+	}
+	if err := models.SaveTwThreadPost(post, items); err != nil {
+		return nil, err
+	}
+
+	return post, nil
+}
+
+// postTwThread post the items not posted yet in order, the first one replies to the last posted item,
+// each item is saved once it is posted so a failed post resumes from where it stops.
+// the ids of the tweets posted by this call are returned, with the error if it stops halfway
+func postTwThread(twClient twitterapi.TwitterClient, post *models.TwThreadPost, items []*models.TwThreadPostItem) ([]string, error) {
+	successTweetIds := make([]string, 0)
+	if err := holdTwThreadPost(post); err != nil {
+		return successTweetIds, err
+	}
+
+	account := ""
+	twAccount, err := models.GetTwAccountByUserId(post.UserId)
+	if err != nil {
+		log.Error("", "models.GetTwAccountByUserId() error %s", err.Error())
+	} else if twAccount != nil {
+		account = twAccount.Account
+	}
+
+	tempInReplyToTweetID := ""
+	for _, v := range items {
+		if v.Status == models.TwThreadPostItemStatusPosted {
+			tempInReplyToTweetID = v.TweetId
+			continue
+		}
+
+		createTweetReq := &twitter.CreateTweetRequest{
+			Text: v.Text,
+		}
+		if mediaIds := v.GetMediaIds(); len(mediaIds) != 0 {
+			createTweetReq.Media = &twitter.CreateTweetMedia{
+				IDs: mediaIds,
+			}
+		}
+		if len(tempInReplyToTweetID) != 0 {
+			createTweetReq.Reply = &twitter.CreateTweetReply{
+				InReplyToTweetID: tempInReplyToTweetID,
+			}
+		}
+
+		log.Info("", "create tweet start, userId:%s, threadPostId:%d, seq:%d", post.UserId, post.Id, v.Seq)
+		err := checkEmergencyStop()
+		if err == nil {
+			if err = twitterapi.Wait(post.UserId, twitterapi.EndpointTweetCreate, maxTwPostRateLimitWait); err != nil {
+				err = fmt.Errorf("twitterapi.Wait() error %w", err)
+			}
+		}
+		if err == nil {
+			var resp *twitter.CreateTweetResponse
+			resp, err = twClient.CreateTweet(createTweetReq)
+			if err != nil {
+				err = &ErrCreateTweet{Err: err}
+			} else {
+				countTwAppMonthlyPost()
+				v.TweetId = resp.Tweet.ID
+			}
+		}
+		if err != nil {
+			v.Status = models.TwThreadPostItemStatusFailed
+			v.ErrorMsg = err.Error()
+			if e := v.Update(); e != nil {
+				log.Error("", "twThreadPostItem.Update() error %s", e.Error())
+			}
+			if e := finishTwThreadPost(post, items, err); e != nil {
+				log.Error("", "finishTwThreadPost() error %s", e.Error())
+			}
+			return successTweetIds, err
+		}
+
+		v.Status = models.TwThreadPostItemStatusPosted
+		v.ErrorMsg = ""
+		v.PostedAt = tools.GetMillisecond(time.Now())
+		if e := v.Update(); e != nil {
+			log.Error("", "twThreadPostItem.Update() error %s", e.Error())
+		}
+		log.Info("", "create tweet success, userId:%s, tweetId:%s, inReplyToTweetID:%s", post.UserId, v.TweetId, tempInReplyToTweetID)
+		if e := models.TouchTwThreadPost(post.Id, v.PostedAt); e != nil {
+			log.Error("", "models.TouchTwThreadPost() error %s", e.Error())
+		}
+		if e := savePostedTweet(post, account, items[0].TweetId, tempInReplyToTweetID, v); e != nil {
+			log.Error("", "savePostedTweet() error %s", e.Error())
+		}
+
+		tempInReplyToTweetID = v.TweetId
+		successTweetIds = append(successTweetIds, v.TweetId)
+	}
+
+	return successTweetIds, finishTwThreadPost(post, items, nil)
+}
+
+// getTwThreadPostStaleBefore a thread post still posting not updated since then is taken as stopped
+func getTwThreadPostStaleBefore(now time.Time) int64 {
+	return tools.GetMillisecond(now.Add(-time.Duration(conf.TwThreadPostStaleTime) * time.Second))
+}
+
+// claimTwThreadPost take the thread post in one of the statuses or stopped while posting, so it is posted
+// by one worker only, ErrTwThreadPostClaimed is returned if another one has taken it
+func claimTwThreadPost(post *models.TwThreadPost, statuses ...int) error {
+	now := time.Now()
+	claimed, err := models.ClaimTwThreadPost(post.Id, statuses, getTwThreadPostStaleBefore(now), tools.GetMillisecond(now))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrTwThreadPostClaimed
+	}
+	post.Status = models.TwThreadPostStatusPosting
+	post.UpdatedAt = tools.GetMillisecond(now)
+
+	return nil
+}
+
+// holdTwThreadPost end the hold of the thread post, ErrTwThreadPostUndone is returned if it is undone.
+// a post still held is left pending with ErrTwThreadPostHeld, it is posted by a deferred fire or task, never waited for
+func holdTwThreadPost(post *models.TwThreadPost) error {
+	if post.HoldUntil == 0 {
+		return nil
+	}
+
+	now := tools.GetMillisecond(time.Now())
+	if post.HoldUntil > now {
+		heldErr := &ErrTwThreadPostHeld{HoldUntil: post.HoldUntil}
+		if err := pendTwThreadPost(post, heldErr); err != nil {
+			return err
+		}
+		return heldErr
+	}
+
+	released, err := models.ReleaseTwThreadPostHold(post.Id, now)
+	if err != nil {
+		return err
+	}
+	if !released {
+		post.Status = models.TwThreadPostStatusCanceled
+		log.Info("", "thread post %d is undone", post.Id)
+		return ErrTwThreadPostUndone
+	}
+	post.HoldUntil = 0
+
+	return nil
+}
+
+// twHeldThreadPostTask the payload of TaskTypeTwHeldThreadPost
+type twHeldThreadPostTask struct {
+	TwThreadPostId int64 `json:"tw_thread_post_id"`
+}
+
+// addTwHeldThreadPostTask enqueue the post of a thread held in the undo window to run when the hold ends,
+// the task outlives a restart
+func addTwHeldThreadPostTask(post *models.TwThreadPost) error {
+	payload := &twHeldThreadPostTask{TwThreadPostId: post.Id}
+	return taskpool.EnqueueAt(TaskTypeTwHeldThreadPost, payload, time.UnixMilli(post.HoldUntil))
+}
+
+// handleTwHeldThreadPostTask post the thread held in the undo window, the task is dropped if the post is undone or taken.
+// the post records its own result, it is resumed by hand if it fails, never retried by the task pool
+func handleTwHeldThreadPostTask(task *taskpool.Task) error {
+	payload := new(twHeldThreadPostTask)
+	if err := task.Unmarshal(payload); err != nil {
+		return err
+	}
+
+	post, items, err := getTwThreadPostDetail(payload.TwThreadPostId)
+	if err == conf.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if post.Status != models.TwThreadPostStatusPending {
+		log.Info("", "drop the held thread post %d of status %d", post.Id, post.Status)
+		return nil
+	}
+	if d := time.Until(time.UnixMilli(post.HoldUntil)); d > 0 {
+		return taskpool.RetryAfter(d, "the thread post is still held")
+	}
+
+	if err = claimTwThreadPost(post, models.TwThreadPostStatusPending); err != nil {
+		if errors.Is(err, ErrTwThreadPostClaimed) {
+			return nil
+		}
+		return err
+	}
+
+	twClient, err := getTwUserClient(post.UserId)
+	if err != nil {
+		log.Error("", "getTwUserClient() threadPostId:%d error %s", post.Id, err.Error())
+		return finishTwThreadPost(post, items, err)
+	}
+
+	if _, err = postTwThread(twClient, post, items); err != nil {
+		log.Error("", "postTwThread() threadPostId:%d error %s", post.Id, err.Error())
+	}
+
+	return nil
+}
+
+// finishTwThreadPost update the status of the thread post by its items
+func finishTwThreadPost(post *models.TwThreadPost, items []*models.TwThreadPostItem, postErr error) error {
+	post.PostedCount = 0
+	for _, v := range items {
+		if v.Status == models.TwThreadPostItemStatusPosted {
+			post.PostedCount++
+		}
+	}
+
+	post.ErrorMsg = ""
+	switch {
+	case postErr == nil:
+		post.Status = models.TwThreadPostStatusPosted
+	case post.PostedCount > 0:
+		post.Status = models.TwThreadPostStatusPartial
+		post.ErrorMsg = postErr.Error()
+	default:
+		post.Status = models.TwThreadPostStatusFailed
+		post.ErrorMsg = postErr.Error()
+	}
+
+	if err := post.Update(); err != nil {
+		return err
+	}
+
+	if post.Status == models.TwThreadPostStatusPosted && post.TweetLibId > 0 {
+		if err := saveEvergreenPostLog(post, items); err != nil {
+			log.Error("", "saveEvergreenPostLog() threadPostId:%d error %s", post.Id, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// failTwThreadPostMedia update the thread post whose media is not ready, a thread post not posted yet waits for
+// the media still processing, the next fire of its schedule resumes it
+func failTwThreadPostMedia(post *models.TwThreadPost, items []*models.TwThreadPostItem, mediaErr error) error {
+	var processingErr *ErrTwMediaProcessing
+	if !errors.As(mediaErr, &processingErr) || post.PostedCount > 0 {
+		return finishTwThreadPost(post, items, mediaErr)
+	}
+
+	return pendTwThreadPost(post, mediaErr)
+}
+
+// pendTwThreadPost leave the thread post not posted yet to the next fire of its schedule,
+// ErrTwThreadPostUndone is returned if it is undone meanwhile
+func pendTwThreadPost(post *models.TwThreadPost, reason error) error {
+	now := tools.GetMillisecond(time.Now())
+	pended, err := models.PendTwThreadPost(post.Id, reason.Error(), now)
+	if err != nil {
+		return err
+	}
+	if !pended {
+		post.Status = models.TwThreadPostStatusCanceled
+		return ErrTwThreadPostUndone
+	}
+	post.Status = models.TwThreadPostStatusPending
+	post.ErrorMsg = reason.Error()
+	post.UpdatedAt = now
+
+	return nil
+}
+
+// handleTwScheduleThreadPostHeld defer the fire to the end of the hold of its thread post,
+// the thread post is undone or posted by the deferred fire
+func handleTwScheduleThreadPostHeld(twSchedule *models.TwSchedule, heldErr *ErrTwThreadPostHeld) error {
+	deferred, err := consumeTwScheduleFire(twSchedule, heldErr.HoldUntil)
+	if err != nil {
+		return err
+	}
+
+	if !deferred {
+		if e := onTwPostQueueScheduleDone(twSchedule, heldErr); e != nil {
+			log.Error("", "onTwPostQueueScheduleDone() error %s", e.Error())
+		}
+	}
+
+	return heldErr
+}
+
+// prepareTwThreadPostMedia attach the media ids to the items not posted yet, the media urls are uploaded by the pipeline
+func prepareTwThreadPostMedia(twClient twitterapi.TwitterClient, post *models.TwThreadPost, items []*models.TwThreadPostItem) error {
+	// the upload may take a while, the post is not taken as stale meanwhile
+	now := tools.GetMillisecond(time.Now())
+	if err := models.TouchTwThreadPost(post.Id, now); err != nil {
+		return err
+	}
+	post.UpdatedAt = now
+
+	pending := make([]*models.TwThreadPostItem, 0)
+	for _, v := range items {
+		if v.Status != models.TwThreadPostItemStatusPosted && len(v.GetMediaUrls()) > 0 {
+			pending = append(pending, v)
+		}
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	mediaIds, err := prepareTwThreadMedia(twClient, post.UserId, pending)
+	if err != nil {
+		return err
+	}
+	for _, v := range pending {
+		v.SetMediaIds(mediaIds[v.Seq])
+	}
+
+	return nil
+}
+
+func getTwThreadPostDetail(id int64) (*models.TwThreadPost, []*models.TwThreadPostItem, error) {
+	post, err := models.GetTwThreadPostById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if post == nil {
+		return nil, nil, conf.ErrRecordNotFound
+	}
+
+	items, err := models.GetTwThreadPostItemList(post.Id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return post, items, nil
+}
+
+func GetTwThreadPostDetail(id int64) (*TwThreadPostDetail, error) {
+	post, items, err := getTwThreadPostDetail(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TwThreadPostDetail{TwThreadPost: post, Items: items}, nil
+}
+
+// ResumeTwThreadPost post the items of a failed or stopped thread post from the one it stopped at
+func ResumeTwThreadPost(id int64) (*TwThreadPostDetail, error) {
+	post, items, err := getTwThreadPostDetail(id)
+	if err != nil {
+		return nil, err
+	}
+
+	twClient, err := getTwUserClient(post.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	// a fire of its schedule resuming it meanwhile fails to take it
+	err = claimTwThreadPost(post, models.TwThreadPostStatusPartial, models.TwThreadPostStatusFailed)
+	if errors.Is(err, ErrTwThreadPostClaimed) {
+		return nil, ErrTwThreadPostNotResumable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = prepareTwThreadPostMedia(twClient, post, items); err != nil {
+		if e := finishTwThreadPost(post, items, err); e != nil {
+			log.Error("", "finishTwThreadPost() error %s", e.Error())
+		}
+		return nil, err
+	}
+
+	if _, err = postTwThread(twClient, post, items); err != nil {
+		return nil, err
+	}
+
+	return &TwThreadPostDetail{TwThreadPost: post, Items: items}, nil
+}
+
+// RollbackTwThreadPost delete the posted items of a thread post failed or stopped halfway, the last one first,
+// a rollback failed halfway can be run again
+func RollbackTwThreadPost(id int64) (*TwThreadPostDetail, error) {
+	post, items, err := getTwThreadPostDetail(id)
+	if err != nil {
+		return nil, err
+	}
+
+	twClient, err := getTwUserClient(post.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	// taken like a resume so the items are not posted while they are deleted
+	err = claimTwThreadPost(post, models.TwThreadPostStatusPartial)
+	if errors.Is(err, ErrTwThreadPostClaimed) {
+		return nil, ErrTwThreadPostNotRollbackable
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = deleteTwThreadPostItems(twClient, post, items); err != nil {
+		// the items still posted are rolled back by running it again
+		if e := finishTwThreadPost(post, items, err); e != nil {
+			log.Error("", "finishTwThreadPost() error %s", e.Error())
+		}
+		return nil, err
+	}
+
+	post.PostedCount = 0
+	post.Status = models.TwThreadPostStatusRolledBack
+	if err = post.Update(); err != nil {
+		return nil, err
+	}
+
+	return &TwThreadPostDetail{TwThreadPost: post, Items: items}, nil
+}
+
+// deleteTwThreadPostItems delete the posted items of the thread post, the last one first
+func deleteTwThreadPostItems(twClient twitterapi.TwitterClient, post *models.TwThreadPost, items []*models.TwThreadPostItem) error {
+	for i := len(items) - 1; i >= 0; i-- {
+		v := items[i]
+		if v.Status != models.TwThreadPostItemStatusPosted {
+			continue
+		}
+
+		// a tweet deleted already is reported not deleted, it is gone either way
+		if _, err := twClient.DeleteTweet(v.TweetId); err != nil {
+			return fmt.Errorf("twClient.DeleteTweet() tweetId: %s error %s", v.TweetId, err.Error())
+		}
+		log.Info("", "delete tweet success, userId:%s, tweetId:%s", post.UserId, v.TweetId)
+		if err := markPostedTweetDeleted(v.TweetId); err != nil {
+			return err
+		}
+
+		v.Status = models.TwThreadPostItemStatusDeleted
+		if err := v.Update(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UndoTwThreadPost cancel a thread post still held in the undo window, none of its tweets is posted then
+func UndoTwThreadPost(id int64) (*TwThreadPostDetail, error) {
+	post, err := models.GetTwThreadPostById(id)
+	if err != nil {
+		return nil, err
+	}
+	if post == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	undone, err := models.CancelTwThreadPost(id, tools.GetMillisecond(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	if !undone {
+		return nil, ErrTwThreadPostNotUndoable
+	}
+
+	// the fire deferred for the media of the thread post would post another thread
+	if post.Status == models.TwThreadPostStatusPending && post.TwScheduleId > 0 {
+		if err = dropTwScheduleDeferredFire(post.TwScheduleId); err != nil {
+			log.Error("", "dropTwScheduleDeferredFire() scheduleId:%d error %s", post.TwScheduleId, err.Error())
+		}
+	}
+
+	return GetTwThreadPostDetail(id)
+}
+
+// dropTwScheduleDeferredFire consume the deferred fire of the schedule, its task is dropped when it runs
+func dropTwScheduleDeferredFire(twScheduleId int64) error {
+	twSchedule, err := models.GetTwScheduleById(twScheduleId, models.TwScheduleStatusUnFinished)
+	if err != nil {
+		return err
+	}
+	if twSchedule == nil || twSchedule.DeferredRunAt == 0 {
+		return nil
+	}
+
+	twSchedule.DeferredRunAt = 0
+	if _, err = consumeTwScheduleFire(twSchedule, 0); err != nil {
+		return err
+	}
+
+	return onTwPostQueueScheduleDone(twSchedule, ErrTwThreadPostUndone)
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/g8rswimmer/go-twitter/v2"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools/log"
+)
+
+var (
+	ErrTwAccountRevoked = fmt.Errorf("the twitter account is revoked, it has to be connected again")
+)
+
+// CheckTwAccounts validate the oauth2 token and the oauth1 credentials of every account,
+// the accounts revoked or suspended are marked and their schedules are paused
+func CheckTwAccounts() error {
+	accounts, err := models.GetAllAccountList("", "")
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, account := range accounts {
+		if account.Status == models.TwAccountStatusRevoked {
+			continue
+		}
+
+		if err := checkTwAccount(account); err != nil {
+			log.Error("", "checkTwAccount() userId:%s error %s", account.UserId, err.Error())
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("check failed for %d of %d accounts", failed, len(accounts))
+	}
+
+	return nil
+}
+
+func checkTwAccount(account *models.TwAccount) error {
+	if err := RefreshAccessToken(account); err != nil {
+		if errors.Is(err, ErrTwAccountRevoked) { // revoked after the list is loaded
+			return nil
+		}
+		return handleTwAuthError(account, "oauth2", err)
+	}
+
+	if _, err := twitterapi.NewTwitterClient(account.UserId, account.AccessToken, "", "").GetAuthUser(); err != nil {
+		return handleTwAuthError(account, "oauth2", err)
+	}
+
+	twOAuth1, err := models.GetTwOAuth1ByUserId(account.UserId)
+	if err != nil {
+		return err
+	}
+	if twOAuth1 == nil {
+		return nil
+	}
+
+	err = twitterapi.NewTwitterClient(account.UserId, "", twOAuth1.AccessToken, twOAuth1.AccessSecret).VerifyCredentials()
+	if err != nil {
+		return handleTwAuthError(account, "oauth1", err)
+	}
+
+	return nil
+}
+
+// handleTwAuthError mark the account revoked if the error tells the authorization is dead,
+// the other errors are returned as they are
+func handleTwAuthError(account *models.TwAccount, auth string, err error) error {
+	if !isTwAuthRevoked(err) {
+		return err
+	}
+
+	reason := fmt.Sprintf("%s: %s", auth, err.Error())
+	if e := markTwAccountRevoked(account, reason); e != nil {
+		return fmt.Errorf("markTwAccountRevoked() error %s", e.Error())
+	}
+
+	return nil
+}
+
+// the v1.1 error codes of a 403 telling the authorization is dead, the other 403s are the api tier, the enrollment
+// or the app, not the account
+const (
+	twErrCodeInvalidToken  = 89  // invalid or expired token
+	twErrCodeAccountLocked = 326 // the account is temporarily locked
+)
+
+// isTwAuthRevoked tell whether twitter rejected the authorization, a 401, a 403 of a dead token or a locked account,
+// or a dead refresh token
+func isTwAuthRevoked(err error) bool {
+	var tokenErr *twitterapi.ErrTokenResponse
+	if errors.As(err, &tokenErr) {
+		return tokenErr.IsTokenInvalid()
+	}
+
+	var v2Err *twitter.ErrorResponse
+	if errors.As(err, &v2Err) {
+		return v2Err.StatusCode == http.StatusUnauthorized
+	}
+
+	var v1Err *anaconda.ApiError
+	if !errors.As(err, &v1Err) {
+		return false
+	}
+	if v1Err.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	if v1Err.StatusCode != http.StatusForbidden {
+		return false
+	}
+	for _, v := range v1Err.Decoded.Errors {
+		if v.Code == twErrCodeInvalidToken || v.Code == twErrCodeAccountLocked {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markTwAccountRevoked mark the account revoked, pause its schedules and notify the admins
+func markTwAccountRevoked(account *models.TwAccount, reason string) error {
+	account.Status = models.TwAccountStatusRevoked
+	account.StatusReason = reason
+	if err := account.Update(); err != nil {
+		return err
+	}
+
+	twSchedules, err := models.GetTwScheduleListByUserId(account.UserId, models.TwScheduleStatusUnFinished)
+	if err != nil {
+		return err
+	}
+
+	paused := 0
+	for _, v := range twSchedules {
+		_, err := PauseTwSchedule(v.Id)
+		if err == ErrPauseTwPostQueueSchedule { // the post queue is stopped by CreateTweet
+			continue
+		}
+		if err != nil {
+			log.Error("", "PauseTwSchedule() scheduleId:%d error %s", v.Id, err.Error())
+			continue
+		}
+		paused++
+	}
+
+	NotifyAdmins("twitter account revoked", fmt.Sprintf(
+		"@%s (user_id %s) is revoked or suspended, %d schedules are paused. reason: %s. "+
+			"get a reconnect link by /security/twaccount/getreconnecturl and resume the schedules after connecting",
+		account.Account, account.UserId, paused, reason))
+
+	return nil
+}
+
+// GetTwReconnectUrl build the authorization url connecting a revoked account again,
+// the oauth1 flow is used if the oauth1 credentials are the revoked ones
+func GetTwReconnectUrl(userId string, initiator string) (string, error) {
+	account, err := models.GetTwAccountByUserId(userId)
+	if err != nil {
+		return "", err
+	}
+	if account == nil {
+		return "", conf.ErrRecordNotFound
+	}
+
+	if strings.HasPrefix(account.StatusReason, "oauth1") {
+		return GetTwOAuth1AuthUrl(initiator)
+	}
+	return GetTwOAuth2AuthUrl(int64(account.AuthType), initiator)
+}
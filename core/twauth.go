@@ -0,0 +1,139 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+var (
+	ErrUnsupportedAuthType = fmt.Errorf("unsupported auth type")
+)
+
+// GetAdminInitiator describe the admin who started an authorization
+func GetAdminInitiator(adminToken *AdminToken) string {
+	if adminToken == nil {
+		return ""
+	}
+	return fmt.Sprintf("admin:%d:%s", adminToken.Uid, adminToken.Username)
+}
+
+// GetTwOAuth2AuthUrl build the oauth2 authorization url granting the scope of the auth type
+func GetTwOAuth2AuthUrl(authType int64, initiator string) (string, error) {
+	scope, ok := models.AllowAuthType[authType]
+	if !ok {
+		return "", ErrUnsupportedAuthType
+	}
+
+	return twitterapi.GetAuthCodeUrl(conf.BaseScope+" "+scope, authType, initiator)
+}
+
+// GetTwOAuth1AuthUrl build the oauth1 authorization url
+func GetTwOAuth1AuthUrl(initiator string) (string, error) {
+	return twitterapi.NewTwitterAPIV1("", "").GetAuthorizationUrl(initiator)
+}
+
+// ConnectTwOAuth2Account exchange the code of the oauth2 callback and save the authorized account
+func ConnectTwOAuth2Account(code, state string) (*models.TwAccount, error) {
+	resp, authState, err := twitterapi.GetAuthToken(code, state)
+	if err != nil {
+		return nil, err
+	}
+
+	authUser, err := twitterapi.GetAuthUser(resp.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("twitterapi.GetAuthUser() error %s", err.Error())
+	}
+	if len(authUser.Users) == 0 {
+		return nil, fmt.Errorf("the auth user not found")
+	}
+	user := authUser.Users[0]
+
+	account, err := models.GetTwAccountByUserId(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if account == nil {
+		account = &models.TwAccount{
+			UserId:    user.ID,
+			CreatedAt: tools.GetMillisecond(now),
+		}
+	}
+	account.Name = user.Name
+	account.Account = user.UserName
+	account.ProfileImageUrl = user.ProfileImageURL
+	account.AuthType = int(authState.AuthType)
+	account.Scope = resp.Scope
+	account.AccessToken = resp.AccessToken
+	account.RefreshToken = resp.RefreshToken
+	account.ExpiredAt = tools.GetMillisecond(now.Add(time.Duration(resp.ExpiresIn) * time.Second))
+	account.Status = models.TwAccountStatusNormal
+	account.StatusReason = ""
+	account.UpdatedAt = tools.GetMillisecond(now)
+
+	if err = account.SaveOrUpdateWithLog(); err != nil {
+		return nil, err
+	}
+
+	log.Info("", "oauth2 account connected, userId:%s, initiator:%s", account.UserId, authState.Initiator)
+	return account, nil
+}
+
+// ConnectTwOAuth1Account exchange the verifier of the oauth1 callback and save the authorized credentials
+func ConnectTwOAuth1Account(token, verifier string) (*models.TwOAuth1, error) {
+	cred, err := twitterapi.NewTwitterAPIV1("", "").ExchangeAccessToken(token, verifier)
+	if err != nil {
+		return nil, err
+	}
+
+	self, err := twitterapi.NewTwitterAPIV1(cred.Token, cred.Secret).Client.GetSelf(nil)
+	if err != nil {
+		return nil, fmt.Errorf("get the authorized user error %s", err.Error())
+	}
+
+	twOAuth1, err := models.GetTwOAuth1ByUserId(cred.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := tools.GetMillisecond(time.Now())
+	if twOAuth1 == nil {
+		twOAuth1 = &models.TwOAuth1{
+			UserId:    cred.UserId,
+			CreatedAt: now,
+		}
+	}
+	twOAuth1.Name = self.Name
+	twOAuth1.Account = self.ScreenName
+	twOAuth1.AccessToken = cred.Token
+	twOAuth1.AccessSecret = cred.Secret
+	twOAuth1.UpdatedAt = now
+
+	if err = twOAuth1.Save(); err != nil {
+		return nil, err
+	}
+
+	// the account revoked by its oauth1 credentials is back
+	account, err := models.GetTwAccountByUserId(twOAuth1.UserId)
+	if err != nil {
+		return nil, err
+	}
+	if account != nil && account.Status == models.TwAccountStatusRevoked && strings.HasPrefix(account.StatusReason, "oauth1") {
+		account.Status = models.TwAccountStatusNormal
+		account.StatusReason = ""
+		if err = account.Update(); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info("", "oauth1 account connected, userId:%s, initiator:%s", twOAuth1.UserId, cred.Initiator)
+	return twOAuth1, nil
+}
@@ -0,0 +1,307 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/g8rswimmer/go-twitter/v2"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	defaultTweetLibMaxPerAccount = 20
+	// the refresh waits for the rate limit to reset at most this long
+	maxTweetLibRefreshWait = 15 * time.Minute
+)
+
+var (
+	ErrTweetLibDuplicate = fmt.Errorf("the tweet is in the lib already")
+)
+
+// tweetLibImportSource a tweet to import and the category it goes to
+type tweetLibImportSource struct {
+	Source   string
+	Account  string
+	TweetId  string
+	Category string
+}
+
+// GetTweetLibSourceUrl the canonical url of a tweet, the lib is deduplicated by it
+func GetTweetLibSourceUrl(account, tweetId string) string {
+	return fmt.Sprintf("%s/%s/status/%s", TwitterBaseUrl, account, tweetId)
+}
+
+// ImportTweetLib store the threads of the tweet urls, of the recent original tweets of the accounts and of the csv rows,
+// a thread in the lib already is skipped, a source failed does not stop the others
+func ImportTweetLib(req *data.TweetLibImportReq) (*data.TweetLibImportResp, error) {
+	client, err := getTwThreadClient(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &data.TweetLibImportResp{
+		ImportedIds: make([]uint64, 0),
+		Duplicates:  make([]string, 0),
+		Failed:      make([]*data.TweetLibImportFailItem, 0),
+	}
+	fail := func(source string, err error) {
+		resp.Failed = append(resp.Failed, &data.TweetLibImportFailItem{Source: source, ErrMsg: err.Error()})
+	}
+
+	sources := make([]*tweetLibImportSource, 0)
+	for _, v := range req.TweetUrls {
+		account, tweetId, err := ParseTweetUrl(v)
+		if err != nil {
+			fail(v, err)
+			continue
+		}
+		sources = append(sources, &tweetLibImportSource{Source: v, Account: account, TweetId: tweetId, Category: req.Category})
+	}
+
+	if len(req.Csv) > 0 {
+		rows, errs := parseTweetLibCsv(req.Csv, req.Category)
+		sources = append(sources, rows...)
+		resp.Failed = append(resp.Failed, errs...)
+	}
+
+	maxPerAccount := req.MaxPerAccount
+	if maxPerAccount <= 0 {
+		maxPerAccount = defaultTweetLibMaxPerAccount
+	}
+	for _, account := range req.Accounts {
+		account = strings.TrimPrefix(strings.TrimSpace(account), "@")
+		tweetIds, err := searchTweetLibAccount(client, account, maxPerAccount)
+		if err != nil {
+			fail("@"+account, err)
+			continue
+		}
+		for _, tweetId := range tweetIds {
+			sources = append(sources, &tweetLibImportSource{
+				Source:   GetTweetLibSourceUrl(account, tweetId),
+				Account:  account,
+				TweetId:  tweetId,
+				Category: req.Category,
+			})
+		}
+	}
+
+	for _, v := range sources {
+		tweetLib, err := importTweetLibThread(client, v)
+		if err == ErrTweetLibDuplicate {
+			resp.Duplicates = append(resp.Duplicates, v.Source)
+			continue
+		}
+		if err != nil {
+			log.Error("", "importTweetLibThread() source: %s error %s", v.Source, err.Error())
+			fail(v.Source, err)
+			continue
+		}
+		resp.ImportedIds = append(resp.ImportedIds, tweetLib.Id)
+	}
+
+	log.Info("", "ImportTweetLib() done, imported: %d, duplicates: %d, failed: %d", len(resp.ImportedIds), len(resp.Duplicates), len(resp.Failed))
+	return resp, nil
+}
+
+// parseTweetLibCsv parse the rows of "tweet_url[,category]", a header row is skipped
+func parseTweetLibCsv(content, category string) ([]*tweetLibImportSource, []*data.TweetLibImportFailItem) {
+	sources := make([]*tweetLibImportSource, 0)
+	errs := make([]*data.TweetLibImportFailItem, 0)
+
+	r := csv.NewReader(strings.NewReader(content))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, &data.TweetLibImportFailItem{Source: fmt.Sprintf("csv line %d", line), ErrMsg: err.Error()})
+			continue
+		}
+		if len(record) == 0 || len(strings.TrimSpace(record[0])) == 0 {
+			continue
+		}
+
+		tweetUrl := strings.TrimSpace(record[0])
+		account, tweetId, err := ParseTweetUrl(tweetUrl)
+		if err != nil {
+			if line == 1 { // header
+				continue
+			}
+			errs = append(errs, &data.TweetLibImportFailItem{Source: tweetUrl, ErrMsg: err.Error()})
+			continue
+		}
+
+		source := &tweetLibImportSource{Source: tweetUrl, Account: account, TweetId: tweetId, Category: category}
+		if len(record) > 1 && len(strings.TrimSpace(record[1])) > 0 {
+			source.Category = strings.TrimSpace(record[1])
+		}
+		sources = append(sources, source)
+	}
+
+	return sources, errs
+}
+
+// searchTweetLibAccount the ids of the recent original tweets of the account, the newest first
+func searchTweetLibAccount(client twitterapi.TwitterClient, account string, limit int) ([]string, error) {
+	userRaw, err := client.GetUserByAccount(account)
+	if err != nil {
+		return nil, fmt.Errorf("client.GetUserByAccount() error %s", err.Error())
+	}
+	if userRaw == nil || len(userRaw.Users) == 0 || userRaw.Users[0] == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	opts := twitter.TweetRecentSearchOpts{
+		MaxResults: min(max(limit, 10), maxSearchResults),
+	}
+	query := fmt.Sprintf("from:%s -is:reply -is:retweet", userRaw.Users[0].ID)
+
+	tweetIds := make([]string, 0)
+	for len(tweetIds) < limit {
+		raw, meta, err := client.SearchTweets(query, opts)
+		if err != nil {
+			return nil, fmt.Errorf("client.SearchTweets() error %s", err.Error())
+		}
+		for _, v := range raw.Tweets {
+			if len(tweetIds) < limit {
+				tweetIds = append(tweetIds, v.ID)
+			}
+		}
+
+		if meta == nil || len(meta.NextToken) == 0 {
+			break
+		}
+		opts.NextToken = meta.NextToken
+	}
+
+	return tweetIds, nil
+}
+
+// importTweetLibThread save the thread of the tweet, the source url is the one of the first tweet of the thread
+func importTweetLibThread(client twitterapi.TwitterClient, source *tweetLibImportSource) (*models.TweetLib, error) {
+	exists, err := models.GeTweetBySourceUrl(GetTweetLibSourceUrl(source.Account, source.TweetId))
+	if err != nil {
+		return nil, err
+	}
+	if exists != nil {
+		return nil, ErrTweetLibDuplicate
+	}
+
+	thread, err := getTwThread(client, source.Account, source.TweetId)
+	if err != nil {
+		return nil, err
+	}
+
+	account := thread.Account
+	if account == "" {
+		account = source.Account
+	}
+	first := thread.Items[0]
+	sourceUrl := GetTweetLibSourceUrl(account, first.TweetId)
+	if exists, err = models.GeTweetBySourceUrl(sourceUrl); err != nil {
+		return nil, err
+	}
+	if exists != nil {
+		return nil, ErrTweetLibDuplicate
+	}
+
+	content, err := json.Marshal(thread.Tweets)
+	if err != nil {
+		return nil, err
+	}
+
+	now := tools.GetMillisecond(time.Now())
+	tweetLib := &models.TweetLib{
+		Account:      account,
+		Avatar:       thread.Avatar,
+		Category:     source.Category,
+		SourceUrl:    sourceUrl,
+		LikeCount:    int64(first.LikeCount),
+		RetweetCount: int64(first.RetweetCount),
+		Content:      string(content),
+		PublishAt:    thread.PublishAt,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err = tweetLib.Save(); err != nil {
+		return nil, err
+	}
+
+	return tweetLib, nil
+}
+
+// RefreshTweetLibMetrics update the like and retweet counts of the lib in batched lookups, the tweets deleted are kept as they are
+func RefreshTweetLibMetrics() error {
+	client := twitterapi.NewTwitterClient(twitterapi.AppOwner, conf.TwitterAPIToken, "", "")
+
+	var lastId uint64
+	updated := 0
+	for {
+		list, err := models.GetTweetLibListAfterId(lastId, maxTweetLookupIds)
+		if err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			break
+		}
+		lastId = list[len(list)-1].Id
+
+		libMap := make(map[string]*models.TweetLib)
+		tweetIds := make([]string, 0, len(list))
+		for _, v := range list {
+			_, tweetId, err := ParseTweetUrl(v.SourceUrl)
+			if err != nil {
+				continue
+			}
+			libMap[tweetId] = v
+			tweetIds = append(tweetIds, tweetId)
+		}
+		if len(tweetIds) == 0 {
+			continue
+		}
+
+		if err = twitterapi.Wait(twitterapi.AppOwner, twitterapi.EndpointTweetsLookup, maxTweetLibRefreshWait); err != nil {
+			return fmt.Errorf("twitterapi.Wait() error %s", err.Error())
+		}
+		lookup, err := client.TweetLookup(tweetIds)
+		if err != nil {
+			return fmt.Errorf("client.TweetLookup() error %s", err.Error())
+		}
+		if lookup.Raw == nil {
+			continue
+		}
+
+		for _, v := range lookup.Raw.Tweets {
+			tweetLib, ok := libMap[v.ID]
+			if !ok || v.PublicMetrics == nil {
+				continue
+			}
+			likeCount, retweetCount := int64(v.PublicMetrics.Likes), int64(v.PublicMetrics.Retweets)
+			if tweetLib.LikeCount == likeCount && tweetLib.RetweetCount == retweetCount {
+				continue
+			}
+
+			tweetLib.LikeCount = likeCount
+			tweetLib.RetweetCount = retweetCount
+			if err = tweetLib.Update(); err != nil {
+				return err
+			}
+			updated++
+		}
+	}
+
+	log.Info("", "RefreshTweetLibMetrics() done, updated: %d", updated)
+	return nil
+}
@@ -0,0 +1,277 @@
+package core
+
+import (
+	"time"
+
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	// the due snapshots taken by a run at most, the rest are taken by the next run
+	maxTwTweetMetricSnapshots = 1000
+	maxTwTweetMetricWait      = 5 * time.Minute
+	// a snapshot can not be taken this long after it is due is given up
+	maxTwTweetMetricDelay = 24 * time.Hour
+)
+
+// twTweetMetricStageOffsets the time after the post each stage is taken
+var twTweetMetricStageOffsets = map[string]time.Duration{
+	models.TwTweetMetricStage1Hour:  time.Hour,
+	models.TwTweetMetricStage24Hour: 24 * time.Hour,
+	models.TwTweetMetricStage7Day:   7 * 24 * time.Hour,
+}
+
+type TweetPerformance struct {
+	Tweet   *models.PostedTweet     `json:"tweet"`
+	Metrics []*models.TwTweetMetric `json:"metrics"`
+}
+
+type TwSchedulePerformance struct {
+	TwScheduleId int64                      `json:"tw_schedule_id"`
+	Stages       []*models.TwTweetMetricSum `json:"stages"`
+	Amount       int64                      `json:"amount"`
+	Tweets       []*TweetPerformance        `json:"tweets"`
+}
+
+// scheduleTwTweetMetrics schedule the metric snapshots of a posted tweet at each stage
+func scheduleTwTweetMetrics(postedTweet *models.PostedTweet, twScheduleLibId int64) error {
+	now := tools.GetMillisecond(time.Now())
+	postedAt := time.UnixMilli(postedTweet.PostedAt)
+	list := make([]*models.TwTweetMetric, 0, len(models.TwTweetMetricStages))
+	for _, stage := range models.TwTweetMetricStages {
+		list = append(list, &models.TwTweetMetric{
+			UserId:          postedTweet.UserId,
+			TweetId:         postedTweet.TweetId,
+			TwScheduleId:    postedTweet.SourceId,
+			TwScheduleLibId: twScheduleLibId,
+			Stage:           stage,
+			Status:          models.TwTweetMetricStatusPending,
+			DueAt:           tools.GetMillisecond(postedAt.Add(twTweetMetricStageOffsets[stage])),
+			CreatedAt:       now,
+		})
+	}
+
+	return models.SaveTwTweetMetrics(list)
+}
+
+// SnapshotTwTweetMetrics take the due metric snapshots of the posted tweets, the tweets are looked up with the token
+// of their accounts so the non public metrics are included when available
+func SnapshotTwTweetMetrics() error {
+	now := time.Now()
+	list, err := models.GetDueTwTweetMetricList(tools.GetMillisecond(now), maxTwTweetMetricSnapshots)
+	if err != nil {
+		return err
+	}
+
+	userMetrics := make(map[string][]*models.TwTweetMetric)
+	for _, v := range list {
+		userMetrics[v.UserId] = append(userMetrics[v.UserId], v)
+	}
+
+	taken := 0
+	for userId, metrics := range userMetrics {
+		twClient, err := getTwUserClient(userId)
+		if err != nil {
+			log.Error("", "getTwUserClient() userId: %s error %s", userId, err.Error())
+			expireTwTweetMetrics(metrics, now)
+			continue
+		}
+
+		tweetMetrics := make(map[string][]*models.TwTweetMetric)
+		tweetIds := make([]string, 0)
+		for _, v := range metrics {
+			if _, ok := tweetMetrics[v.TweetId]; !ok {
+				tweetIds = append(tweetIds, v.TweetId)
+			}
+			tweetMetrics[v.TweetId] = append(tweetMetrics[v.TweetId], v)
+		}
+
+		for i := 0; i < len(tweetIds); i += maxTweetLookupIds {
+			batch := tweetIds[i:min(i+maxTweetLookupIds, len(tweetIds))]
+			n, err := snapshotTwTweetMetricBatch(twClient, userId, batch, tweetMetrics)
+			if err != nil {
+				log.Error("", "snapshotTwTweetMetricBatch() userId: %s error %s", userId, err.Error())
+				for _, tweetId := range batch {
+					expireTwTweetMetrics(tweetMetrics[tweetId], now)
+				}
+				break
+			}
+			taken += n
+		}
+	}
+
+	log.Info("", "SnapshotTwTweetMetrics() done, due: %d, taken: %d", len(list), taken)
+	return nil
+}
+
+// snapshotTwTweetMetricBatch look up a batch of tweets and save their snapshots, the non public metrics are
+// given up for the batch if the lookup of them fails
+func snapshotTwTweetMetricBatch(twClient twitterapi.TwitterClient, userId string, tweetIds []string, tweetMetrics map[string][]*models.TwTweetMetric) (int, error) {
+	if err := twitterapi.Wait(userId, twitterapi.EndpointTweetsLookup, maxTwTweetMetricWait); err != nil {
+		return 0, err
+	}
+	lookup, err := twClient.TweetMetricsLookup(tweetIds, true)
+	if err != nil {
+		log.Warning("", "twClient.TweetMetricsLookup() with the non public metrics error %s", err.Error())
+		if err = twitterapi.Wait(userId, twitterapi.EndpointTweetsLookup, maxTwTweetMetricWait); err != nil {
+			return 0, err
+		}
+		if lookup, err = twClient.TweetMetricsLookup(tweetIds, false); err != nil {
+			return 0, err
+		}
+	}
+
+	now := tools.GetMillisecond(time.Now())
+	taken := 0
+	for _, v := range lookup.Tweets {
+		for _, m := range tweetMetrics[v.ID] {
+			applyTwTweetMetrics(m, v)
+			m.Status = models.TwTweetMetricStatusDone
+			m.SnapshotAt = now
+			if err = m.Update(); err != nil {
+				return taken, err
+			}
+			taken++
+		}
+	}
+	// a tweet deleted or not visible is reported in the errors by its id
+	for _, v := range lookup.Errors {
+		for _, m := range tweetMetrics[v.Value] {
+			m.Status = models.TwTweetMetricStatusGone
+			m.SnapshotAt = now
+			if err = m.Update(); err != nil {
+				return taken, err
+			}
+		}
+	}
+
+	return taken, nil
+}
+
+func applyTwTweetMetrics(m *models.TwTweetMetric, obj *twitterapi.TweetMetricsObj) {
+	if obj.PublicMetrics != nil {
+		m.Impressions = obj.PublicMetrics.Impressions
+		m.Likes = obj.PublicMetrics.Likes
+		m.Replies = obj.PublicMetrics.Replies
+		m.Retweets = obj.PublicMetrics.Retweets
+		m.Quotes = obj.PublicMetrics.Quotes
+		m.Bookmarks = obj.PublicMetrics.Bookmarks
+	}
+	m.NonPublic = obj.NonPublicMetrics != nil
+	if m.NonPublic {
+		m.Impressions = obj.NonPublicMetrics.Impressions
+	}
+}
+
+// expireTwTweetMetrics give up the snapshots overdue for maxTwTweetMetricDelay, such as those of a revoked account,
+// so they do not hold the due snapshots behind them
+func expireTwTweetMetrics(metrics []*models.TwTweetMetric, now time.Time) {
+	for _, v := range metrics {
+		if now.Sub(time.UnixMilli(v.DueAt)) < maxTwTweetMetricDelay {
+			continue
+		}
+		v.Status = models.TwTweetMetricStatusGone
+		if err := v.Update(); err != nil {
+			log.Error("", "twTweetMetric.Update() error %s", err.Error())
+		}
+	}
+}
+
+// GetTweetPerformance the metric snapshots of a posted tweet
+func GetTweetPerformance(tweetId string) (*TweetPerformance, error) {
+	postedTweet, err := models.GetPostedTweetByTweetId(tweetId)
+	if err != nil {
+		return nil, err
+	}
+	if postedTweet == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	metrics, err := models.GetTwTweetMetricListByTweetIds([]string{tweetId})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TweetPerformance{Tweet: postedTweet, Metrics: metrics}, nil
+}
+
+// GetTwSchedulePerformance the metrics of the tweets posted by a schedule summed up per stage, with a page of the tweets
+func GetTwSchedulePerformance(twScheduleId, page, limit int64) (*TwSchedulePerformance, error) {
+	stages, err := models.GetTwScheduleMetricSumList(twScheduleId)
+	if err != nil {
+		return nil, err
+	}
+
+	amount, list, err := models.GetPostedTweetList("", models.PostedTweetSourceSchedule, twScheduleId, 0, 0, 0, page, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	tweetIds := make([]string, 0, len(list))
+	for _, v := range list {
+		tweetIds = append(tweetIds, v.TweetId)
+	}
+	metrics, err := models.GetTwTweetMetricListByTweetIds(tweetIds)
+	if err != nil {
+		return nil, err
+	}
+	tweetMetrics := make(map[string][]*models.TwTweetMetric)
+	for _, v := range metrics {
+		tweetMetrics[v.TweetId] = append(tweetMetrics[v.TweetId], v)
+	}
+
+	tweets := make([]*TweetPerformance, 0, len(list))
+	for _, v := range list {
+		m := tweetMetrics[v.TweetId]
+		if m == nil {
+			m = make([]*models.TwTweetMetric, 0)
+		}
+		tweets = append(tweets, &TweetPerformance{Tweet: v, Metrics: m})
+	}
+
+	return &TwSchedulePerformance{
+		TwScheduleId: twScheduleId,
+		Stages:       stages,
+		Amount:       amount,
+		Tweets:       tweets,
+	}, nil
+}
+
+type TwScheduleLibRankItem struct {
+	*models.TwTweetMetricSum
+	Lib *models.TwScheduleLib `json:"lib"`
+}
+
+// GetTwScheduleLibRank rank the schedule libs by the average of the metric per tweet at the stage, with their contents
+func GetTwScheduleLibRank(userId, stage, order string, page, limit int64) (int64, []*TwScheduleLibRankItem, error) {
+	amount, list, err := models.GetTwScheduleLibMetricRank(userId, stage, order, page, limit)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	ids := make([]int64, 0, len(list))
+	for _, v := range list {
+		ids = append(ids, v.TwScheduleLibId)
+	}
+	libMap := make(map[int64]*models.TwScheduleLib)
+	if len(ids) > 0 {
+		libs, err := models.GetTwScheduleListByIds(ids)
+		if err != nil {
+			return 0, nil, err
+		}
+		for _, v := range libs {
+			libMap[v.Id] = v
+		}
+	}
+
+	results := make([]*TwScheduleLibRankItem, 0, len(list))
+	for _, v := range list {
+		results = append(results, &TwScheduleLibRankItem{TwTweetMetricSum: v, Lib: libMap[v.TwScheduleLibId]})
+	}
+
+	return amount, results, nil
+}
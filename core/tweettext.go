@@ -0,0 +1,53 @@
+package core
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTweetWeightedLength max weighted length of a tweet for a non-premium account
+	MaxTweetWeightedLength = 280
+
+	// every url is counted as a t.co link
+	tweetUrlWeightedLength = 23
+)
+
+var (
+	tweetUrlRegexp = regexp.MustCompile(`https?://[^\s]+`)
+
+	// code points in these ranges are counted as 1, the others (CJK, emoji...) as 2, see twitter-text v3 config
+	tweetLightRanges = [][2]rune{
+		{0, 4351},
+		{8192, 8205},
+		{8208, 8223},
+		{8242, 8247},
+	}
+)
+
+// TweetWeightedLength the length of the text counted the way Twitter does
+func TweetWeightedLength(text string) int {
+	urlCount := len(tweetUrlRegexp.FindAllStringIndex(text, -1))
+	return urlCount*tweetUrlWeightedLength + weightedRuneCount(tweetUrlRegexp.ReplaceAllString(text, ""))
+}
+
+func weightedRuneCount(text string) int {
+	length := 0
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+		length += runeWeight(r)
+	}
+
+	return length
+}
+
+func runeWeight(r rune) int {
+	for _, v := range tweetLightRanges {
+		if r >= v[0] && r <= v[1] {
+			return 1
+		}
+	}
+
+	return 2
+}
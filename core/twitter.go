@@ -2,6 +2,7 @@ package core
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"sort"
@@ -11,10 +12,12 @@ import (
 
 	"github.com/ChimeraCoder/anaconda"
 	"github.com/g8rswimmer/go-twitter/v2"
+	"github.com/go-co-op/gocron"
 	"github.com/project-miko/miko/conf"
 	"github.com/project-miko/miko/models"
 	"github.com/project-miko/miko/models/data"
 	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/taskpool"
 	"github.com/project-miko/miko/tools"
 	"github.com/project-miko/miko/tools/log"
 	"github.com/shopspring/decimal"
@@ -27,17 +30,33 @@ const (
 
 	RouteDeFlashGetThread  = "/twitter/getthreadwithtweeturl"
 	RouteRSSHubTweetDetail = "/twitter/tweet"
+
+	// a fire waits for the undo window and the media processing at most, the lock outlives both
+	twScheduleFireLockTTL = 30 * time.Minute
+
+	twTokenRefreshLockTTL  = 30 * time.Second
+	twTokenRefreshLockPoll = 200 * time.Millisecond
+)
+
+var (
+	ErrTwScheduleFiring  = fmt.Errorf("the schedule is fired by another job")
+	ErrTwTokenRefreshing = fmt.Errorf("the token of the account is refreshed by another one for too long")
+)
+
+const (
+	TaskTypeTwDeferredFire   = "tw_deferred_fire"
+	TaskTypeTwHeldThreadPost = "tw_held_thread_post"
 )
 
+// RegisterTaskHandlers register the handlers of the task types run by the task pool
+func RegisterTaskHandlers() {
+	taskpool.RegisterHandler(TaskTypeTwDeferredFire, handleTwDeferredFireTask, 0, twScheduleFireLockTTL)
+	taskpool.RegisterHandler(TaskTypeTwHeldThreadPost, handleTwHeldThreadPostTask, 0, twScheduleFireLockTTL)
+}
+
 // getUserMap user_id -> UserObj
 func getUserMap(userIds []string, token string, totalReqCount *int) (map[string]*twitter.UserObj, error) {
-	maxResults := 100
-	twAPI, err := twitterapi.NewTwitterAPI(token, maxResults)
-	if err != nil {
-		return nil, fmt.Errorf("twitterapi.NewTwitterAPI(%s, %d) error %s", token, maxResults, err.Error())
-	}
-
-	userRaw, err := twAPI.GetFollowerCount(userIds)
+	userRaw, err := twitterapi.NewTwitterClient(twitterapi.AppOwner, token, "", "").GetFollowerCount(userIds)
 	if err != nil {
 		return nil, fmt.Errorf("twAPI.GetFollowerCount() error %s", err.Error())
 	}
@@ -302,11 +321,33 @@ func Div(dividend, divisor int) decimal.Decimal {
 	return decimal.NewFromInt(int64(dividend) * 100).Div(decimal.NewFromInt(int64(divisor))).Truncate(4)
 }
 
+// GetTweetResp the normalized thread of a tweet, Tweets are the texts of the Items
 type GetTweetResp struct {
-	Account   string   `json:"account"`
-	Avatar    string   `json:"avatar"`
-	Tweets    []string `json:"tweets"`
-	PublishAt int64    `json:"publish_at"`
+	Account        string          `json:"account"`
+	Avatar         string          `json:"avatar"`
+	Tweets         []string        `json:"tweets"`
+	PublishAt      int64           `json:"publish_at"`
+	Name           string          `json:"name"`
+	AuthorId       string          `json:"author_id"`
+	ConversationId string          `json:"conversation_id"`
+	Items          []*GetTweetItem `json:"items"`
+	Backend        string          `json:"backend"` // api or the name of the backend the thread is fetched with
+}
+
+type GetTweetItem struct {
+	TweetId      string           `json:"tweet_id"`
+	Text         string           `json:"text"`
+	CreatedAt    int64            `json:"created_at"`
+	LikeCount    int              `json:"like_count"`
+	RetweetCount int              `json:"retweet_count"`
+	Media        []*GetTweetMedia `json:"media"`
+}
+
+type GetTweetMedia struct {
+	Type       string                     `json:"type"`        // photo, video or animated_gif
+	Url        string                     `json:"url"`         // the photo, or the mp4 variant of the highest bit rate
+	PreviewUrl string                     `json:"preview_url"` // the preview image of a video
+	Variants   []*twitter.MediaVariantObj `json:"variants,omitempty"`
 }
 
 func mapToStruct(m map[string]interface{}, out interface{}) error {
@@ -341,9 +382,34 @@ func (e *ErrCreateTweet) Error() string {
 	return e.Err.Error()
 }
 
+// RefreshAccessToken refresh the oauth2 token of the account if it is about to expire, the account is reloaded.
+// twitter rotates the refresh token, so the refreshes of an account are serialized by a redis lock
+// and a token refreshed by another one meanwhile is taken as it is
 func RefreshAccessToken(account *models.TwAccount) error {
+	if !isTwAccessTokenExpiring(account, time.Now()) {
+		return nil
+	}
+
+	unlock, err := lockTwTokenRefresh(account.UserId)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	latest, err := models.GetTwAccountByUserId(account.UserId)
+	if err != nil {
+		return err
+	}
+	if latest == nil {
+		return conf.ErrRecordNotFound
+	}
+	*account = *latest
+	if account.Status == models.TwAccountStatusRevoked {
+		return ErrTwAccountRevoked
+	}
+
 	now := time.Now()
-	if account.ExpiredAt > tools.GetMillisecond(now.Add(10*time.Minute)) { // refresh 10 minutes before expired
+	if !isTwAccessTokenExpiring(account, now) {
 		return nil
 	}
 
@@ -366,14 +432,113 @@ func RefreshAccessToken(account *models.TwAccount) error {
 	return nil
 }
 
+// isTwAccessTokenExpiring the token is refreshed 10 minutes before it expires
+func isTwAccessTokenExpiring(account *models.TwAccount, now time.Time) bool {
+	return account.ExpiredAt <= tools.GetMillisecond(now.Add(10*time.Minute))
+}
+
+// lockTwTokenRefresh wait for the refresh lock of the account, a refresh takes a request to the token endpoint
+func lockTwTokenRefresh(userId string) (func(), error) {
+	key := fmt.Sprintf(conf.AISERTwTokenRefreshLock, userId)
+	deadline := time.Now().Add(twTokenRefreshLockTTL)
+	for {
+		unlock, ok, err := redisLock(key, twTokenRefreshLockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return unlock, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrTwTokenRefreshing
+		}
+		time.Sleep(twTokenRefreshLockPoll)
+	}
+}
+
+// RefreshAllAccessTokens refresh the access tokens about to expire, an account failed does not stop the others,
+// the accounts whose refresh token is dead are marked revoked
+func RefreshAllAccessTokens() error {
+	accounts, err := models.GetAllAccountList("", "")
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, account := range accounts {
+		if account.Status == models.TwAccountStatusRevoked {
+			continue
+		}
+
+		if err := RefreshAccessToken(account); err != nil {
+			if errors.Is(err, ErrTwAccountRevoked) { // revoked after the list is loaded
+				continue
+			}
+			if err = handleTwAuthError(account, "oauth2", err); err != nil {
+				log.Error("", "RefreshAccessToken() userId:%s error %s", account.UserId, err.Error())
+				failed++
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("refresh token failed for %d of %d accounts", failed, len(accounts))
+	}
+
+	return nil
+}
+
 var JobHandleFunc = func(userId string, twScheduleLibId int64) {
+	handleJob(userId, twScheduleLibId, false)
+}
+
+// deferredJobHandle runs a fire deferred by a blackout window, the caps or the media processing
+func deferredJobHandle(userId string, twScheduleLibId int64) {
+	handleJob(userId, twScheduleLibId, true)
+}
+
+// twDeferredFireTask the payload of TaskTypeTwDeferredFire, RunAt is the DeferredRunAt of the schedule it was enqueued for
+type twDeferredFireTask struct {
+	UserId          string `json:"user_id"`
+	TwScheduleLibId int64  `json:"tw_schedule_lib_id"`
+	RunAt           int64  `json:"run_at"`
+}
+
+// handleTwDeferredFireTask run the deferred fire of the schedule, the task is dropped if the schedule is gone or paused,
+// or the fire has run or moved. the fire records its own result, it is never retried by the task pool
+func handleTwDeferredFireTask(task *taskpool.Task) error {
+	payload := new(twDeferredFireTask)
+	if err := task.Unmarshal(payload); err != nil {
+		return err
+	}
+
+	twSchedule, err := models.GetTwScheduleByUserIdAndTwLibId(payload.UserId, payload.TwScheduleLibId)
+	if err != nil {
+		return err
+	}
+	if twSchedule == nil || twSchedule.DeferredRunAt != payload.RunAt {
+		log.Info("", "drop the deferred fire of %s at %d", GetTag(payload.UserId, payload.TwScheduleLibId), payload.RunAt)
+		return nil
+	}
+
+	deferredJobHandle(payload.UserId, payload.TwScheduleLibId)
+	return nil
+}
+
+func handleJob(userId string, twScheduleLibId int64, deferred bool) {
 	log.Info("", "job callback function execution start")
 
-	now := tools.GetMillisecond(time.Now())
-	err := doJobHandle(userId, twScheduleLibId)
+	tag := GetTag(userId, twScheduleLibId)
+	setTwPostQueueFiring(tag, true)
+	defer setTwPostQueueFiring(tag, false)
+
+	execLog := &models.ScheduleLog{
+		JobId:  tag,
+		UserId: userId,
+		ExecAt: tools.GetMillisecond(time.Now()),
+	}
+	err := doJobHandle(userId, twScheduleLibId, deferred, execLog)
 
-	jobId := fmt.Sprintf("%s-%d", userId, twScheduleLibId)
-	if e := models.SaveScheduleLog(jobId, now, err); e != nil {
+	if e := models.SaveScheduleLog(execLog, scheduleExecStatus(err), err); e != nil {
 		errMsg := e.Error()
 		log.Error("", "doJobHandle() error %s", errMsg)
 		return
@@ -382,8 +547,18 @@ var JobHandleFunc = func(userId string, twScheduleLibId int64) {
 	log.Info("", "job callback function execution success")
 }
 
-func doJobHandle(userId string, twScheduleLibId int64) error {
-	// todo add lock
+// doJobHandle post the thread of the schedule, the schedule and the posted tweets are recorded to execLog
+func doJobHandle(userId string, twScheduleLibId int64, deferred bool, execLog *models.ScheduleLog) error {
+	// the deferred fire and the regular fire of a schedule never post at the same time
+	unlock, ok, err := redisLock(fmt.Sprintf(conf.AISERTwScheduleFireLock, GetTag(userId, twScheduleLibId)), twScheduleFireLockTTL)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTwScheduleFiring
+	}
+	defer unlock()
+
 	twSchedule, err := models.GetTwScheduleByUserIdAndTwLibId(userId, twScheduleLibId)
 	if err != nil {
 		return err
@@ -391,10 +566,47 @@ func doJobHandle(userId string, twScheduleLibId int64) error {
 	if twSchedule == nil {
 		return conf.ErrRecordNotFound
 	}
+	execLog.TwScheduleId = twSchedule.Id
+	if deferred {
+		twSchedule.DeferredRunAt = 0
+	}
 
-	nextRunAt := new(int64)
-	err = doUploadTwMediaAndCreateTweet(userId, twScheduleLibId, nextRunAt)
+	window, err := models.GetActiveTwBlackoutWindow(userId, tools.GetMillisecond(time.Now()))
+	if err != nil {
+		return err
+	}
+	if window != nil {
+		return handleTwScheduleBlackout(twSchedule, window)
+	}
+
+	quotaErr, err := checkTwFireQuota(twSchedule)
 	if err != nil {
+		return err
+	}
+	if quotaErr != nil {
+		return handleTwScheduleQuotaExceeded(twSchedule, quotaErr)
+	}
+
+	nextRunAt := new(int64)
+	tweetIds := new([]string)
+	err = doUploadTwMediaAndCreateTweet(twSchedule, nextRunAt, tweetIds)
+	execLog.TweetIds = strings.Join(*tweetIds, ",")
+	var processingErr *ErrTwMediaProcessing
+	if errors.As(err, &processingErr) { // nothing is posted yet, the fire runs again once the media is processed
+		return handleTwScheduleMediaProcessing(twSchedule, processingErr)
+	}
+	var heldErr *ErrTwThreadPostHeld
+	if errors.As(err, &heldErr) { // nothing is posted yet, the fire runs again once the hold ends
+		return handleTwScheduleThreadPostHeld(twSchedule, heldErr)
+	}
+	if errors.Is(err, ErrEmergencyStop) || errors.Is(err, ErrTwThreadPostUndone) || errors.Is(err, ErrTwThreadPostClaimed) ||
+		errors.Is(err, twitterapi.ErrRateLimitWaitStopped) { // the fire is consumed, the schedule goes on
+		twSchedule.RemainCount--
+		if twSchedule.RemainCount <= 0 {
+			twSchedule.Status = models.TwScheduleStatusFinished
+		}
+		twSchedule.NextRunAt, _ = getTwCreateTweetJobNextRunAt(userId, twScheduleLibId)
+	} else if err != nil {
 		err = fmt.Errorf("doUploadTwMediaAndCreateTweet() error, %w", err)
 		twSchedule.Status = models.TwScheduleStatusError
 	} else {
@@ -409,95 +621,101 @@ func doJobHandle(userId string, twScheduleLibId int64) error {
 		return e
 	}
 
+	if e := onTwPostQueueScheduleDone(twSchedule, err); e != nil {
+		log.Error("", "onTwPostQueueScheduleDone() error %s", e.Error())
+	}
+
 	return err
 }
 
-func doUploadTwMediaAndCreateTweet(userId string, twScheduleLibId int64, nextRunAt *int64) error {
+func doUploadTwMediaAndCreateTweet(twSchedule *models.TwSchedule, nextRunAt *int64, postedTweetIds *[]string) error {
+	userId := twSchedule.UserId
+	twScheduleLibId := twSchedule.TwScheduleLibId
 	twScheduleLib, err := models.GetTwScheduleLibById(twScheduleLibId)
 	if err != nil {
 		return err
 	}
+	if twScheduleLib == nil {
+		return conf.ErrRecordNotFound
+	}
 
-	items := make([]*data.TwAddTweetScheduleReqItem, 0)
-	err = json.Unmarshal([]byte(twScheduleLib.Content), &items)
+	twClient, err := getTwUserClient(userId)
 	if err != nil {
 		return err
 	}
 
-	uploadFiles := make([]*data.TwUploadFileReqItem, 0)
-	for _, item := range items {
-		for _, v2 := range item.MediaUrls {
-			reqItem := new(data.TwUploadFileReqItem)
-			reqItem.Id = item.SortId
-			reqItem.MediaUrl = v2
-			uploadFiles = append(uploadFiles, reqItem)
-		}
-	}
-
-	uReq := new(data.TwUploadMediaReq)
-	uReq.UserId = userId
-	uReq.UploadFiles = uploadFiles
-
-	mediaResp, err := UploadTwMedia(uReq)
+	// the thread failed halfway, waiting for its media or stopped by a crash in the last fire is resumed
+	// instead of posting a new one
+	post, err := models.GetResumableTwThreadPostByScheduleId(twSchedule.Id, getTwThreadPostStaleBefore(time.Now()))
 	if err != nil {
 		return err
 	}
+	var items []*models.TwThreadPostItem
+	if post != nil {
+		log.Info("", "resume thread post %d of schedule %d", post.Id, twSchedule.Id)
+		// a manual resume taking it meanwhile posts the thread of this fire
+		if err = claimTwThreadPost(post, models.TwThreadPostStatusPartial, models.TwThreadPostStatusPending); err != nil {
+			return err
+		}
+		if items, err = models.GetTwThreadPostItemList(post.Id); err != nil {
+			return err
+		}
+	} else {
+		thread, err := resolveTwScheduleThread(twSchedule, twScheduleLib)
+		if err != nil {
+			return err
+		}
+		// the thread is saved before its media is waited for, the fire deferred by the processing posts the same thread
+		items = newTwThreadPostItems(thread.Items)
+		post, err = createTwThreadPost(userId, models.PostedTweetSourceSchedule, twSchedule.Id, thread.TweetLibId, items)
+		if err != nil {
+			return err
+		}
+	}
 
-	m := make(map[string][]string)
-	for _, item := range mediaResp.MediaItems {
-		if len(item.ErrMsg) != 0 {
-			return fmt.Errorf("UploadTwMedia() error %s", item.ErrMsg)
+	if err = prepareTwThreadPostMedia(twClient, post, items); err != nil {
+		e := failTwThreadPostMedia(post, items, err)
+		if errors.Is(e, ErrTwThreadPostUndone) { // undone while its media is prepared, the fire is not deferred for it
+			return e
 		}
-		m[item.Id] = append(m[item.Id], item.MediaId)
-	}
-
-	tweets := make([]*data.CreateTweetItem, 0)
-	for _, v := range items {
-		mediaIds := m[v.SortId]
-		ci := new(data.CreateTweetItem)
-		ci.MediaIds = mediaIds
-		ci.SortId = v.SortId
-		ci.Text = v.Text
-		tweets = append(tweets, ci)
-	}
-
-	req := new(data.CreateTweetReq)
-	req.UserId = userId
-	req.Tweets = tweets
-
-	time.Sleep(1 * time.Second)
-	const maxRetryCount = 6
-	const waitSec = 10                   // use fixed time to wait
-	for i := 0; i < maxRetryCount; i++ { // max wait 1 minute
-		err = CreateTweet(req)
-		if err == nil { // send success, exit loop
-			break
+		if e != nil {
+			log.Error("", "failTwThreadPostMedia() error %s", e.Error())
 		}
+		return err
+	}
 
-		if _, ok := err.(*ErrGetMediaUploadStatusInProgress); ok { // if send failed, and return Twitter is uploading status, then wait and retry
-			if i == maxRetryCount-1 {
-				return fmt.Errorf("create tweet error after max retry")
-			}
-			log.Error("", "call core.CreateTweet() retry")
-			//waitSec := e.PInfo.CheckAfterSecs
-			time.Sleep(time.Duration(waitSec) * time.Second)
-		} else { // if not uploading status, return directly
+	// the fire is deferred to the end of the hold instead of waiting in the worker, it can be undone meanwhile
+	if post.HoldUntil > tools.GetMillisecond(time.Now()) {
+		heldErr := &ErrTwThreadPostHeld{HoldUntil: post.HoldUntil}
+		if err = pendTwThreadPost(post, heldErr); err != nil {
 			return err
 		}
+		return heldErr
 	}
 
-	tag := GetTag(userId, twScheduleLibId)
-	jobs, err := scheduler.Scheduler.FindJobsByTag(tag)
+	tweetIds, err := postTwThread(twClient, post, items)
+	*postedTweetIds = append(*postedTweetIds, tweetIds...)
 	if err != nil {
 		return err
 	}
+
+	*nextRunAt, err = getTwCreateTweetJobNextRunAt(userId, twScheduleLibId)
+
+	return err
+}
+
+// getTwCreateTweetJobNextRunAt the next run time of the create tweet job, 0 if the job is gone
+func getTwCreateTweetJobNextRunAt(userId string, twScheduleLibId int64) (int64, error) {
+	jobs, err := scheduler.Scheduler.FindJobsByTag(GetTag(userId, twScheduleLibId))
+	if err != nil && err != gocron.ErrJobNotFoundWithTag {
+		return 0, err
+	}
+	// the job is removed by the scheduler once it reaches its run limit, there is no next run then
 	if len(jobs) == 0 {
-		return fmt.Errorf("can not found job by tag. %s", tag)
+		return 0, nil
 	}
 
-	*nextRunAt = tools.GetMillisecond(jobs[0].NextRun())
-
-	return nil
+	return tools.GetMillisecond(jobs[0].NextRun()), nil
 }
 
 func GetTag(userId string, twScheduleLibId int64) string {
@@ -523,11 +741,8 @@ func ReloadTwCreateTweetJobsFromDB() error {
 		return err
 	}
 
-	s := GetScheduler()
 	for _, v := range list {
-		scheduler.SetJobFuncAndParams(JobHandleFunc, v.UserId, v.TwScheduleLibId)
-		tag := GetTag(v.UserId, v.TwScheduleLibId)
-		j, err := s.Add(v.CronExpression, tag, v.RemainCount, v.NextRunAt)
+		j, err := addTwCreateTweetJob(v)
 		if err != nil {
 			return fmt.Errorf("scheduler.Add() error %s", err.Error())
 		}
@@ -545,6 +760,38 @@ func ReloadTwCreateTweetJobsFromDB() error {
 	return nil
 }
 
+// addTwCreateTweetJob add the create tweet job of the schedule to the scheduler
+func addTwCreateTweetJob(ts *models.TwSchedule) (*gocron.Job, error) {
+	jobLocker.Lock()
+	defer jobLocker.Unlock()
+
+	scheduler.SetJobFuncAndParams(JobHandleFunc, ts.UserId, ts.TwScheduleLibId)
+	tag := GetTag(ts.UserId, ts.TwScheduleLibId)
+	return scheduler.Add(ts.CronExpression, tag, ts.RemainCount, ts.NextRunAt)
+}
+
+// addTwDeferredJob enqueue the deferred fire of the schedule to run at DeferredRunAt, the task outlives a restart
+func addTwDeferredJob(ts *models.TwSchedule) error {
+	payload := &twDeferredFireTask{
+		UserId:          ts.UserId,
+		TwScheduleLibId: ts.TwScheduleLibId,
+		RunAt:           ts.DeferredRunAt,
+	}
+
+	return taskpool.EnqueueAt(TaskTypeTwDeferredFire, payload, time.UnixMilli(ts.DeferredRunAt))
+}
+
+// removeTwCreateTweetJob remove the create tweet job of the schedule from the scheduler, a job that does not exist is ignored.
+// a pending deferred fire is dropped when it runs as the schedule is no longer unfinished
+func removeTwCreateTweetJob(ts *models.TwSchedule) error {
+	err := scheduler.Remove(GetTag(ts.UserId, ts.TwScheduleLibId))
+	if err != nil && err != gocron.ErrJobNotFoundWithTag {
+		return err
+	}
+
+	return nil
+}
+
 func UploadTwMedia(req *data.TwUploadMediaReq) (*data.TwUploadMediaResp, error) {
 	log.Info("", "upload media start. user_id: %s, the length of media: %d", req.UserId, len(req.UploadFiles))
 	uploadFiles := req.UploadFiles
@@ -558,7 +805,7 @@ func UploadTwMedia(req *data.TwUploadMediaReq) (*data.TwUploadMediaResp, error)
 		return nil, conf.ErrRecordNotFound
 	}
 
-	twApi := twitterapi.NewTwitterAPIV1(exists.AccessToken, exists.AccessSecret)
+	twClient := twitterapi.NewTwitterClient(userId, "", exists.AccessToken, exists.AccessSecret)
 
 	resp := new(data.TwUploadMediaResp)
 	items := make([]*data.TwMediaRespItem, 0)
@@ -567,13 +814,14 @@ func UploadTwMedia(req *data.TwUploadMediaReq) (*data.TwUploadMediaResp, error)
 		item.Id = v.Id
 		item.Status = twitterapi.UploadMediaSucceeded
 
-		mediaId, err := twApi.UploadMediaFromUrl(v.MediaUrl)
+		media, err := twClient.UploadMediaFromUrl(v.MediaUrl, v.AltText)
 		if err != nil {
 			log.Error("", "twitterapi.UploadMediaFromUrl() error %s", err.Error())
 			item.ErrMsg = err.Error()
 			item.Status = twitterapi.UploadMediaFailed
+		} else {
+			item.MediaId = media.MediaIDString
 		}
-		item.MediaId = mediaId
 		items = append(items, item)
 	}
 
@@ -582,7 +830,9 @@ func UploadTwMedia(req *data.TwUploadMediaReq) (*data.TwUploadMediaResp, error)
 	return resp, nil
 }
 
-func CreateTweet(req *data.CreateTweetReq) error {
+// CreateTweet post the tweets of the request as a thread, a thread held in the undo window is left pending and posted
+// by a task once the hold ends, the thread post is returned at once so it can be undone
+func CreateTweet(req *data.CreateTweetReq) (*TwThreadPostDetail, error) {
 	log.Info("", "create tweet start, userId:%s. the length of tweets: %d", req.UserId, len(req.Tweets))
 	userId := req.UserId
 	tweetItems := req.Tweets
@@ -602,96 +852,110 @@ func CreateTweet(req *data.CreateTweetReq) error {
 	//	return
 	//}
 
-	account, err := models.GetTwAccountByUserId(userId)
-	if err != nil {
-		return fmt.Errorf("models.GetTwAccountByUserId() error %s", err.Error())
-	}
-	if account == nil {
-		return conf.ErrRecordNotFound
-	}
-
-	twOAuth1, err := models.GetTwOAuth1ByUserId(userId)
+	twClient, err := getTwUserClient(userId)
 	if err != nil {
-		return fmt.Errorf("models.GetTwOAuth1ByUserId() error %s", err.Error())
-	}
-	if twOAuth1 == nil {
-		return conf.ErrRecordNotFound
+		return nil, err
 	}
 
-	twApiV1 := twitterapi.NewTwitterAPIV1(twOAuth1.AccessToken, twOAuth1.AccessSecret)
 	for _, v := range tweetItems {
 		for _, mediaId := range v.MediaIds {
-			mediaResp, err := twApiV1.GetMediaUploadStatus(mediaId)
+			mediaResp, err := twClient.GetMediaUploadStatus(mediaId)
 			if err != nil {
 				if e, ok := err.(*anaconda.ApiError); ok {
 					if e.StatusCode == http.StatusNotFound {
 						continue
 					}
 				}
-				return fmt.Errorf("twitter.GetMediaUploadStatus() error %w", err)
+				return nil, fmt.Errorf("twitter.GetMediaUploadStatus() error %w", err)
 			}
 
 			pInfo := mediaResp.ProcessingInfo
 			switch pInfo.State {
 			case twitterapi.UploadMediaInProgress:
 				if pInfo.Error != nil {
-					return &ErrGetMediaUploadStatusFailed{Err: pInfo.Error}
+					return nil, &ErrGetMediaUploadStatusFailed{Err: pInfo.Error}
 				}
 
-				return &ErrGetMediaUploadStatusInProgress{PInfo: pInfo}
+				return nil, &ErrGetMediaUploadStatusInProgress{PInfo: pInfo}
 			case twitterapi.UploadMediaFailed:
-				return &ErrGetMediaUploadStatusFailed{Err: pInfo.Error}
+				return nil, &ErrGetMediaUploadStatusFailed{Err: pInfo.Error}
 			}
 		}
 	}
 
-	if err = RefreshAccessToken(account); err != nil {
-		return fmt.Errorf("RefreshAccessToken() error %s", err.Error())
-	}
-
-	twApi, err := twitterapi.NewTwitterAPI(account.AccessToken, -1)
-	if err != nil {
-		return err
-	}
-
 	sort.SliceStable(tweetItems, func(i, j int) bool {
 		ai, _ := strconv.Atoi(tweetItems[i].SortId)
 		aj, _ := strconv.Atoi(tweetItems[j].SortId)
 		return ai < aj
 	})
 
-	successTweetIds := make([]string, 0)
-	tempInReplyToTweetID := ""
-	for _, v := range tweetItems {
-		createTweetReq := &twitter.CreateTweetRequest{
-			Text: v.Text,
+	now := tools.GetMillisecond(time.Now())
+	items := make([]*models.TwThreadPostItem, 0, len(tweetItems))
+	for i, v := range tweetItems {
+		item := &models.TwThreadPostItem{
+			Seq:       i,
+			SortId:    v.SortId,
+			Text:      v.Text,
+			Status:    models.TwThreadPostItemStatusPending,
+			CreatedAt: now,
 		}
-		if len(v.MediaIds) != 0 {
-			createTweetReq.Media = &twitter.CreateTweetMedia{
-				IDs: v.MediaIds,
-			}
+		item.SetMediaIds(v.MediaIds)
+		items = append(items, item)
+	}
+
+	post, err := createTwThreadPost(userId, models.PostedTweetSourceManual, 0, 0, items)
+	if err != nil {
+		return nil, err
+	}
+	detail := &TwThreadPostDetail{TwThreadPost: post, Items: items}
+
+	if post.HoldUntil > 0 {
+		if err = pendTwThreadPost(post, &ErrTwThreadPostHeld{HoldUntil: post.HoldUntil}); err != nil {
+			return nil, err
 		}
-		if len(tempInReplyToTweetID) != 0 {
-			createTweetReq.Reply = &twitter.CreateTweetReply{
-				InReplyToTweetID: tempInReplyToTweetID,
+		if err = addTwHeldThreadPostTask(post); err != nil {
+			// a post left pending without its task is never posted, it is resumed by hand as a failed one
+			if e := finishTwThreadPost(post, items, err); e != nil {
+				log.Error("", "finishTwThreadPost() error %s", e.Error())
 			}
+			return nil, err
 		}
+		return detail, nil
+	}
 
-		log.Info("", "create tweet start, userId:%s", userId)
-		resp, err := twApi.CreateTweet(createTweetReq)
-		SaveUserRateLimitCreateTweet(userId, resp, err)
-		if err != nil {
-			return &ErrCreateTweet{Err: err}
-		}
+	if _, err = postTwThread(twClient, post, items); err != nil {
+		return nil, err
+	}
+
+	return detail, nil
+}
+
+// getTwUserClient the client of the account with its oauth2 token refreshed and its oauth1 credentials
+func getTwUserClient(userId string) (twitterapi.TwitterClient, error) {
+	account, err := models.GetTwAccountByUserId(userId)
+	if err != nil {
+		return nil, fmt.Errorf("models.GetTwAccountByUserId() error %s", err.Error())
+	}
+	if account == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+	if account.Status == models.TwAccountStatusRevoked {
+		return nil, ErrTwAccountRevoked
+	}
 
-		respTweetId := resp.Tweet.ID
-		tempInReplyToTweetID = respTweetId
+	twOAuth1, err := models.GetTwOAuth1ByUserId(userId)
+	if err != nil {
+		return nil, fmt.Errorf("models.GetTwOAuth1ByUserId() error %s", err.Error())
+	}
+	if twOAuth1 == nil {
+		return nil, conf.ErrRecordNotFound
+	}
 
-		log.Info("", "create tweet success, userId:%s, tweetId:%s, inReplyToTweetID:%s", userId, respTweetId, tempInReplyToTweetID)
-		successTweetIds = append(successTweetIds, respTweetId)
+	if err = RefreshAccessToken(account); err != nil {
+		return nil, fmt.Errorf("RefreshAccessToken() error %s", err.Error())
 	}
 
-	return nil
+	return twitterapi.NewTwitterClient(userId, account.AccessToken, twOAuth1.AccessToken, twOAuth1.AccessSecret), nil
 }
 
 type AddTweetScheduleParams struct {
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/tools"
+)
+
+// createTwScheduleLib save the thread list as a new schedule lib
+func createTwScheduleLib(threadList []*data.TwAddTweetScheduleReqItem) (*models.TwScheduleLib, error) {
+	b, err := json.Marshal(threadList)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := &models.TwScheduleLib{
+		Content:   string(b),
+		CreatedAt: tools.GetMillisecond(time.Now()),
+	}
+	if err = lib.Save(); err != nil {
+		return nil, err
+	}
+
+	return lib, nil
+}
+
+// copyTwScheduleLib copy the content of an exists schedule lib to a new one, so that every job has its own lib and tag
+func copyTwScheduleLib(twScheduleLibId int64) (*models.TwScheduleLib, error) {
+	src, err := models.GetTwScheduleLibById(twScheduleLibId)
+	if err != nil {
+		return nil, err
+	}
+	if src == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	lib := &models.TwScheduleLib{
+		Content:   src.Content,
+		CreatedAt: tools.GetMillisecond(time.Now()),
+	}
+	if err = lib.Save(); err != nil {
+		return nil, err
+	}
+
+	return lib, nil
+}
+
+func getTwScheduleLibThreadList(lib *models.TwScheduleLib) ([]*data.TwAddTweetScheduleRespItem, error) {
+	items := make([]*data.TwAddTweetScheduleRespItem, 0)
+	if err := json.Unmarshal([]byte(lib.Content), &items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// twScheduleThread the thread a schedule posts when it fires, TweetLibId is the evergreen content of the thread
+type twScheduleThread struct {
+	Items      []*data.TwAddTweetScheduleReqItem
+	TweetLibId int64
+}
+
+// resolveTwScheduleThread get the thread to post by the type of the schedule lib
+func resolveTwScheduleThread(twSchedule *models.TwSchedule, lib *models.TwScheduleLib) (*twScheduleThread, error) {
+	switch lib.Type {
+	case models.TwScheduleLibTypeEvergreen:
+		return resolveEvergreenThread(twSchedule, lib)
+	case models.TwScheduleLibTypeGenerated:
+		return resolveGeneratedThread(twSchedule, lib)
+	default:
+		items := make([]*data.TwAddTweetScheduleReqItem, 0)
+		if err := json.Unmarshal([]byte(lib.Content), &items); err != nil {
+			return nil, err
+		}
+		return &twScheduleThread{Items: items}, nil
+	}
+}
+
+// GetLoopCronExp the cron expression that fires every loopUnit weeks, weekDay 1-7 means Monday to Sunday
+func GetLoopCronExp(weekDay, hour, minute, loopUnit int) string {
+	return fmt.Sprintf("0 %d %d * * %d/%d", minute, hour, weekDay, loopUnit*7)
+}
+
+// AddTweetSchedule save the schedule and add its job to the scheduler, a thread lib is created from the ThreadList if lib is nil
+func AddTweetSchedule(params *AddTweetScheduleParams, lib *models.TwScheduleLib) (*models.TwSchedule, error) {
+	var err error
+	if lib == nil {
+		lib, err = createTwScheduleLib(params.ThreadList)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	twSchedule := &models.TwSchedule{
+		UserId:          params.UserId,
+		TwScheduleLibId: lib.Id,
+		CronExpression:  params.CronExp,
+		TotalCount:      params.LoopCount,
+		RemainCount:     params.LoopCount,
+		SourceType:      params.SourceType,
+		Status:          models.TwScheduleStatusUnFinished,
+		NextRunAt:       -1,
+		CreatedAt:       tools.GetMillisecond(time.Now()),
+	}
+
+	j, err := addTwCreateTweetJob(twSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("addTwCreateTweetJob() error %s", err.Error())
+	}
+	twSchedule.NextRunAt = tools.GetMillisecond(j.NextRun())
+
+	if err = twSchedule.Save(); err != nil {
+		_ = removeTwCreateTweetJob(twSchedule)
+		return nil, err
+	}
+
+	return twSchedule, nil
+}
+
+// addTwScheduleWithLibConf save the conf as a schedule lib of the type and add a looping schedule with it
+func addTwScheduleWithLibConf(userId string, libType int, libConf interface{}, loop *data.TwScheduleLoopReq) (*models.TwSchedule, error) {
+	b, err := json.Marshal(libConf)
+	if err != nil {
+		return nil, err
+	}
+
+	lib := &models.TwScheduleLib{
+		Type:      libType,
+		Content:   string(b),
+		CreatedAt: tools.GetMillisecond(time.Now()),
+	}
+	if err = lib.Save(); err != nil {
+		return nil, err
+	}
+
+	params := &AddTweetScheduleParams{
+		UserId:    userId,
+		LoopCount: loop.LoopCount,
+		CronExp:   GetLoopCronExp(loop.WeekDay, loop.Hour, loop.Minute, loop.LoopUnit),
+	}
+
+	return AddTweetSchedule(params, lib)
+}
+
+var (
+	ErrPauseTwPostQueueSchedule = fmt.Errorf("schedule of the posting queue can not be paused, remove it from the queue instead")
+)
+
+// PauseTwSchedule remove the jobs of the schedule from the scheduler, RemainCount and the deferred fire are kept for resuming
+func PauseTwSchedule(scheduleId int64) (*models.TwSchedule, error) {
+	twSchedule, err := models.GetTwScheduleById(scheduleId, models.TwScheduleStatusUnFinished)
+	if err != nil {
+		return nil, err
+	}
+	if twSchedule == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	item, err := models.GetTwPostQueueByScheduleId(twSchedule.Id)
+	if err != nil {
+		return nil, err
+	}
+	if item != nil {
+		return nil, ErrPauseTwPostQueueSchedule
+	}
+
+	if err = removeTwCreateTweetJob(twSchedule); err != nil {
+		return nil, err
+	}
+
+	twSchedule.Status = models.TwScheduleStatusPaused
+	if err = twSchedule.Update(); err != nil {
+		return nil, err
+	}
+
+	return twSchedule, nil
+}
+
+// ResumeTwSchedule add the jobs of a paused schedule back, a looping schedule goes on from its next run after now
+func ResumeTwSchedule(scheduleId int64) (*models.TwSchedule, error) {
+	twSchedule, err := models.GetTwScheduleById(scheduleId, models.TwScheduleStatusPaused)
+	if err != nil {
+		return nil, err
+	}
+	if twSchedule == nil {
+		return nil, conf.ErrRecordNotFound
+	}
+
+	runTimes, err := NextScheduleRunTimes(twSchedule.CronExpression, twSchedule.NextRunAt, 1)
+	if err != nil {
+		return nil, err
+	}
+	twSchedule.NextRunAt = -1
+	if len(runTimes) > 0 {
+		twSchedule.NextRunAt = tools.GetMillisecond(runTimes[0])
+	}
+
+	j, err := addTwCreateTweetJob(twSchedule)
+	if err != nil {
+		return nil, fmt.Errorf("addTwCreateTweetJob() error %s", err.Error())
+	}
+	twSchedule.NextRunAt = tools.GetMillisecond(j.NextRun())
+
+	if twSchedule.DeferredRunAt > 0 {
+		if err = addTwDeferredJob(twSchedule); err != nil {
+			_ = removeTwCreateTweetJob(twSchedule)
+			return nil, fmt.Errorf("addTwDeferredJob() error %s", err.Error())
+		}
+	}
+
+	twSchedule.Status = models.TwScheduleStatusUnFinished
+	if err = twSchedule.Update(); err != nil {
+		_ = removeTwCreateTweetJob(twSchedule)
+		return nil, err
+	}
+
+	return twSchedule, nil
+}
@@ -0,0 +1,126 @@
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/sdk/twitterapi"
+)
+
+// reserveTwRateLimitScript take a request from the remaining of each known window, none is taken if any of them
+// has none left, return the seconds to wait for all of them then
+var reserveTwRateLimitScript = redis.NewScript(1, `
+local now = tonumber(ARGV[1])
+local wait = 0
+local limits = {}
+for i = 2, #ARGV do
+	local v = redis.call('HGET', KEYS[1], ARGV[i])
+	if v then
+		local l = cjson.decode(v)
+		if now < l.reset then
+			if l.remaining > 0 then
+				limits[ARGV[i]] = l
+			elseif l.reset - now > wait then
+				wait = l.reset - now
+			end
+		end
+	end
+end
+if wait > 0 then
+	return wait
+end
+for field, l in pairs(limits) do
+	l.remaining = l.remaining - 1
+	redis.call('HSET', KEYS[1], field, cjson.encode(l))
+end
+return 0
+`)
+
+// twRedisStore the twitterapi.Store shared by the processes through redis
+type twRedisStore struct{}
+
+// NewTwStore the store of the twitter api state, it is set to twitterapi at start
+func NewTwStore() twitterapi.Store {
+	return new(twRedisStore)
+}
+
+func (s *twRedisStore) SaveState(key, value string, expire int64) error {
+	return models.GetRdbInst().SetString(key, value, expire)
+}
+
+func (s *twRedisStore) TakeState(key string) (string, error) {
+	str, err := models.GetRdbInst().GetDel(key)
+	if err == redis.ErrNil {
+		return "", twitterapi.ErrStateNotFound
+	}
+
+	return str, err
+}
+
+func (s *twRedisStore) SaveRateLimit(l *twitterapi.RateLimitInfo) error {
+	b, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	return models.GetRdbInst().Hset(conf.AISERTwRateLimit, twitterapi.RateLimitField(l.Owner, l.Endpoint), string(b))
+}
+
+func (s *twRedisStore) GetRateLimit(owner, endpoint string) (*twitterapi.RateLimitInfo, error) {
+	str, err := models.GetRdbInst().Hget(conf.AISERTwRateLimit, twitterapi.RateLimitField(owner, endpoint))
+	if err == redis.ErrNil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l := new(twitterapi.RateLimitInfo)
+	if err = json.Unmarshal([]byte(str), l); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+func (s *twRedisStore) GetRateLimitList() ([]*twitterapi.RateLimitInfo, error) {
+	all, err := models.GetRdbInst().Hgetall(conf.AISERTwRateLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*twitterapi.RateLimitInfo, 0, len(all))
+	for _, str := range all {
+		l := new(twitterapi.RateLimitInfo)
+		if err = json.Unmarshal([]byte(str), l); err != nil {
+			return nil, err
+		}
+		results = append(results, l)
+	}
+
+	return results, nil
+}
+
+func (s *twRedisStore) DelRateLimits(list []*twitterapi.RateLimitInfo) error {
+	fields := make([]string, 0, len(list))
+	for _, l := range list {
+		fields = append(fields, twitterapi.RateLimitField(l.Owner, l.Endpoint))
+	}
+
+	return models.GetRdbInst().Hdel(conf.AISERTwRateLimit, fields...)
+}
+
+func (s *twRedisStore) ReserveRateLimits(fields []string, now int64) (int64, error) {
+	client := models.GetRdbInst().Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	args := []interface{}{conf.AISERTwRateLimit, now}
+	for _, field := range fields {
+		args = append(args, field)
+	}
+	return redis.Int64(reserveTwRateLimitScript.Do(client, args...))
+}
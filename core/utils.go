@@ -2,17 +2,10 @@ package core
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"os"
 	"text/template"
-	"time"
 
-	"github.com/g8rswimmer/go-twitter/v2"
-	"github.com/gomodule/redigo/redis"
-	"github.com/project-miko/miko/conf"
-	"github.com/project-miko/miko/models"
-	"github.com/project-miko/miko/tools/log"
 	"github.com/shopspring/decimal"
 )
 
@@ -23,73 +16,6 @@ const (
 	up    = "↑"
 )
 
-func NewDefaultRateLimit() *twitter.RateLimit {
-	limit := new(twitter.RateLimit)
-	limit.Limit = 100
-	limit.Remaining = 100
-	limit.Reset = twitter.Epoch(time.Now().Unix())
-
-	return limit
-}
-
-func SaveUserRateLimitCreateTweet(userId string, resp *twitter.CreateTweetResponse, dstErr error) {
-	var err error
-	var limit *twitter.RateLimit
-
-	if resp != nil && resp.RateLimit != nil {
-		limit = resp.RateLimit
-	} else if dstErr != nil {
-		limit, _ = twitter.RateLimitFromError(dstErr)
-	}
-
-	if limit != nil {
-		if err = SaveUserRateLimit2Redis(userId, limit); err != nil {
-			log.Error("", "Error saving rate limit to Redis: %v", err)
-		}
-	}
-}
-
-func GetUserRateLimit(userId string) (*twitter.RateLimit, error) {
-	client := models.GetRdbInst()
-
-	key := fmt.Sprintf(conf.AISERAppRateLimitCreateTweet, userId)
-	jsonStr, err := client.GetString(key)
-	if err != nil {
-		if err == redis.ErrNil {
-			return NewDefaultRateLimit(), nil
-		}
-
-		return nil, err
-	}
-
-	limit := new(twitter.RateLimit)
-	err = json.Unmarshal([]byte(jsonStr), limit)
-	if err != nil {
-		return nil, err
-	}
-
-	if time.Now().Unix() > int64(limit.Reset) {
-		return NewDefaultRateLimit(), nil
-	}
-
-	return limit, nil
-}
-
-func SaveUserRateLimit2Redis(userId string, limit *twitter.RateLimit) error {
-	client := models.GetRdbInst()
-	b, err := json.Marshal(limit)
-	if err != nil {
-		return err
-	}
-	key := fmt.Sprintf(conf.AISERAppRateLimitCreateTweet, userId)
-	err = client.SetString(key, string(b), int64(1*24*time.Hour.Seconds()))
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
 func WrapColorForInteger(dst int) string {
 	color := ""
 	flag := ""
@@ -1,5 +1,104 @@
 package crond
 
-func InitCrond() {
-	// init schedule job
+import (
+	"fmt"
+	"time"
+
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+)
+
+func InitCrond() error {
+	crawlExp, err := crawlCronExp(conf.CrawlInterval)
+	if err != nil {
+		return err
+	}
+
+	jobs := []*core.CrondJob{
+		{
+			// crawl the last finished interval at start as well, it may have been missed while the process was down
+			Name:          "tw_daily_data",
+			CronExp:       crawlExp,
+			SkipIfRunning: true,
+			LockTTL:       30 * time.Minute,
+			RunOnStart:    true,
+			Worker: func() error {
+				return core.CrawlTwDailyData(time.Now())
+			},
+		},
+		{
+			Name:          "tw_metric_info",
+			Interval:      time.Duration(conf.TwMetricRefreshInterval) * time.Second,
+			Jitter:        10 * time.Second,
+			SkipIfRunning: true,
+			LockTTL:       5 * time.Minute,
+			RunOnStart:    true,
+			Worker:        core.RefreshTwMetricInfo,
+		},
+		{
+			Name:          "tw_token_refresh",
+			Interval:      5 * time.Minute,
+			Jitter:        30 * time.Second,
+			SkipIfRunning: true,
+			LockTTL:       5 * time.Minute,
+			RunOnStart:    true,
+			Worker:        core.RefreshAllAccessTokens,
+		},
+		{
+			Name:          "tw_account_check",
+			Interval:      time.Hour,
+			Jitter:        time.Minute,
+			SkipIfRunning: true,
+			LockTTL:       30 * time.Minute,
+			Worker:        core.CheckTwAccounts,
+		},
+		{
+			Name:          "tweet_lib_refresh",
+			Interval:      6 * time.Hour,
+			Jitter:        5 * time.Minute,
+			SkipIfRunning: true,
+			LockTTL:       time.Hour,
+			Worker:        core.RefreshTweetLibMetrics,
+		},
+		{
+			Name:          "tw_tweet_metric",
+			Interval:      5 * time.Minute,
+			Jitter:        30 * time.Second,
+			SkipIfRunning: true,
+			LockTTL:       30 * time.Minute,
+			Worker:        core.SnapshotTwTweetMetrics,
+		},
+		{
+			// each watched account is snapshotted once it is due for the snapshot interval
+			Name:          "tw_follower_snapshot",
+			Interval:      time.Hour,
+			Jitter:        time.Minute,
+			SkipIfRunning: true,
+			LockTTL:       6 * time.Hour,
+			Worker:        core.SnapshotTwFollowers,
+		},
+	}
+
+	for _, j := range jobs {
+		if err := core.RegisterCrondJob(j); err != nil {
+			return err
+		}
+	}
+
+	return core.StartCronds()
+}
+
+// crawlCronExp fire a minute after each crawl interval of the minutes ends, the intervals are aligned to the hour
+// or the day, so the interval has to divide 60 or 1440
+func crawlCronExp(interval int) (string, error) {
+	switch {
+	case interval <= 0:
+		return "", fmt.Errorf("crawl interval %d is not positive", interval)
+	case 60%interval == 0:
+		return fmt.Sprintf("0 1/%d * * * *", interval), nil
+	case interval%60 == 0 && 1440%interval == 0:
+		return fmt.Sprintf("0 1 */%d * * *", interval/60), nil
+	default:
+		return "", fmt.Errorf("crawl interval %d divides neither 60 nor 1440", interval)
+	}
 }
@@ -0,0 +1,29 @@
+package crond
+
+import "testing"
+
+func TestCrawlCronExp(t *testing.T) {
+	cases := []struct {
+		interval int
+		want     string
+		err      bool
+	}{
+		{0, "", true},
+		{-5, "", true},
+		{1, "0 1/1 * * * *", false},
+		{15, "0 1/15 * * * *", false},
+		{60, "0 1/60 * * * *", false},
+		{120, "0 1 */2 * * *", false},
+		{1440, "0 1 */24 * * *", false},
+		{7, "", true},
+		{90, "", true},
+		{300, "", true},
+		{2880, "", true},
+	}
+	for _, c := range cases {
+		got, err := crawlCronExp(c.interval)
+		if (err != nil) != c.err || got != c.want {
+			t.Fatalf("interval %d: %q, %v, want %q", c.interval, got, err, c.want)
+		}
+	}
+}
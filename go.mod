@@ -18,6 +18,7 @@ require (
 	github.com/jinzhu/gorm v1.9.16
 	github.com/pgvector/pgvector-go v0.2.2
 	github.com/pquerna/otp v1.4.0
+	github.com/robfig/cron/v3 v3.0.1
 	github.com/sashabaranov/go-openai v1.36.0
 	github.com/satori/go.uuid v1.2.0
 	github.com/shopspring/decimal v1.4.0
@@ -69,7 +70,6 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
 	github.com/modern-go/reflect2 v1.0.2 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.2 // indirect
-	github.com/robfig/cron/v3 v3.0.1 // indirect
 	github.com/russross/blackfriday/v2 v2.1.0 // indirect
 	github.com/stretchr/testify v1.10.0 // indirect
 	github.com/twitchyliquid64/golang-asm v0.15.1 // indirect
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/signal"
@@ -18,13 +19,14 @@ import (
 	"github.com/project-miko/miko/models"
 	"github.com/project-miko/miko/router"
 	"github.com/project-miko/miko/taskpool"
+	"github.com/project-miko/miko/tools/lifecycle"
 	"github.com/project-miko/miko/tools/log"
 	"github.com/project-miko/miko/tools/logger"
 	"github.com/urfave/cli"
 )
 
 var (
-	sigs     = make(chan os.Signal)
+	sigs     = make(chan os.Signal, 1)
 	done     = make(chan bool)
 	confpath = ""
 )
@@ -101,6 +103,11 @@ func initProc(c *cli.Context) error {
 
 	// initialize Twitter configuration
 	twitterapi.InitConfig()
+	twitterapi.SetStore(core.NewTwStore())
+	if conf.RSSHubHost != "" {
+		core.RegisterTwThreadBackend(core.NewRSSHubThreadBackend(conf.RSSHubHost))
+	}
+	core.RegisterTaskHandlers()
 
 	err = chatgptapi.InitChatGPT()
 	if err != nil {
@@ -117,19 +124,11 @@ func initProc(c *cli.Context) error {
 
 func start(c *cli.Context) {
 
-	core.InitScheduler()
-
-	// initialize task
-	taskpool.InitTaskListeners()
-
-	// initialize scheduled tasks
-	crond.InitCrond()
-
-	// initialize router
-	router.Router()
+	registerLifecycleHooks()
 
-	// start program
-	go core.Run()
+	if err := lifecycle.Start(); err != nil {
+		panic(err)
+	}
 
 	// serve
 	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM) // ctrl+c, kill, kill -2,
@@ -138,12 +137,96 @@ func start(c *cli.Context) {
 	<-done
 }
 
+// registerLifecycleHooks register the subsystems, they are started in ascending order and stopped in the reverse order
+func registerLifecycleHooks() {
+	// logs are flushed at last
+	lifecycle.Register(&lifecycle.Hook{
+		Name:  "logger",
+		Order: 0,
+		Stop: func(ctx context.Context) error {
+			logger.DestroyLogger()
+			return nil
+		},
+	})
+
+	// initialized in initProc
+	lifecycle.Register(&lifecycle.Hook{
+		Name:  "models",
+		Order: 10,
+		Stop: func(ctx context.Context) error {
+			return models.CloseModel()
+		},
+	})
+
+	// in-flight tweet jobs are waited for, a thread may take minutes to post
+	lifecycle.Register(&lifecycle.Hook{
+		Name:  "scheduler",
+		Order: 20,
+		Start: func() error {
+			core.InitScheduler()
+			return nil
+		},
+		Stop: func(ctx context.Context) error {
+			core.StopScheduler()
+			return nil
+		},
+		Timeout: 5 * time.Minute,
+	})
+
+	// initialize task
+	lifecycle.Register(&lifecycle.Hook{
+		Name:  "taskpool",
+		Order: 30,
+		Start: func() error {
+			taskpool.InitTaskListeners()
+			return nil
+		},
+		Stop:    taskpool.Stop,
+		Timeout: 2 * time.Minute,
+	})
+
+	// initialize scheduled tasks
+	lifecycle.Register(&lifecycle.Hook{
+		Name:  "crond",
+		Order: 40,
+		Start: crond.InitCrond,
+		Stop: func(ctx context.Context) error {
+			core.StopCronds()
+			return nil
+		},
+	})
+
+	// the jobs waiting for a rate limit to reset stop waiting before the jobs are waited for
+	lifecycle.Register(&lifecycle.Hook{
+		Name:  "twitterapi",
+		Order: 45,
+		Stop: func(ctx context.Context) error {
+			twitterapi.StopWait()
+			return nil
+		},
+	})
+
+	// stop accepting requests first
+	lifecycle.Register(&lifecycle.Hook{
+		Name:  "http",
+		Order: 50,
+		Start: func() error {
+			// initialize router
+			router.Router()
+
+			// start program
+			go core.Run()
+			return nil
+		},
+		Stop: core.Shutdown,
+	})
+}
+
 func sigAwaiter() {
 	sig := <-sigs
 	fmt.Printf("recv signal %s\n", sig.String())
 
-	// todo process exit logic
-	logger.DestroyLogger() // flush log
+	lifecycle.Stop()
 
 	done <- true
 }
@@ -47,6 +47,28 @@ func InitModel() {
 	}
 }
 
+// CloseModel close the db, redis and pg connections
+func CloseModel() error {
+	var lastErr error
+	for name, db := range dbs {
+		if err := db.Close(); err != nil {
+			lastErr = fmt.Errorf("close %s error %s", name, err.Error())
+		}
+	}
+
+	for name, rc := range redises {
+		if err := rc.client.Close(); err != nil {
+			lastErr = fmt.Errorf("close %s error %s", name, err.Error())
+		}
+	}
+
+	for _, pg := range pgs {
+		pg.Close()
+	}
+
+	return lastErr
+}
+
 // get main db instance
 func GetDbInst() *gorm.DB {
 	return dbs["db_main"]
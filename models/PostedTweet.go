@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+const (
+	PostedTweetSourceSchedule = "schedule"
+	PostedTweetSourceManual   = "manual"
+
+	PostedTweetStatusPosted  = 1
+	PostedTweetStatusDeleted = 2
+)
+
+// PostedTweet a tweet posted by miko, the tweets of a thread share the ThreadId, the id of the first tweet
+type PostedTweet struct {
+	Id               int64  `json:"id"`
+	UserId           string `json:"user_id"`
+	Account          string `json:"account"`
+	Source           string `json:"source"`
+	SourceId         int64  `json:"source_id"` // the schedule id if the source is schedule
+	TwThreadPostId   int64  `json:"tw_thread_post_id"`
+	ThreadId         string `json:"thread_id"`
+	Seq              int    `json:"seq"`
+	TweetId          string `json:"tweet_id"`
+	InReplyToTweetId string `json:"in_reply_to_tweet_id"`
+	Text             string `json:"text"`
+	MediaIds         string `json:"media_ids"` // json array
+	Status           int    `json:"status"`
+	PostedAt         int64  `json:"posted_at"`
+	DeletedAt        int64  `json:"deleted_at"`
+	CreatedAt        int64  `json:"created_at"`
+	UpdatedAt        int64  `json:"updated_at"`
+}
+
+func (t *PostedTweet) TableName() string {
+	return "posted_tweet"
+}
+
+func (t *PostedTweet) Save() error {
+	return GetDbInst().Save(t).Error
+}
+
+func (t *PostedTweet) Update() error {
+	t.UpdatedAt = tools.GetMillisecond(time.Now())
+	return t.Save()
+}
+
+func GetPostedTweetByTweetId(tweetId string) (*PostedTweet, error) {
+	result := new(PostedTweet)
+	err := GetDbInst().Where("tweet_id=?", tweetId).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// GetPostedTweetListByThreadId get the tweets of the thread in the order of the thread
+func GetPostedTweetListByThreadId(threadId string) ([]*PostedTweet, error) {
+	results := make([]*PostedTweet, 0)
+	err := GetDbInst().Where("thread_id=?", threadId).Order("seq asc").Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+// GetPostedTweetList list the tweets posted in [startAt, endAt), the latest first, an empty filter matches all
+func GetPostedTweetList(userId, source string, sourceId int64, status int, startAt, endAt, page, limit int64) (int64, []*PostedTweet, error) {
+	var amount int64
+	results := make([]*PostedTweet, 0)
+	db := GetDbInst().Model(&PostedTweet{})
+	if userId != "" {
+		db = db.Where("user_id=?", userId)
+	}
+	if source != "" {
+		db = db.Where("source=?", source)
+	}
+	if sourceId > 0 {
+		db = db.Where("source_id=?", sourceId)
+	}
+	if status > 0 {
+		db = db.Where("status=?", status)
+	}
+	if startAt > 0 {
+		db = db.Where("posted_at>=?", startAt)
+	}
+	if endAt > 0 {
+		db = db.Where("posted_at<?", endAt)
+	}
+
+	err := db.Count(&amount).Error
+	if err != nil {
+		return 0, results, err
+	}
+
+	err = db.Order("posted_at desc, seq desc").Offset((page - 1) * limit).Limit(limit).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return amount, results, nil
+	}
+	return amount, results, err
+}
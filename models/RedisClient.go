@@ -7,7 +7,7 @@ import (
 )
 
 // completed commands
-// string set / get
+// string set / get / del
 // hash hset / hget / hmset / hmget / hgetall
 // list lpush / rpop / brpop
 
@@ -138,6 +138,17 @@ func (rc *RedisClient) Hset(key, field, value string) (e error) {
 	return e
 }
 
+// delete fields of a hash structure
+func (rc *RedisClient) Hdel(key string, fields ...string) (e error) {
+	client := rc.Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	_, e = client.Do("HDEL", redis.Args{}.Add(key).AddFlat(fields)...)
+	return e
+}
+
 // get command, get a string from redis
 func (rc *RedisClient) GetString(key string) (str string, e error) {
 	client := rc.Get()
@@ -176,6 +187,17 @@ func (rc *RedisClient) MGet(keys []string) (map[string]string, error) {
 	return result, nil
 }
 
+// del command, delete a key from redis
+func (rc *RedisClient) Del(key string) (e error) {
+	client := rc.Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	_, e = client.Do("DEL", key)
+	return e
+}
+
 // same as set command, set a string to redis
 func (rc *RedisClient) SetString(key, value string, expire int64) (e error) {
 	client := rc.Get()
@@ -262,3 +284,63 @@ func (rc *RedisClient) IncrWithExpire(key string, expire int64) (int, error) {
 	// if the key exists, only execute the INCR operation
 	return redis.Int(client.Do("INCR", key))
 }
+
+// set command with NX, set a string to redis only if the key does not exist
+func (rc *RedisClient) SetNX(key, value string, expire int64) (ok bool, e error) {
+	client := rc.Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	var reply interface{}
+	if expire > 0 {
+		reply, e = client.Do("SET", key, value, "EX", expire, "NX")
+	} else {
+		reply, e = client.Do("SET", key, value, "NX")
+	}
+	if e != nil {
+		return false, e
+	}
+
+	return reply != nil, nil
+}
+
+var delIfEqualScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// delete a key from redis only if its value is still the given one
+func (rc *RedisClient) DelIfEqual(key, value string) (ok bool, e error) {
+	client := rc.Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	n, e := redis.Int(delIfEqualScript.Do(client, key, value))
+	if e != nil {
+		return false, e
+	}
+
+	return n > 0, nil
+}
+
+var getDelScript = redis.NewScript(1, `
+local v = redis.call('GET', KEYS[1])
+if v then
+	redis.call('DEL', KEYS[1])
+end
+return v
+`)
+
+// get a string from redis and delete it atomically, redis.ErrNil is returned if the key does not exist
+func (rc *RedisClient) GetDel(key string) (str string, e error) {
+	client := rc.Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	return redis.String(getDelScript.Do(client, key))
+}
@@ -3,17 +3,24 @@ package models
 import (
 	"time"
 
+	"github.com/jinzhu/gorm"
 	"github.com/project-miko/miko/tools"
 )
 
 const (
-	ScheduleExecStatusSuccess = 1
-	ScheduleExecStatusFail    = 2
+	ScheduleExecStatusSuccess  = 1
+	ScheduleExecStatusFail     = 2
+	ScheduleExecStatusSkipped  = 3 // the fire is skipped on purpose, e.g. by a blackout window or the emergency stop
+	ScheduleExecStatusDeferred = 4 // the fire runs again later, e.g. after a blackout window or the media processing
+	ScheduleExecStatusCanceled = 5 // the thread is undone within the undo window
 )
 
 type ScheduleLog struct {
 	Id           int64  `json:"id"`
 	JobId        string `json:"job_id"`
+	UserId       string `json:"user_id"`
+	TwScheduleId int64  `json:"tw_schedule_id"`
+	TweetIds     string `json:"tweet_ids"` // ids of the tweets posted by the execution, comma separated
 	ExecDuration int    `json:"exec_duration"`
 	Status       int    `json:"status"`
 	ErrorMsg     string `json:"error_msg"`
@@ -39,19 +46,14 @@ func (r *ScheduleLog) Del() error {
 	return GetDbInst().Delete(r).Error
 }
 
-func SaveScheduleLog(jobId string, execAt int64, err error) error {
-	reqLog := &ScheduleLog{
-		JobId:  jobId,
-		ExecAt: execAt,
-		Status: ScheduleExecStatusSuccess,
-	}
-
+// SaveScheduleLog save the execution log with the status and the error of the job, the job error is returned
+func SaveScheduleLog(reqLog *ScheduleLog, status int, err error) error {
+	reqLog.Status = status
 	if err != nil {
 		reqLog.ErrorMsg = err.Error()
-		reqLog.Status = ScheduleExecStatusFail
 	}
 
-	reqLog.ExecDuration = int(tools.GetMillisecond(time.Now()) - execAt)
+	reqLog.ExecDuration = int(tools.GetMillisecond(time.Now()) - reqLog.ExecAt)
 	reqLog.CreatedAt = tools.GetMillisecond(time.Now())
 	if e := reqLog.Save(); e != nil {
 		return e
@@ -59,3 +61,47 @@ func SaveScheduleLog(jobId string, execAt int64, err error) error {
 
 	return err
 }
+
+// GetScheduleLogList list the execution logs between start and end, a filter is ignored if it is zero
+func GetScheduleLogList(userId string, twScheduleId int64, status int, start, end, page, limit int64) (int64, []*ScheduleLog, error) {
+	var amount int64
+	results := make([]*ScheduleLog, 0)
+	db := GetDbInst().Model(&ScheduleLog{})
+	db = db.Where("exec_at between ? and ?", start, end)
+	if len(userId) != 0 {
+		db = db.Where("user_id=?", userId)
+	}
+	if twScheduleId > 0 {
+		db = db.Where("tw_schedule_id=?", twScheduleId)
+	}
+	if status > 0 {
+		db = db.Where("status=?", status)
+	}
+
+	err := db.Count(&amount).Error
+	if err != nil {
+		return 0, results, err
+	}
+
+	err = db.Order("exec_at desc").Offset((page - 1) * limit).Limit(limit).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return amount, results, nil
+	}
+	return amount, results, err
+}
+
+// GetScheduleLogStatList get the fields of the execution logs between start and end needed by the statistics
+func GetScheduleLogStatList(userId string, start, end int64) ([]*ScheduleLog, error) {
+	results := make([]*ScheduleLog, 0)
+	db := GetDbInst().Select("user_id, status, exec_duration, error_msg")
+	db = db.Where("exec_at between ? and ?", start, end)
+	if len(userId) != 0 {
+		db = db.Where("user_id=?", userId)
+	}
+
+	err := db.Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
@@ -16,6 +16,9 @@ const (
 	TweetLikeScope      = "like.write"
 	ReTweetScope        = "tweet.write"
 	ReTweetAndLikeScope = "like.write tweet.write"
+
+	TwAccountStatusNormal  = 0
+	TwAccountStatusRevoked = 1 // the authorization is revoked or the account is suspended, it has to be connected again
 )
 
 var (
@@ -37,6 +40,8 @@ type TwAccount struct {
 	AccessToken     string `json:"access_token"`
 	RefreshToken    string `json:"refresh_token"`
 	ExpiredAt       int64  `json:"expired_at"`
+	Status          int    `json:"status"`
+	StatusReason    string `json:"status_reason"`
 	CreatedAt       int64  `json:"created_at"`
 	UpdatedAt       int64  `json:"updated_at"`
 }
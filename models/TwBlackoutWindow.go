@@ -0,0 +1,101 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+const (
+	TwBlackoutPolicySkip  = 1 // the fire is dropped
+	TwBlackoutPolicyDefer = 2 // the fire runs once the window ends
+
+	TwBlackoutWindowStatusEnabled = 1
+	TwBlackoutWindowStatusDeleted = 2
+)
+
+var (
+	AllowTwBlackoutPolicy = map[int]struct{}{
+		TwBlackoutPolicySkip:  {},
+		TwBlackoutPolicyDefer: {},
+	}
+)
+
+// TwBlackoutWindow a period no schedule posts in, UserId is empty for a global window
+type TwBlackoutWindow struct {
+	Id        int64  `json:"id"`
+	UserId    string `json:"user_id"`
+	Name      string `json:"name"`
+	StartAt   int64  `json:"start_at"`
+	EndAt     int64  `json:"end_at"`
+	Policy    int    `json:"policy"`
+	Status    int    `json:"status"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (w *TwBlackoutWindow) TableName() string {
+	return "tw_blackout_window"
+}
+
+func (w *TwBlackoutWindow) Save() error {
+	return GetDbInst().Save(w).Error
+}
+
+func (w *TwBlackoutWindow) Update() error {
+	w.UpdatedAt = tools.GetMillisecond(time.Now())
+	return w.Save()
+}
+
+func (w *TwBlackoutWindow) Del() error {
+	return GetDbInst().Delete(w).Error
+}
+
+func GetTwBlackoutWindowById(id int64) (*TwBlackoutWindow, error) {
+	result := new(TwBlackoutWindow)
+	err := GetDbInst().Where("id=? and status=?", id, TwBlackoutWindowStatusEnabled).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// GetActiveTwBlackoutWindow get the window of the user or the global window covering the time, the one ending last is returned
+func GetActiveTwBlackoutWindow(userId string, at int64) (*TwBlackoutWindow, error) {
+	result := new(TwBlackoutWindow)
+	db := GetDbInst()
+	db = db.Where("status=?", TwBlackoutWindowStatusEnabled)
+	db = db.Where("user_id in (?, '')", userId)
+	db = db.Where("start_at<=? and end_at>?", at, at)
+	err := db.Order("end_at desc").Limit(1).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// GetTwBlackoutWindowList list the windows not ended yet, the global windows are included if withGlobal is true
+func GetTwBlackoutWindowList(userId string, withGlobal bool, page, limit int64) (int64, []*TwBlackoutWindow, error) {
+	var amount int64
+	results := make([]*TwBlackoutWindow, 0)
+	db := GetDbInst().Model(&TwBlackoutWindow{})
+	db = db.Where("status=?", TwBlackoutWindowStatusEnabled)
+	db = db.Where("end_at>?", tools.GetMillisecond(time.Now()))
+	if withGlobal {
+		db = db.Where("user_id in (?, '')", userId)
+	} else {
+		db = db.Where("user_id=?", userId)
+	}
+
+	err := db.Count(&amount).Error
+	if err != nil {
+		return 0, results, err
+	}
+
+	err = db.Order("start_at asc").Offset((page - 1) * limit).Limit(limit).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return amount, results, nil
+	}
+	return amount, results, err
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+const (
+	TwFollowerChangeTypeFollow   = 1
+	TwFollowerChangeTypeUnfollow = 2
+)
+
+// TwFollowerWatch an account whose followers are snapshotted
+type TwFollowerWatch struct {
+	Id             int64  `json:"id"`
+	UserId         string `json:"user_id"`
+	Account        string `json:"account"`
+	Name           string `json:"name"`
+	LastSnapshotAt int64  `json:"last_snapshot_at"`
+	LastError      string `json:"last_error"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+func (w *TwFollowerWatch) TableName() string {
+	return "tw_follower_watch"
+}
+
+func (w *TwFollowerWatch) Save() error {
+	return GetDbInst().Save(w).Error
+}
+
+func (w *TwFollowerWatch) Update() error {
+	w.UpdatedAt = tools.GetMillisecond(time.Now())
+	return w.Save()
+}
+
+func (w *TwFollowerWatch) Del() error {
+	return GetDbInst().Delete(w).Error
+}
+
+// TwFollowerSnapshot the follower ids of a watched account at a time, stored gzipped
+type TwFollowerSnapshot struct {
+	Id            int64  `json:"id"`
+	UserId        string `json:"user_id"`
+	FollowerCount int    `json:"follower_count"`
+	FollowerIds   []byte `json:"-"`
+	FollowCount   int    `json:"follow_count"`   // the new followers since the last snapshot
+	UnfollowCount int    `json:"unfollow_count"` // the followers gone since the last snapshot
+	SnapshotAt    int64  `json:"snapshot_at"`
+	CreatedAt     int64  `json:"created_at"`
+}
+
+func (s *TwFollowerSnapshot) TableName() string {
+	return "tw_follower_snapshot"
+}
+
+// GetFollowerIds the follower ids in ascending order
+func (s *TwFollowerSnapshot) GetFollowerIds() ([]string, error) {
+	if len(s.FollowerIds) == 0 {
+		return make([]string, 0), nil
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(s.FollowerIds))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return make([]string, 0), nil
+	}
+
+	return strings.Split(string(b), "\n"), nil
+}
+
+func (s *TwFollowerSnapshot) SetFollowerIds(ids []string) error {
+	sorted := make([]string, len(ids))
+	copy(sorted, ids)
+	sort.Strings(sorted)
+
+	buf := new(bytes.Buffer)
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write([]byte(strings.Join(sorted, "\n"))); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	s.FollowerCount = len(sorted)
+	s.FollowerIds = buf.Bytes()
+	return nil
+}
+
+// TwFollowerChange a follower followed or unfollowed a watched account between two snapshots,
+// FollowerCount is the follower count of the follower
+type TwFollowerChange struct {
+	Id                   int64  `json:"id"`
+	UserId               string `json:"user_id"`
+	TwFollowerSnapshotId int64  `json:"tw_follower_snapshot_id"`
+	Type                 int    `json:"type"`
+	FollowerId           string `json:"follower_id"`
+	FollowerAccount      string `json:"follower_account"`
+	FollowerName         string `json:"follower_name"`
+	FollowerCount        int    `json:"follower_count"`
+	Notable              bool   `json:"notable"`
+	SnapshotAt           int64  `json:"snapshot_at"`
+	CreatedAt            int64  `json:"created_at"`
+}
+
+func (c *TwFollowerChange) TableName() string {
+	return "tw_follower_change"
+}
+
+func GetTwFollowerWatchByUserId(userId string) (*TwFollowerWatch, error) {
+	result := new(TwFollowerWatch)
+	err := GetDbInst().Where("user_id=?", userId).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+func GetAllTwFollowerWatchList() ([]*TwFollowerWatch, error) {
+	results := make([]*TwFollowerWatch, 0)
+	err := GetDbInst().Order("id asc").Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+// SaveTwFollowerSnapshot save a snapshot with the changes since the last one
+func SaveTwFollowerSnapshot(snapshot *TwFollowerSnapshot, changes []*TwFollowerChange) error {
+	tx := GetDbInst().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		tx.Rollback()
+	}()
+
+	if err := tx.Save(snapshot).Error; err != nil {
+		return err
+	}
+	for _, v := range changes {
+		v.TwFollowerSnapshotId = snapshot.Id
+		if err := tx.Save(v).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+func GetLatestTwFollowerSnapshot(userId string) (*TwFollowerSnapshot, error) {
+	result := new(TwFollowerSnapshot)
+	err := GetDbInst().Where("user_id=?", userId).Order("id desc").Limit(1).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// GetTwFollowerSnapshotList list the snapshots of the account without their follower ids, the latest first
+func GetTwFollowerSnapshotList(userId string, page, limit int64) (int64, []*TwFollowerSnapshot, error) {
+	var amount int64
+	results := make([]*TwFollowerSnapshot, 0)
+	db := GetDbInst().Model(&TwFollowerSnapshot{}).Where("user_id=?", userId)
+	if err := db.Count(&amount).Error; err != nil {
+		return 0, results, err
+	}
+
+	db = db.Select("id, user_id, follower_count, follow_count, unfollow_count, snapshot_at, created_at")
+	err := db.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return amount, results, nil
+	}
+	return amount, results, err
+}
+
+// GetTwFollowerChangeList list the changes of the account in [startAt, endAt), the latest first,
+// or the ones with the most followers first if byFollowerCount, a zero filter matches all
+func GetTwFollowerChangeList(userId string, changeType int, notable bool, startAt, endAt int64, byFollowerCount bool, page, limit int64) (int64, []*TwFollowerChange, error) {
+	var amount int64
+	results := make([]*TwFollowerChange, 0)
+	db := GetDbInst().Model(&TwFollowerChange{}).Where("user_id=?", userId)
+	if changeType > 0 {
+		db = db.Where("type=?", changeType)
+	}
+	if notable {
+		db = db.Where("notable=?", true)
+	}
+	if startAt > 0 {
+		db = db.Where("snapshot_at>=?", startAt)
+	}
+	if endAt > 0 {
+		db = db.Where("snapshot_at<?", endAt)
+	}
+	if err := db.Count(&amount).Error; err != nil {
+		return 0, results, err
+	}
+
+	order := "snapshot_at desc, id asc"
+	if byFollowerCount {
+		order = "follower_count desc, id asc"
+	}
+	err := db.Order(order).Offset((page - 1) * limit).Limit(limit).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return amount, results, nil
+	}
+	return amount, results, err
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+const (
+	TwMediaStateUploading  = "uploading"
+	TwMediaStateProcessing = "processing"
+	TwMediaStateReady      = "ready"
+	TwMediaStateFailed     = "failed"
+)
+
+// TwMedia a media url uploaded for a user, the media id is reused by the posts until it expires
+type TwMedia struct {
+	Id           int64  `json:"id"`
+	UserId       string `json:"user_id"`
+	MediaUrl     string `json:"media_url"`
+	AltText      string `json:"alt_text"`
+	MediaId      string `json:"media_id"`
+	Category     string `json:"category"`
+	State        string `json:"state"`
+	ErrMsg       string `json:"err_msg"`
+	CheckAfterAt int64  `json:"check_after_at"` // the processing is polled again after it, unit: millisecond
+	ExpiresAt    int64  `json:"expires_at"`     // the media id can not be attached after it, unit: millisecond
+	UploadedAt   int64  `json:"uploaded_at"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+func (m *TwMedia) TableName() string {
+	return "tw_media"
+}
+
+func (m *TwMedia) Save() error {
+	return GetDbInst().Save(m).Error
+}
+
+func (m *TwMedia) Update() error {
+	m.UpdatedAt = tools.GetMillisecond(time.Now())
+	return m.Save()
+}
+
+// GetLatestTwMedia get the latest upload of the media url with the alt text for the user
+func GetLatestTwMedia(userId, mediaUrl, altText string) (*TwMedia, error) {
+	result := new(TwMedia)
+	db := GetDbInst().Where("user_id=? and media_url=? and alt_text=?", userId, mediaUrl, altText)
+	err := db.Order("id desc").Limit(1).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
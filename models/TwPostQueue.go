@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+const (
+	TwPostQueueStatusQueued  = 1
+	TwPostQueueStatusPosted  = 2
+	TwPostQueueStatusDeleted = 3
+	TwPostQueueStatusError   = 4
+)
+
+// TwPostQueue an item of the account's posting queue, the queued items fill the next free posting slots in order of position.
+// TwScheduleId is the one-shot schedule the item is materialized into, 0 if there is no slot for it yet
+type TwPostQueue struct {
+	Id              int64  `json:"id"`
+	UserId          string `json:"user_id"`
+	TwScheduleLibId int64  `json:"tw_schedule_lib_id"`
+	TwScheduleId    int64  `json:"tw_schedule_id"`
+	Position        int    `json:"position"`
+	Status          int    `json:"status"`
+	SlotAt          int64  `json:"slot_at"`
+	CreatedAt       int64  `json:"created_at"`
+	UpdatedAt       int64  `json:"updated_at"`
+}
+
+func (q *TwPostQueue) TableName() string {
+	return "tw_post_queue"
+}
+
+func (q *TwPostQueue) Save() error {
+	return GetDbInst().Save(q).Error
+}
+
+func (q *TwPostQueue) Update() error {
+	q.UpdatedAt = tools.GetMillisecond(time.Now())
+	return q.Save()
+}
+
+func (q *TwPostQueue) Del() error {
+	return GetDbInst().Delete(q).Error
+}
+
+func GetTwPostQueueById(id int64) (*TwPostQueue, error) {
+	result := new(TwPostQueue)
+	err := GetDbInst().Where("id=?", id).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+func GetTwPostQueueByScheduleId(twScheduleId int64) (*TwPostQueue, error) {
+	result := new(TwPostQueue)
+	err := GetDbInst().Where("tw_schedule_id=?", twScheduleId).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// GetTwPostQueueList get the queue items of the user ordered by position, status <= 0 means all status
+func GetTwPostQueueList(userId string, status int) ([]*TwPostQueue, error) {
+	results := make([]*TwPostQueue, 0)
+	db := GetDbInst()
+	db = db.Where("user_id=?", userId)
+	if status > 0 {
+		db = db.Where("status=?", status)
+	}
+
+	err := db.Order("position asc, id asc").Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+// GetTwPostQueueUserIds get the users who have queued items
+func GetTwPostQueueUserIds() ([]string, error) {
+	results := make([]string, 0)
+	err := GetDbInst().Model(TwPostQueue{}).Where("status=?", TwPostQueueStatusQueued).Group("user_id").Pluck("user_id", &results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
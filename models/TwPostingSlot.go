@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+type TwPostingSlot struct {
+	Id        int64  `json:"id"`
+	UserId    string `json:"user_id"`
+	WeekDay   int    `json:"week_day"`
+	Hour      int    `json:"hour"`
+	Minute    int    `json:"minute"`
+	CreatedAt int64  `json:"created_at"`
+	UpdatedAt int64  `json:"updated_at"`
+}
+
+func (s *TwPostingSlot) TableName() string {
+	return "tw_posting_slot"
+}
+
+func (s *TwPostingSlot) Save() error {
+	return GetDbInst().Save(s).Error
+}
+
+func (s *TwPostingSlot) Update() error {
+	s.UpdatedAt = tools.GetMillisecond(time.Now())
+	return s.Save()
+}
+
+func (s *TwPostingSlot) Del() error {
+	return GetDbInst().Delete(s).Error
+}
+
+func GetTwPostingSlotList(userId string) ([]*TwPostingSlot, error) {
+	results := make([]*TwPostingSlot, 0)
+	err := GetDbInst().Where("user_id=?", userId).Order("week_day asc, hour asc, minute asc").Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+// ReplaceTwPostingSlots replace all weekly posting slots of the user
+func ReplaceTwPostingSlots(userId string, slots []*TwPostingSlot) error {
+	tx := GetDbInst().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		tx.Rollback()
+	}()
+
+	err := tx.Where("user_id=?", userId).Delete(TwPostingSlot{}).Error
+	if err != nil {
+		return err
+	}
+
+	now := tools.GetMillisecond(time.Now())
+	for _, v := range slots {
+		v.UserId = userId
+		v.CreatedAt = now
+		if err = tx.Save(v).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
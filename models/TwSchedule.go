@@ -14,6 +14,7 @@ const (
 	TwScheduleStatusFinished   = 2
 	TwScheduleStatusDeleted    = 3
 	TwScheduleStatusError      = 4
+	TwScheduleStatusPaused     = 5 // the job is removed from the scheduler, RemainCount is kept for resuming
 )
 
 type TwSchedule struct {
@@ -26,6 +27,7 @@ type TwSchedule struct {
 	SourceType      int    `json:"source_type"`
 	Status          int    `json:"status"`
 	NextRunAt       int64  `json:"next_run_at"`
+	DeferredRunAt   int64  `json:"deferred_run_at"` // a fire deferred by a blackout window runs at this time, 0 if none
 	CreatedAt       int64  `json:"created_at"`
 	UpdatedAt       int64  `json:"updated_at"`
 }
@@ -76,6 +78,15 @@ func GetTwScheduleByUserIdAndTwLibId(userId string, twScheduleLibId int64) (*TwS
 	return result, err
 }
 
+func GetTwScheduleListByUserId(userId string, status int) ([]*TwSchedule, error) {
+	results := make([]*TwSchedule, 0)
+	err := GetDbInst().Where("user_id = ? and status = ?", userId, status).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
 func GetAllTwScheduleList(status int) ([]*TwSchedule, error) {
 	results := make([]*TwSchedule, 0)
 	db := GetDbInst()
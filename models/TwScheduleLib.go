@@ -9,8 +9,15 @@ import (
 	"github.com/project-miko/miko/tools/strutils"
 )
 
+const (
+	TwScheduleLibTypeThread    = 0 // content is a fixed thread
+	TwScheduleLibTypeEvergreen = 1 // content is an evergreen config, the thread is picked from TweetLib at run time
+	TwScheduleLibTypeGenerated = 2 // content is a prompt config, the tweet is generated by llm at run time
+)
+
 type TwScheduleLib struct {
 	Id        int64  `json:"id"`
+	Type      int    `json:"type"`
 	Content   string `json:"content"`
 	CreatedAt int64  `json:"created_at"`
 	UpdatedAt int64  `json:"updated_at"`
@@ -0,0 +1,239 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+const (
+	TwThreadPostStatusPosting    = 1 // some items are not posted yet
+	TwThreadPostStatusPosted     = 2
+	TwThreadPostStatusPartial    = 3 // failed halfway, to resume or to roll back
+	TwThreadPostStatusFailed     = 4 // failed before any item is posted
+	TwThreadPostStatusRolledBack = 5 // the posted items are deleted
+	TwThreadPostStatusCanceled   = 6 // undone within the undo window, nothing is posted
+	TwThreadPostStatusPending    = 7 // waiting for its media to be processed or its hold to end, the next fire or its task posts it
+
+	TwThreadPostItemStatusPending = 1
+	TwThreadPostItemStatusPosted  = 2
+	TwThreadPostItemStatusFailed  = 3
+	TwThreadPostItemStatusDeleted = 4
+)
+
+// TwThreadPost a thread posted by a schedule or by the api, TwScheduleId is 0 if it is not posted by a schedule
+type TwThreadPost struct {
+	Id           int64  `json:"id"`
+	UserId       string `json:"user_id"`
+	TwScheduleId int64  `json:"tw_schedule_id"`
+	TweetLibId   int64  `json:"tweet_lib_id"` // the evergreen content of the thread, 0 if it is not
+	Source       string `json:"source"`       // the source of the posted tweets
+	Status       int    `json:"status"`
+	ItemCount    int    `json:"item_count"`
+	PostedCount  int    `json:"posted_count"`
+	ErrorMsg     string `json:"error_msg"`
+	HoldUntil    int64  `json:"hold_until"` // the thread is not posted before it so it can be undone, unit: millisecond
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+func (p *TwThreadPost) TableName() string {
+	return "tw_thread_post"
+}
+
+func (p *TwThreadPost) Save() error {
+	return GetDbInst().Save(p).Error
+}
+
+func (p *TwThreadPost) Update() error {
+	p.UpdatedAt = tools.GetMillisecond(time.Now())
+	return p.Save()
+}
+
+// TwThreadPostItem a tweet of a thread post, Seq is its position in the thread from 0
+type TwThreadPostItem struct {
+	Id             int64  `json:"id"`
+	TwThreadPostId int64  `json:"tw_thread_post_id"`
+	Seq            int    `json:"seq"`
+	SortId         string `json:"sort_id"`
+	Text           string `json:"text"`
+	MediaUrls      string `json:"media_urls"`      // json array, the media is uploaded again on resume if the ids expired
+	MediaAltTexts  string `json:"media_alt_texts"` // json array, by the index of the media urls
+	MediaIds       string `json:"media_ids"`       // json array
+	TweetId        string `json:"tweet_id"`
+	Status         int    `json:"status"`
+	ErrorMsg       string `json:"error_msg"`
+	PostedAt       int64  `json:"posted_at"`
+	CreatedAt      int64  `json:"created_at"`
+	UpdatedAt      int64  `json:"updated_at"`
+}
+
+func (i *TwThreadPostItem) TableName() string {
+	return "tw_thread_post_item"
+}
+
+func (i *TwThreadPostItem) Save() error {
+	return GetDbInst().Save(i).Error
+}
+
+func (i *TwThreadPostItem) Update() error {
+	i.UpdatedAt = tools.GetMillisecond(time.Now())
+	return i.Save()
+}
+
+func (i *TwThreadPostItem) GetMediaUrls() []string {
+	return unmarshalStringList(i.MediaUrls)
+}
+
+func (i *TwThreadPostItem) SetMediaUrls(urls []string) {
+	i.MediaUrls = marshalStringList(urls)
+}
+
+func (i *TwThreadPostItem) GetMediaAltTexts() []string {
+	return unmarshalStringList(i.MediaAltTexts)
+}
+
+func (i *TwThreadPostItem) SetMediaAltTexts(altTexts []string) {
+	i.MediaAltTexts = marshalStringList(altTexts)
+}
+
+func (i *TwThreadPostItem) GetMediaIds() []string {
+	return unmarshalStringList(i.MediaIds)
+}
+
+func (i *TwThreadPostItem) SetMediaIds(ids []string) {
+	i.MediaIds = marshalStringList(ids)
+}
+
+func unmarshalStringList(s string) []string {
+	list := make([]string, 0)
+	if s != "" {
+		_ = json.Unmarshal([]byte(s), &list)
+	}
+	return list
+}
+
+func marshalStringList(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	b, _ := json.Marshal(list)
+	return string(b)
+}
+
+// SaveTwThreadPost save a new thread post with its items
+func SaveTwThreadPost(post *TwThreadPost, items []*TwThreadPostItem) error {
+	tx := GetDbInst().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		tx.Rollback()
+	}()
+
+	if err := tx.Save(post).Error; err != nil {
+		return err
+	}
+	for _, v := range items {
+		v.TwThreadPostId = post.Id
+		if err := tx.Save(v).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+func GetTwThreadPostById(id int64) (*TwThreadPost, error) {
+	result := new(TwThreadPost)
+	err := GetDbInst().Where("id=?", id).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// CancelTwThreadPost cancel the thread post still held at now, false if it is released or not held
+func CancelTwThreadPost(id, now int64) (bool, error) {
+	db := GetDbInst().Model(&TwThreadPost{}).Where("id=? and status in (?) and hold_until>?", id,
+		[]int{TwThreadPostStatusPosting, TwThreadPostStatusPending}, now)
+	db = db.Updates(map[string]interface{}{"status": TwThreadPostStatusCanceled, "updated_at": now})
+	return db.RowsAffected > 0, db.Error
+}
+
+// PendTwThreadPost leave the thread post being posted to the next fire of its schedule, false if it is canceled
+func PendTwThreadPost(id int64, errorMsg string, now int64) (bool, error) {
+	db := GetDbInst().Model(&TwThreadPost{}).Where("id=? and status=?", id, TwThreadPostStatusPosting)
+	db = db.Updates(map[string]interface{}{"status": TwThreadPostStatusPending, "error_msg": errorMsg, "updated_at": now})
+	return db.RowsAffected > 0, db.Error
+}
+
+// ReleaseTwThreadPostHold end the hold of the thread post to post it, false if it is canceled
+func ReleaseTwThreadPostHold(id, now int64) (bool, error) {
+	db := GetDbInst().Model(&TwThreadPost{}).Where("id=? and status=?", id, TwThreadPostStatusPosting)
+	db = db.Updates(map[string]interface{}{"hold_until": 0, "updated_at": now})
+	return db.RowsAffected > 0, db.Error
+}
+
+// ClaimTwThreadPost take the thread post in one of the statuses to post it, a post still posting is taken only if
+// it is not updated since staleBefore, false if it is taken by another worker
+func ClaimTwThreadPost(id int64, statuses []int, staleBefore, now int64) (bool, error) {
+	db := GetDbInst().Model(&TwThreadPost{}).Where("id=? and (status in (?) or (status=? and updated_at<? and hold_until<?))",
+		id, statuses, TwThreadPostStatusPosting, staleBefore, staleBefore)
+	db = db.Updates(map[string]interface{}{"status": TwThreadPostStatusPosting, "updated_at": now})
+	return db.RowsAffected > 0, db.Error
+}
+
+// TouchTwThreadPost mark the thread post still posting at now, it is not taken as stale then
+func TouchTwThreadPost(id, now int64) error {
+	db := GetDbInst().Model(&TwThreadPost{}).Where("id=? and status=?", id, TwThreadPostStatusPosting)
+	return db.Updates(map[string]interface{}{"updated_at": now}).Error
+}
+
+// GetResumableTwThreadPostByScheduleId get the latest thread post of the schedule failed halfway, waiting for its media
+// or still posting but not updated since staleBefore
+func GetResumableTwThreadPostByScheduleId(twScheduleId, staleBefore int64) (*TwThreadPost, error) {
+	result := new(TwThreadPost)
+	db := GetDbInst().Where("tw_schedule_id=? and (status in (?) or (status=? and updated_at<? and hold_until<?))", twScheduleId,
+		[]int{TwThreadPostStatusPartial, TwThreadPostStatusPending}, TwThreadPostStatusPosting, staleBefore, staleBefore)
+	err := db.Order("id desc").Limit(1).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	return result, err
+}
+
+// GetTwThreadPostItemList get the items of the thread post in the order of the thread
+func GetTwThreadPostItemList(twThreadPostId int64) ([]*TwThreadPostItem, error) {
+	results := make([]*TwThreadPostItem, 0)
+	err := GetDbInst().Where("tw_thread_post_id=?", twThreadPostId).Order("seq asc").Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+func GetTwThreadPostList(userId string, status int, page, limit int64) (int64, []*TwThreadPost, error) {
+	var amount int64
+	results := make([]*TwThreadPost, 0)
+
+	db := GetDbInst().Model(&TwThreadPost{})
+	if userId != "" {
+		db = db.Where("user_id=?", userId)
+	}
+	if status > 0 {
+		db = db.Where("status=?", status)
+	}
+	if err := db.Count(&amount).Error; err != nil {
+		return 0, nil, err
+	}
+
+	err := db.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return amount, results, nil
+	}
+	return amount, results, err
+}
@@ -0,0 +1,179 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+const (
+	TwTweetMetricStage1Hour  = "1h"
+	TwTweetMetricStage24Hour = "24h"
+	TwTweetMetricStage7Day   = "7d"
+
+	TwTweetMetricStatusPending = 1
+	TwTweetMetricStatusDone    = 2
+	TwTweetMetricStatusGone    = 3 // the tweet is deleted or not visible when the snapshot is due
+
+	twTweetMetricEngagements = "likes+replies+retweets+quotes+bookmarks"
+)
+
+// TwTweetMetricStages the stages in the order they are taken
+var TwTweetMetricStages = []string{TwTweetMetricStage1Hour, TwTweetMetricStage24Hour, TwTweetMetricStage7Day}
+
+// TwTweetMetricOrders the metrics the schedule libs can be ranked by
+var TwTweetMetricOrders = map[string]string{
+	"impressions": "impressions",
+	"likes":       "likes",
+	"replies":     "replies",
+	"retweets":    "retweets",
+	"quotes":      "quotes",
+	"bookmarks":   "bookmarks",
+	"engagements": twTweetMetricEngagements,
+}
+
+// TwTweetMetric a metric snapshot of a posted tweet taken at a stage after it is posted,
+// NonPublic is true if the impressions are the non public ones seen by the author
+type TwTweetMetric struct {
+	Id              int64  `json:"id"`
+	UserId          string `json:"user_id"`
+	TweetId         string `json:"tweet_id"`
+	TwScheduleId    int64  `json:"tw_schedule_id"`
+	TwScheduleLibId int64  `json:"tw_schedule_lib_id"`
+	Stage           string `json:"stage"`
+	Status          int    `json:"status"`
+	DueAt           int64  `json:"due_at"`
+	Impressions     int    `json:"impressions"`
+	Likes           int    `json:"likes"`
+	Replies         int    `json:"replies"`
+	Retweets        int    `json:"retweets"`
+	Quotes          int    `json:"quotes"`
+	Bookmarks       int    `json:"bookmarks"`
+	NonPublic       bool   `json:"non_public"`
+	SnapshotAt      int64  `json:"snapshot_at"`
+	CreatedAt       int64  `json:"created_at"`
+	UpdatedAt       int64  `json:"updated_at"`
+}
+
+func (m *TwTweetMetric) TableName() string {
+	return "tw_tweet_metric"
+}
+
+func (m *TwTweetMetric) Save() error {
+	return GetDbInst().Save(m).Error
+}
+
+func (m *TwTweetMetric) Update() error {
+	m.UpdatedAt = tools.GetMillisecond(time.Now())
+	return m.Save()
+}
+
+// TwTweetMetricSum the metrics of the snapshots summed up, the ranking is by the average per tweet
+type TwTweetMetricSum struct {
+	TwScheduleLibId int64  `json:"tw_schedule_lib_id,omitempty"`
+	Stage           string `json:"stage,omitempty"`
+	TweetCount      int64  `json:"tweet_count"`
+	Impressions     int64  `json:"impressions"`
+	Likes           int64  `json:"likes"`
+	Replies         int64  `json:"replies"`
+	Retweets        int64  `json:"retweets"`
+	Quotes          int64  `json:"quotes"`
+	Bookmarks       int64  `json:"bookmarks"`
+	Engagements     int64  `json:"engagements"`
+}
+
+const twTweetMetricSumFields = "count(*) as tweet_count, sum(impressions) as impressions, sum(likes) as likes, " +
+	"sum(replies) as replies, sum(retweets) as retweets, sum(quotes) as quotes, sum(bookmarks) as bookmarks, " +
+	"sum(" + twTweetMetricEngagements + ") as engagements"
+
+// SaveTwTweetMetrics save the snapshots scheduled for a posted tweet
+func SaveTwTweetMetrics(list []*TwTweetMetric) error {
+	tx := GetDbInst().Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	defer func() {
+		tx.Rollback()
+	}()
+
+	for _, v := range list {
+		if err := tx.Save(v).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// GetDueTwTweetMetricList get the pending snapshots due at now, the earliest first
+func GetDueTwTweetMetricList(now int64, limit int64) ([]*TwTweetMetric, error) {
+	results := make([]*TwTweetMetric, 0)
+	db := GetDbInst().Where("status=? and due_at<=?", TwTweetMetricStatusPending, now)
+	err := db.Order("due_at asc, id asc").Limit(limit).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+// GetTwTweetMetricListByTweetIds get the snapshots of the tweets in the order they are due
+func GetTwTweetMetricListByTweetIds(tweetIds []string) ([]*TwTweetMetric, error) {
+	results := make([]*TwTweetMetric, 0)
+	if len(tweetIds) == 0 {
+		return results, nil
+	}
+	err := GetDbInst().Where("tweet_id in (?)", tweetIds).Order("due_at asc, id asc").Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+// GetTwScheduleMetricSumList sum up the snapshots taken of the tweets of the schedule per stage
+func GetTwScheduleMetricSumList(twScheduleId int64) ([]*TwTweetMetricSum, error) {
+	results := make([]*TwTweetMetricSum, 0)
+	db := GetDbInst().Model(&TwTweetMetric{}).Select("stage, " + twTweetMetricSumFields)
+	db = db.Where("tw_schedule_id=? and status=?", twScheduleId, TwTweetMetricStatusDone)
+	err := db.Group("stage").Scan(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return slices.Index(TwTweetMetricStages, results[i].Stage) < slices.Index(TwTweetMetricStages, results[j].Stage)
+	})
+	return results, err
+}
+
+// GetTwScheduleLibMetricRank rank the schedule libs by the average of the metric per tweet at the stage,
+// order is a key of TwTweetMetricOrders
+func GetTwScheduleLibMetricRank(userId, stage, order string, page, limit int64) (int64, []*TwTweetMetricSum, error) {
+	var amount int64
+	results := make([]*TwTweetMetricSum, 0)
+	orderField, ok := TwTweetMetricOrders[order]
+	if !ok {
+		return 0, results, fmt.Errorf("unknown metric order %s", order)
+	}
+
+	db := GetDbInst().Model(&TwTweetMetric{}).Where("stage=? and status=? and tw_schedule_lib_id>0", stage, TwTweetMetricStatusDone)
+	if userId != "" {
+		db = db.Where("user_id=?", userId)
+	}
+
+	if err := db.Select("count(distinct tw_schedule_lib_id)").Count(&amount).Error; err != nil {
+		return 0, results, err
+	}
+
+	db = db.Select("tw_schedule_lib_id, " + twTweetMetricSumFields).Group("tw_schedule_lib_id")
+	db = db.Order(fmt.Sprintf("sum(%s)/count(*) desc, tw_schedule_lib_id asc", orderField))
+	err := db.Offset((page - 1) * limit).Limit(limit).Scan(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return amount, results, nil
+	}
+	return amount, results, err
+}
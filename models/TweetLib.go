@@ -1,10 +1,13 @@
 package models
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/jinzhu/gorm"
 	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/strutils"
 )
 
 const (
@@ -50,6 +53,15 @@ func (m *TweetLib) Del() error {
 	return GetDbInst().Delete(m).Error
 }
 
+// GetTweets the content is a json array of the thread's tweet texts, a plain text is a single tweet
+func (m *TweetLib) GetTweets() []string {
+	tweets := make([]string, 0)
+	if err := json.Unmarshal([]byte(m.Content), &tweets); err != nil {
+		return []string{m.Content}
+	}
+	return tweets
+}
+
 func GeTweetById(id int64) (*TweetLib, error) {
 	result := new(TweetLib)
 	err := GetDbInst().Where("id=?", id).Find(result).Error
@@ -124,3 +136,35 @@ func GetTweetListByPage(category, keywords string, likeCount, retweetCount, page
 
 	return amount, results, err
 }
+
+// GetTweetLibCandidate pick a tweet of the category that is not in excludeIds, by engagement or randomly
+func GetTweetLibCandidate(category string, queryMode int64, excludeIds []int64) (*TweetLib, error) {
+	result := new(TweetLib)
+	db := GetDbInst()
+	db = db.Where("category = ?", category)
+	if len(excludeIds) > 0 {
+		db = db.Where(fmt.Sprintf("id not in (%s)", strutils.IdsToInString(excludeIds)))
+	}
+
+	orderStr := "like_count desc, retweet_count desc"
+	if queryMode == TweetLibQueryModeRandom {
+		orderStr = "rand()"
+	}
+
+	err := db.Order(orderStr).Limit(1).Find(result).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+
+	return result, err
+}
+
+// GetTweetLibListAfterId get the tweets with id greater than lastId in order of id, used to walk the table in batches
+func GetTweetLibListAfterId(lastId uint64, limit int) ([]*TweetLib, error) {
+	results := make([]*TweetLib, 0)
+	err := GetDbInst().Where("id > ?", lastId).Order("id asc").Limit(limit).Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/project-miko/miko/tools"
+)
+
+// TweetLibPostLog records a TweetLib content posted by an evergreen schedule
+type TweetLibPostLog struct {
+	Id           int64  `json:"id"`
+	TweetLibId   int64  `json:"tweet_lib_id"`
+	UserId       string `json:"user_id"`
+	TwScheduleId int64  `json:"tw_schedule_id"`
+	TweetIds     string `json:"tweet_ids"`
+	Content      string `json:"content"`
+	PostedAt     int64  `json:"posted_at"`
+	CreatedAt    int64  `json:"created_at"`
+	UpdatedAt    int64  `json:"updated_at"`
+}
+
+func (l *TweetLibPostLog) TableName() string {
+	return "tweet_lib_post_log"
+}
+
+func (l *TweetLibPostLog) Save() error {
+	return GetDbInst().Save(l).Error
+}
+
+func (l *TweetLibPostLog) Update() error {
+	l.UpdatedAt = tools.GetMillisecond(time.Now())
+	return l.Save()
+}
+
+// GetPostedTweetLibIds get the TweetLib ids posted by the user since the time
+func GetPostedTweetLibIds(userId string, since int64) ([]int64, error) {
+	results := make([]int64, 0)
+	db := GetDbInst().Model(TweetLibPostLog{})
+	db = db.Where("user_id=?", userId)
+	db = db.Where("posted_at>=?", since)
+
+	err := db.Group("tweet_lib_id").Pluck("tweet_lib_id", &results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return results, nil
+	}
+	return results, err
+}
+
+func GetTweetLibPostLogList(userId string, tweetLibId int64, page, limit int64) (int64, []*TweetLibPostLog, error) {
+	var amount int64
+	results := make([]*TweetLibPostLog, 0)
+	db := GetDbInst()
+	if len(userId) > 0 {
+		db = db.Where("user_id=?", userId)
+	}
+	if tweetLibId > 0 {
+		db = db.Where("tweet_lib_id=?", tweetLibId)
+	}
+
+	err := db.Model(TweetLibPostLog{}).Count(&amount).Error
+	if err != nil {
+		return 0, nil, err
+	}
+	if amount == 0 {
+		return 0, results, nil
+	}
+
+	offset := (page - 1) * limit
+	err = db.Offset(offset).Limit(limit).Order("posted_at desc").Find(&results).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return 0, results, nil
+	}
+
+	return amount, results, err
+}
@@ -0,0 +1,5 @@
+package data
+
+type CrondJobNameReq struct {
+	Name string `json:"name" binding:"min=1"`
+}
@@ -0,0 +1,19 @@
+package data
+
+type TwGetPostedTweetListReq struct {
+	UserId   string `json:"user_id"`
+	Source   string `json:"source" binding:"omitempty,oneof=schedule manual"`
+	SourceId int64  `json:"source_id"`
+	Status   int    `json:"status" binding:"omitempty,min=1,max=2"`
+	StartAt  int64  `json:"start_at"` // unit: millisecond
+	EndAt    int64  `json:"end_at"`   // unit: millisecond
+	*BasePage
+}
+
+type TwDeletePostedTweetReq struct {
+	TweetId string `json:"tweet_id" binding:"required"`
+}
+
+type TwDeletePostedThreadReq struct {
+	ThreadId string `json:"thread_id" binding:"required"`
+}
@@ -0,0 +1,5 @@
+package data
+
+type TaskTypeReq struct {
+	TaskType string `json:"task_type" binding:"min=1"`
+}
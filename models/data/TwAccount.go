@@ -0,0 +1,9 @@
+package data
+
+type TwAuthTypeReq struct {
+	AuthType int64 `json:"auth_type" binding:"min=1"`
+}
+
+type TwAccountUserIdReq struct {
+	UserId string `json:"user_id" binding:"min=1"`
+}
@@ -0,0 +1,22 @@
+package data
+
+type TwFollowerWatchReq struct {
+	Account string `json:"account" binding:"required"`
+}
+
+type TwFollowerUserIdReq struct {
+	UserId string `json:"user_id" binding:"required"`
+}
+
+type TwGetFollowerSnapshotListReq struct {
+	UserId string `json:"user_id" binding:"required"`
+	*BasePage
+}
+
+type TwGetFollowerChangeListReq struct {
+	UserId  string `json:"user_id" binding:"required"`
+	Type    int    `json:"type" binding:"omitempty,min=1,max=2"` // 1 follow 2 unfollow
+	StartAt int64  `json:"start_at"`                             // unit: millisecond
+	EndAt   int64  `json:"end_at"`                               // unit: millisecond
+	*BasePage
+}
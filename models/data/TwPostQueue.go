@@ -0,0 +1,41 @@
+package data
+
+type TwPostingSlotItem struct {
+	WeekDay int `json:"week_day" binding:"min=1,max=7"`
+	Hour    int `json:"hour" binding:"min=0,max=23"`
+	Minute  int `json:"minute" binding:"min=0,max=59"`
+}
+
+type TwSetPostingSlotsReq struct {
+	UserId string               `json:"user_id" binding:"min=1"`
+	Slots  []*TwPostingSlotItem `json:"slots" binding:"dive,required"`
+}
+
+type TwPostQueueUserReq struct {
+	UserId string `json:"user_id" binding:"min=1"`
+}
+
+type TwAddPostQueueReq struct {
+	UserId          string                       `json:"user_id" binding:"min=1"`
+	TwScheduleLibId *int64                       `json:"tw_schedule_lib_id,omitempty" binding:"omitempty,min=1"`
+	ThreadList      []*TwAddTweetScheduleReqItem `json:"thread_list" binding:"required_without=TwScheduleLibId,dive"`
+}
+
+type TwPostQueueItemReq struct {
+	UserId  string `json:"user_id" binding:"min=1"`
+	QueueId int64  `json:"queue_id" binding:"min=1"`
+}
+
+type TwReorderPostQueueReq struct {
+	UserId   string  `json:"user_id" binding:"min=1"`
+	QueueIds []int64 `json:"queue_ids" binding:"required,min=1,dive,min=1"`
+}
+
+type TwPostQueueItemResp struct {
+	Id              int64                         `json:"id"`
+	TwScheduleLibId int64                         `json:"tw_schedule_lib_id"`
+	Position        int                           `json:"position"`
+	SlotAt          int64                         `json:"slot_at"`
+	CreatedAt       int64                         `json:"created_at"`
+	ThreadList      []*TwAddTweetScheduleRespItem `json:"thread_list,omitempty"`
+}
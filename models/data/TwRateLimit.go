@@ -0,0 +1,17 @@
+package data
+
+type TwRateLimitListReq struct {
+	Owner string `json:"owner"`
+}
+
+type TwRateLimitItem struct {
+	Owner          string `json:"owner"`
+	Endpoint       string `json:"endpoint"`
+	Limit          int    `json:"limit"`
+	Remaining      int    `json:"remaining"`
+	Reset          int64  `json:"reset"`
+	UpdatedAt      int64  `json:"updated_at"`
+	Exhausted      bool   `json:"exhausted"`
+	Low            bool   `json:"low"`             // less than twRateLimitLowRatio is left
+	ProjectedEmpty int64  `json:"projected_empty"` // when the remaining runs out at the current pace, 0 if not before the reset
+}
@@ -0,0 +1,194 @@
+package data
+
+import (
+	"github.com/shopspring/decimal"
+)
+
+const (
+	TwPromptContextDate     = "date"      // today's date and weekday
+	TwPromptContextMetrics  = "metrics"   // the account metrics of the last 7 days
+	TwPromptContextTweetLib = "tweet_lib" // a TweetLib item of the category
+)
+
+var (
+	AllowTwPromptContext = map[string]struct{}{
+		TwPromptContextDate:     {},
+		TwPromptContextMetrics:  {},
+		TwPromptContextTweetLib: {},
+	}
+)
+
+// TwScheduleLoopReq fires at the week day, hour and minute every loop_unit weeks, loop_count times
+type TwScheduleLoopReq struct {
+	LoopUnit  int `json:"loop_unit" binding:"min=1,max=4"`
+	LoopCount int `json:"loop_count" binding:"min=1"`
+	WeekDay   int `json:"week_day" binding:"min=1,max=7"`
+	Hour      int `json:"hour" binding:"min=0,max=23"`
+	Minute    int `json:"minute" binding:"min=0,max=59"`
+}
+
+// TwEvergreenScheduleConf the content of an evergreen schedule lib
+type TwEvergreenScheduleConf struct {
+	Category          string `json:"category"`
+	QueryMode         int64  `json:"query_mode"`
+	MinRepostInterval int64  `json:"min_repost_interval"` // minimum interval between two posts of the same TweetLib item, unit: hour
+	RewriteWithLLM    bool   `json:"rewrite_with_llm"`
+}
+
+type TwAddEvergreenScheduleReq struct {
+	UserId            string `json:"user_id" binding:"min=1"`
+	Category          string `json:"category" binding:"min=1"`
+	QueryMode         int64  `json:"query_mode" binding:"min=1"`
+	MinRepostInterval int64  `json:"min_repost_interval" binding:"min=0"`
+	RewriteWithLLM    bool   `json:"rewrite_with_llm"`
+	TwScheduleLoopReq
+}
+
+// TwGeneratedScheduleConf the content of a generated schedule lib
+type TwGeneratedScheduleConf struct {
+	PromptTemplate string   `json:"prompt_template"` // text/template, fields: .Date .Weekday .Metrics .TweetLib
+	ContextSources []string `json:"context_sources"`
+	Category       string   `json:"category,omitempty"` // TweetLib category, used by the tweet_lib context source
+	QueryMode      int64    `json:"query_mode,omitempty"`
+	BackupText     string   `json:"backup_text"` // posted when the generation or the moderation fails
+}
+
+type TwAddGeneratedScheduleReq struct {
+	UserId         string   `json:"user_id" binding:"min=1"`
+	PromptTemplate string   `json:"prompt_template" binding:"min=1"`
+	ContextSources []string `json:"context_sources"`
+	Category       string   `json:"category"`
+	QueryMode      int64    `json:"query_mode"`
+	BackupText     string   `json:"backup_text"`
+	TwScheduleLoopReq
+}
+
+type TwGetTweetLibPostLogListReq struct {
+	UserId     string `json:"user_id,omitempty"`
+	TweetLibId int64  `json:"tweet_lib_id,omitempty"`
+	*BasePage
+}
+
+type TwPreviewScheduleReq struct {
+	CronExp    string `json:"cron_exp" binding:"required_without=ScheduleId"`
+	ScheduleId int64  `json:"schedule_id" binding:"required_without=CronExp"`
+	// the run times are shown in the zone, IANA name, e.g. Asia/Tokyo, default UTC. the cron expression is always evaluated in the service time zone
+	DisplayTimeZone string `json:"display_time_zone,omitempty"`
+	Limit           int    `json:"limit,omitempty" binding:"omitempty,min=1,max=100"`
+}
+
+type TwPreviewScheduleResp struct {
+	CronExp         string                  `json:"cron_exp"`
+	DisplayTimeZone string                  `json:"display_time_zone"`
+	RunTimes        []*TwPreviewRunTimeItem `json:"run_times"`
+	Thread          []*TwPreviewThreadItem  `json:"thread,omitempty"`
+	ThreadErrMsg    string                  `json:"thread_err_msg,omitempty"`
+}
+
+type TwPreviewRunTimeItem struct {
+	RunAt int64  `json:"run_at"`
+	Time  string `json:"time"`
+}
+
+type TwPreviewThreadItem struct {
+	SortId         string `json:"sort_id"`
+	Text           string `json:"text"`
+	WeightedLength int    `json:"weighted_length"`
+	OverLength     bool   `json:"over_length"`
+	// the text is the prompt or the content to rewrite, the posted text is generated when the schedule fires
+	GeneratedAtFire bool                  `json:"generated_at_fire"`
+	Medias          []*TwPreviewMediaItem `json:"medias"`
+}
+
+type TwPreviewMediaItem struct {
+	MediaUrl    string `json:"media_url"`
+	ContentType string `json:"content_type"`
+	Category    string `json:"category"`
+	Size        int    `json:"size"`
+	Valid       bool   `json:"valid"`
+	ErrMsg      string `json:"err_msg,omitempty"`
+}
+
+type TwScheduleIdReq struct {
+	ScheduleId int64 `json:"schedule_id" binding:"min=1"`
+}
+
+type TwAddBlackoutWindowReq struct {
+	UserId  string `json:"user_id"` // empty for a global window
+	Name    string `json:"name" binding:"min=1"`
+	StartAt int64  `json:"start_at" binding:"min=1"`
+	EndAt   int64  `json:"end_at" binding:"min=1"`
+	Policy  int    `json:"policy" binding:"min=1"`
+}
+
+type TwBlackoutWindowIdReq struct {
+	Id int64 `json:"id" binding:"min=1"`
+}
+
+type TwGetBlackoutWindowListReq struct {
+	UserId     string `json:"user_id"`
+	WithGlobal bool   `json:"with_global"`
+	*BasePage
+}
+
+type TwSetEmergencyStopReq struct {
+	On     bool   `json:"on"`
+	Reason string `json:"reason"`
+}
+
+type TwGetScheduleLogListReq struct {
+	UserId     string `json:"user_id"`
+	ScheduleId int64  `json:"schedule_id"`
+	Status     int    `json:"status" binding:"omitempty,min=1,max=5"`
+	StartAt    int64  `json:"start_at"` // default 7 days ago
+	EndAt      int64  `json:"end_at"`   // default now
+	*BasePage
+}
+
+type TwGetScheduleStatsReq struct {
+	UserId  string `json:"user_id"`
+	StartAt int64  `json:"start_at"` // default 7 days ago
+	EndAt   int64  `json:"end_at"`   // default now
+}
+
+type TwScheduleLogItemResp struct {
+	Id           int64    `json:"id"`
+	JobId        string   `json:"job_id"`
+	UserId       string   `json:"user_id"`
+	TwScheduleId int64    `json:"tw_schedule_id"`
+	TweetIds     []string `json:"tweet_ids"`
+	ExecDuration int      `json:"exec_duration"`
+	Status       int      `json:"status"`
+	ErrorMsg     string   `json:"error_msg"`
+	ExecAt       int64    `json:"exec_at"`
+}
+
+type TwScheduleStatsItem struct {
+	UserId               string          `json:"user_id"`
+	Total                int             `json:"total"`
+	SuccessCount         int             `json:"success_count"`
+	FailCount            int             `json:"fail_count"`
+	SkippedCount         int             `json:"skipped_count"`
+	DeferredCount        int             `json:"deferred_count"`
+	CanceledCount        int             `json:"canceled_count"`
+	SuccessRate          decimal.Decimal `json:"success_rate"` // percent of the fires that posted or failed
+	P50Duration          int             `json:"p50_duration"` // ms
+	P95Duration          int             `json:"p95_duration"` // ms
+	MostCommonError      string          `json:"most_common_error"`
+	MostCommonErrorCount int             `json:"most_common_error_count"`
+}
+
+// TwPostQuotaResp the post quota of an account and the posts its schedules are projected to make
+type TwPostQuotaResp struct {
+	UserId               string   `json:"user_id"`
+	UserLimit            int      `json:"user_limit"`             // posts per 24 hours
+	UserRemaining        int      `json:"user_remaining"`         // posts left before UserReset
+	UserReset            int64    `json:"user_reset"`             // 0 if no response has reported the cap in the current window
+	ProjectedBeforeReset int      `json:"projected_before_reset"` // posts of the schedules of the account before UserReset
+	Projected24Hour      int      `json:"projected_24hour"`       // posts of the schedules of the account in the next 24 hours
+	AppMonthlyLimit      int      `json:"app_monthly_limit"`      // 0 if not capped
+	AppMonthlyUsed       int      `json:"app_monthly_used"`
+	AppMonthlyProjected  int      `json:"app_monthly_projected"` // posts of all schedules until the end of the month
+	Feasible             bool     `json:"feasible"`
+	Warnings             []string `json:"warnings"`
+}
@@ -0,0 +1,11 @@
+package data
+
+type TwThreadPostIdReq struct {
+	Id int64 `json:"id" binding:"min=1"`
+}
+
+type TwGetThreadPostListReq struct {
+	UserId string `json:"user_id"`
+	Status int    `json:"status" binding:"omitempty,min=1,max=7"`
+	*BasePage
+}
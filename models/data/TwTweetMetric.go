@@ -0,0 +1,17 @@
+package data
+
+type TwGetTweetPerformanceReq struct {
+	TweetId string `json:"tweet_id" binding:"required"`
+}
+
+type TwGetSchedulePerformanceReq struct {
+	TwScheduleId int64 `json:"tw_schedule_id" binding:"min=1"`
+	*BasePage
+}
+
+type TwGetScheduleLibRankReq struct {
+	UserId string `json:"user_id"`
+	Stage  string `json:"stage" binding:"omitempty,oneof=1h 24h 7d"`
+	Order  string `json:"order" binding:"omitempty,oneof=impressions likes replies retweets quotes bookmarks engagements"`
+	*BasePage
+}
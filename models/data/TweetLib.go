@@ -0,0 +1,22 @@
+package data
+
+// TweetLibImportReq the tweets to import into the category, by tweet urls, by the recent tweets of accounts, or by csv rows of "tweet_url[,category]"
+type TweetLibImportReq struct {
+	Category      string   `json:"category" binding:"min=1"`
+	TweetUrls     []string `json:"tweet_urls" binding:"omitempty,dive,url"`
+	Accounts      []string `json:"accounts" binding:"omitempty,dive,min=1"`
+	Csv           string   `json:"csv"`
+	MaxPerAccount int      `json:"max_per_account" binding:"omitempty,min=1,max=100"` // default 20
+	UserId        string   `json:"user_id,omitempty"`                                 // the account whose token is used, the app token if empty
+}
+
+type TweetLibImportResp struct {
+	ImportedIds []uint64                  `json:"imported_ids"`
+	Duplicates  []string                  `json:"duplicates"`
+	Failed      []*TweetLibImportFailItem `json:"failed"`
+}
+
+type TweetLibImportFailItem struct {
+	Source string `json:"source"`
+	ErrMsg string `json:"err_msg"`
+}
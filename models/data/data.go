@@ -54,6 +54,7 @@ type TwUploadMediaReq struct {
 type TwUploadFileReqItem struct {
 	Id       string `json:"id" binding:"min=1"`
 	MediaUrl string `json:"media_url" binding:"required,url"`
+	AltText  string `json:"alt_text" binding:"max=1000"`
 }
 
 type TwUploadMediaResp struct {
@@ -79,9 +80,10 @@ type TwAddTweetScheduleReq struct {
 }
 
 type TwAddTweetScheduleReqItem struct {
-	SortId    string   `json:"sort_id" binding:"min=1"`
-	Text      string   `json:"text" binding:"required_without=MediaUrls"`
-	MediaUrls []string `json:"media_urls" binding:"required_without=Text,dive,url"`
+	SortId        string   `json:"sort_id" binding:"min=1"`
+	Text          string   `json:"text" binding:"required_without=MediaUrls"`
+	MediaUrls     []string `json:"media_urls" binding:"required_without=Text,dive,url"`
+	MediaAltTexts []string `json:"media_alt_texts,omitempty" binding:"omitempty,dive,max=1000"` // by the index of the media urls
 }
 
 type TwUpdateTweetScheduleReq struct {
@@ -118,13 +120,15 @@ type TwGetTweetScheduleListItemResp struct {
 }
 
 type TwAddTweetScheduleRespItem struct {
-	SortId    string   `json:"sort_id"`
-	Text      string   `json:"text,omitempty" `
-	MediaUrls []string `json:"media_urls,omitempty"`
+	SortId        string   `json:"sort_id"`
+	Text          string   `json:"text,omitempty" `
+	MediaUrls     []string `json:"media_urls,omitempty"`
+	MediaAltTexts []string `json:"media_alt_texts,omitempty"`
 }
 
 type GetThreadWithTweetUrlByApiReq struct {
 	TweetUrl string `json:"tweet_url" binding:"required,url"`
+	UserId   string `json:"user_id,omitempty"` // the account whose token is used, the app token if empty
 }
 
 type GetAuthUserListReq struct {
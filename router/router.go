@@ -15,9 +15,23 @@ func Router() {
 
 	// register routes
 	core.AutoRoute(&controllers.IndexController{})
+	core.AutoRoute(&controllers.TwAuthController{})
 	middlewareInst := new(middlewares.Middleware)
 
 	// /security/**
 	securityRouterGroup := core.GetEngine().Group("/security")
 	securityRouterGroup.Use(middlewareInst.AdminToken)
+
+	core.AutoGroupRoute(&controllers.TwPostQueueController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TwScheduleController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TaskController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.CrondController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TwRateLimitController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TwAccountController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TwitterController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TweetLibController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TwThreadPostController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TwPostedTweetController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TwTweetMetricController{}, securityRouterGroup)
+	core.AutoGroupRoute(&controllers.TwFollowerController{}, securityRouterGroup)
 }
@@ -2,6 +2,7 @@ package chatgptapi
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -92,3 +93,39 @@ func SendChatGPTRequest(roleSystemContent, roleUserContent string) (string, erro
 
 	return rawContent, nil
 }
+
+// Moderate check the text with the moderation api, flagged is true if the text violates the usage policies
+func Moderate(text string) (flagged bool, categories []string, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(defaultTimeOut)*time.Second)
+	defer cancel()
+
+	client := openai.NewClient(apiKey)
+	resp, err := client.Moderations(ctx, openai.ModerationRequest{
+		Input: text,
+		Model: openai.ModerationOmniLatest,
+	})
+	if err != nil {
+		return false, nil, err
+	}
+
+	if len(resp.Results) <= 0 {
+		return false, nil, fmt.Errorf("results is empty, response %v", resp)
+	}
+
+	for _, result := range resp.Results {
+		if !result.Flagged {
+			continue
+		}
+		flagged = true
+		b, _ := json.Marshal(result.Categories)
+		m := make(map[string]bool)
+		_ = json.Unmarshal(b, &m)
+		for category, v := range m {
+			if v {
+				categories = append(categories, category)
+			}
+		}
+	}
+
+	return flagged, categories, nil
+}
@@ -7,13 +7,18 @@ import (
 	"fmt"
 	"github.com/g8rswimmer/go-twitter/v2"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 const (
 	defaultTimeOut = 30 // s
+	// the max results of a page of the follow endpoints
+	maxFollowResults = 1000
 
 	tweetCreateEndpoint endpoint = "2/tweets"
+	tweetLookupEndpoint endpoint = "2/tweets"
 
 	rateLimit     = "x-user-limit-24hour-limit"
 	rateRemaining = "x-user-limit-24hour-remaining"
@@ -75,6 +80,11 @@ func (ta *TwitterAPI) SetUserId(userId string) {
 
 func (ta *TwitterAPI) GetFollowersByUserId(pageToken string) (*twitter.UserRaw, *TWResponseMeta, error) {
 	opts := twitter.UserFollowersLookupOpts{
+		UserFields: []twitter.UserField{
+			twitter.UserFieldName,
+			twitter.UserFieldUserName,
+			twitter.UserFieldPublicMetrics,
+		},
 		MaxResults:      ta.MaxResults,
 		PaginationToken: pageToken,
 	}
@@ -95,6 +105,11 @@ func (ta *TwitterAPI) GetFollowersByUserId(pageToken string) (*twitter.UserRaw,
 
 func (ta *TwitterAPI) GetFollowingByUserId(pageToken string) (*twitter.UserRaw, *TWResponseMeta, error) {
 	opts := twitter.UserFollowingLookupOpts{
+		UserFields: []twitter.UserField{
+			twitter.UserFieldName,
+			twitter.UserFieldUserName,
+			twitter.UserFieldPublicMetrics,
+		},
 		MaxResults:      ta.MaxResults,
 		PaginationToken: pageToken,
 	}
@@ -123,6 +138,36 @@ func (ta *TwitterAPI) GetAuthUser() (*twitter.UserRaw, error) {
 }
 
 // getFollowInfo Twitter API Docs https://developer.twitter.com/en/docs/twitter-api/users/follows
+// GetAllFollowerList page through all the followers of the user id, pace is called before each page to wait for the rate limit
+func (ta *TwitterAPI) GetAllFollowerList(pace func() error) ([]*twitter.UserObj, error) {
+	page := ta.followPageAPI()
+	return page.getAllFollowInfo(pacedUserCallback(pace, page.GetFollowersByUserId))
+}
+
+// GetAllFollowingList page through all the users the user id follows, pace is called before each page to wait for the rate limit
+func (ta *TwitterAPI) GetAllFollowingList(pace func() error) ([]*twitter.UserObj, error) {
+	page := ta.followPageAPI()
+	return page.getAllFollowInfo(pacedUserCallback(pace, page.GetFollowingByUserId))
+}
+
+// followPageAPI a copy of the api with the largest page the follow endpoints allow, so fewer requests are paced
+func (ta *TwitterAPI) followPageAPI() *TwitterAPI {
+	page := *ta
+	page.MaxResults = maxFollowResults
+	return &page
+}
+
+func pacedUserCallback(pace func() error, cb userCallbackFunc) userCallbackFunc {
+	return func(pageToken string) (*twitter.UserRaw, *TWResponseMeta, error) {
+		if pace != nil {
+			if err := pace(); err != nil {
+				return nil, nil, err
+			}
+		}
+		return cb(pageToken)
+	}
+}
+
 func (ta *TwitterAPI) getAllFollowInfo(cb userCallbackFunc) ([]*twitter.UserObj, error) {
 	results := make([]*twitter.UserObj, 0)
 	pageToken := ""
@@ -205,6 +250,15 @@ func (ta *TwitterAPI) CreateTweet(req *twitter.CreateTweetRequest) (*twitter.Cre
 	return resp, nil
 }
 
+// DeleteTweet delete a tweet of the user, deleted is false if the tweet does not exist
+func (ta *TwitterAPI) DeleteTweet(tweetId string) (bool, error) {
+	resp, err := ta.Client.DeleteTweet(context.Background(), tweetId)
+	if err != nil {
+		return false, ta.ErrorHandler(err)
+	}
+	return resp.Tweet != nil && resp.Tweet.Deleted, nil
+}
+
 func (ta *TwitterAPI) UserMentionTimeline(userId string, opts *twitter.UserMentionTimelineOpts) (*twitter.UserMentionTimelineResponse, error) {
 	resp, err := ta.Client.UserMentionTimeline(context.Background(), userId, *opts)
 	if err != nil {
@@ -216,19 +270,28 @@ func (ta *TwitterAPI) UserMentionTimeline(userId string, opts *twitter.UserMenti
 
 func (ta *TwitterAPI) TweetLookup(tweetIds []string) (*twitter.TweetLookupResponse, error) {
 	opts := twitter.TweetLookupOpts{
-		Expansions: []twitter.Expansion{twitter.ExpansionAttachmentsMediaKeys},
+		Expansions: []twitter.Expansion{twitter.ExpansionAttachmentsMediaKeys, twitter.ExpansionAuthorID},
 		MediaFields: []twitter.MediaField{
 			twitter.MediaFieldMediaKey,
 			twitter.MediaFieldURL,
 			twitter.MediaFieldType,
 			twitter.MediaFieldVariants,
+			twitter.MediaFieldPreviewImageURL,
 		},
 		TweetFields: []twitter.TweetField{
+			twitter.TweetFieldAuthorID,
 			twitter.TweetFieldInReplyToUserID,
 			twitter.TweetFieldConversationID,
+			twitter.TweetFieldReferencedTweets,
 			twitter.TweetFieldAttachments,
 			twitter.TweetFieldEntities,
 			twitter.TweetFieldCreatedAt,
+			twitter.TweetFieldPublicMetrics,
+		},
+		UserFields: []twitter.UserField{
+			twitter.UserFieldName,
+			twitter.UserFieldUserName,
+			twitter.UserFieldProfileImageURL,
 		},
 	}
 
@@ -240,6 +303,83 @@ func (ta *TwitterAPI) TweetLookup(tweetIds []string) (*twitter.TweetLookupRespon
 	return resp, nil
 }
 
+// TweetMetrics the engagement metrics of a tweet, go-twitter does not decode the bookmark count
+type TweetMetrics struct {
+	Impressions int `json:"impression_count"`
+	Likes       int `json:"like_count"`
+	Replies     int `json:"reply_count"`
+	Retweets    int `json:"retweet_count"`
+	Quotes      int `json:"quote_count"`
+	Bookmarks   int `json:"bookmark_count"`
+}
+
+type TweetMetricsObj struct {
+	ID               string        `json:"id"`
+	PublicMetrics    *TweetMetrics `json:"public_metrics,omitempty"`
+	NonPublicMetrics *TweetMetrics `json:"non_public_metrics,omitempty"`
+}
+
+// TweetMetricsLookupResponse the metrics of the tweets found, the tweets deleted or not visible are in Errors
+type TweetMetricsLookupResponse struct {
+	Tweets []*TweetMetricsObj     `json:"data"`
+	Errors []*TweetLookupErrorObj `json:"errors,omitempty"`
+}
+
+// TweetLookupErrorObj a tweet can not be looked up, Value is its id
+type TweetLookupErrorObj struct {
+	Value  string `json:"value"`
+	Title  string `json:"title"`
+	Detail string `json:"detail"`
+}
+
+// TweetMetricsLookup look up the metrics of the tweets, the non public metrics are only returned to the author
+// of a tweet posted in the last 30 days with a user token
+func (ta *TwitterAPI) TweetMetricsLookup(tweetIds []string, nonPublic bool) (*TweetMetricsLookupResponse, error) {
+	fields := []string{string(twitter.TweetFieldPublicMetrics)}
+	if nonPublic {
+		fields = append(fields, string(twitter.TweetFieldNonPublicMetrics))
+	}
+	query := url.Values{}
+	query.Add("ids", strings.Join(tweetIds, ","))
+	query.Add("tweet.fields", strings.Join(fields, ","))
+
+	req, err := http.NewRequest(http.MethodGet, tweetLookupEndpoint.url(ta.Client.Host)+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("tweet metrics lookup request: %w", err)
+	}
+	req.Header.Add("Accept", "application/json")
+	ta.Client.Authorizer.Add(req)
+
+	resp, err := ta.Client.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("tweet metrics lookup response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		e := &twitter.ErrorResponse{}
+		if err := decoder.Decode(e); err != nil {
+			return nil, &twitter.HTTPError{
+				Status:     resp.Status,
+				StatusCode: resp.StatusCode,
+				URL:        resp.Request.URL.String(),
+			}
+		}
+		e.StatusCode = resp.StatusCode
+		return nil, ta.ErrorHandler(e)
+	}
+
+	raw := &TweetMetricsLookupResponse{}
+	if err := decoder.Decode(raw); err != nil {
+		return nil, &twitter.ResponseDecodeError{
+			Name: "tweet metrics lookup",
+			Err:  err,
+		}
+	}
+	return raw, nil
+}
+
 func (ta *TwitterAPI) UserLookup(ids []string) (*twitter.UserLookupResponse, error) {
 	opt := twitter.UserLookupOpts{
 		UserFields: []twitter.UserField{
@@ -0,0 +1,203 @@
+package twitterapi
+
+import (
+	"fmt"
+
+	"github.com/g8rswimmer/go-twitter/v2"
+)
+
+var (
+	ErrNoOAuth2Token       = fmt.Errorf("the oauth2 token of the twitter client is not set")
+	ErrNoOAuth1Credentials = fmt.Errorf("the oauth1 credentials of the twitter client are not set")
+)
+
+// TwitterClient is the twitter api used by core, v2 calls use the oauth2 token and v1.1 calls use the oauth1 credentials
+type TwitterClient interface {
+	// v2
+	GetAuthUser() (*twitter.UserRaw, error)
+	GetFollowerCount(userIds []string) (*twitter.UserRaw, error)
+	GetUserByAccount(account string) (*twitter.UserRaw, error)
+	UserLookup(ids []string) (*twitter.UserLookupResponse, error)
+	GetFollowersByUserId(userId, pageToken string) (*twitter.UserRaw, *TWResponseMeta, error)
+	GetFollowingByUserId(userId, pageToken string) (*twitter.UserRaw, *TWResponseMeta, error)
+	GetAllFollowerList(userId string, pace func() error) ([]*twitter.UserObj, error)
+	SearchTweets(queryString string, opts twitter.TweetRecentSearchOpts) (*twitter.TweetRaw, *TWResponseMeta, error)
+	TweetLookup(tweetIds []string) (*twitter.TweetLookupResponse, error)
+	TweetMetricsLookup(tweetIds []string, nonPublic bool) (*TweetMetricsLookupResponse, error)
+	UserMentionTimeline(userId string, opts *twitter.UserMentionTimelineOpts) (*twitter.UserMentionTimelineResponse, error)
+	CreateTweet(req *twitter.CreateTweetRequest) (*twitter.CreateTweetResponse, error)
+	DeleteTweet(tweetId string) (bool, error)
+
+	// v1.1
+	VerifyCredentials() error
+	UploadMediaFromUrl(mediaUrl, altText string) (*MediaData, error)
+	GetMediaUploadStatus(mediaId string) (*MediaData, error)
+}
+
+type twitterClient struct {
+	v2 *TwitterAPI
+	v1 *V1
+}
+
+// NewTwitterClient create a client with an oauth2 token (user or app) and the oauth1 credentials of a user,
+// either may be empty, the calls requiring it return an error then.
+// the rate limits of the responses are recorded for the owner, AppOwner or the user id
+func NewTwitterClient(owner, token, accessToken, accessSecret string) TwitterClient {
+	c := new(twitterClient)
+	if token != "" {
+		c.v2, _ = NewTwitterAPI(token, 100)
+		c.v2.Client.Client.Transport = newRateLimitTransport(owner, c.v2.Client.Client.Transport)
+	}
+	if accessToken != "" {
+		c.v1 = NewTwitterAPIV1(accessToken, accessSecret)
+		c.v1.Client.HttpClient.Transport = newRateLimitTransport(owner, c.v1.Client.HttpClient.Transport)
+	}
+
+	return c
+}
+
+func (c *twitterClient) apiV2() (*TwitterAPI, error) {
+	if c.v2 == nil {
+		return nil, ErrNoOAuth2Token
+	}
+	return c.v2, nil
+}
+
+func (c *twitterClient) apiV1() (*V1, error) {
+	if c.v1 == nil {
+		return nil, ErrNoOAuth1Credentials
+	}
+	return c.v1, nil
+}
+
+func (c *twitterClient) GetAuthUser() (*twitter.UserRaw, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetAuthUser()
+}
+
+func (c *twitterClient) GetFollowerCount(userIds []string) (*twitter.UserRaw, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetFollowerCount(userIds)
+}
+
+func (c *twitterClient) GetUserByAccount(account string) (*twitter.UserRaw, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetUserByAccount(account)
+}
+
+func (c *twitterClient) UserLookup(ids []string) (*twitter.UserLookupResponse, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	return api.UserLookup(ids)
+}
+
+func (c *twitterClient) GetFollowersByUserId(userId, pageToken string) (*twitter.UserRaw, *TWResponseMeta, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, nil, err
+	}
+	api.SetUserId(userId)
+	return api.GetFollowersByUserId(pageToken)
+}
+
+func (c *twitterClient) GetFollowingByUserId(userId, pageToken string) (*twitter.UserRaw, *TWResponseMeta, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, nil, err
+	}
+	api.SetUserId(userId)
+	return api.GetFollowingByUserId(pageToken)
+}
+
+func (c *twitterClient) GetAllFollowerList(userId string, pace func() error) ([]*twitter.UserObj, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	api.SetUserId(userId)
+	return api.GetAllFollowerList(pace)
+}
+
+func (c *twitterClient) SearchTweets(queryString string, opts twitter.TweetRecentSearchOpts) (*twitter.TweetRaw, *TWResponseMeta, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, nil, err
+	}
+	return api.SearchTweets(queryString, opts)
+}
+
+func (c *twitterClient) TweetLookup(tweetIds []string) (*twitter.TweetLookupResponse, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	return api.TweetLookup(tweetIds)
+}
+
+func (c *twitterClient) TweetMetricsLookup(tweetIds []string, nonPublic bool) (*TweetMetricsLookupResponse, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	return api.TweetMetricsLookup(tweetIds, nonPublic)
+}
+
+func (c *twitterClient) UserMentionTimeline(userId string, opts *twitter.UserMentionTimelineOpts) (*twitter.UserMentionTimelineResponse, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	return api.UserMentionTimeline(userId, opts)
+}
+
+func (c *twitterClient) CreateTweet(req *twitter.CreateTweetRequest) (*twitter.CreateTweetResponse, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return nil, err
+	}
+	return api.CreateTweet(req)
+}
+
+func (c *twitterClient) DeleteTweet(tweetId string) (bool, error) {
+	api, err := c.apiV2()
+	if err != nil {
+		return false, err
+	}
+	return api.DeleteTweet(tweetId)
+}
+
+func (c *twitterClient) VerifyCredentials() error {
+	api, err := c.apiV1()
+	if err != nil {
+		return err
+	}
+	_, err = api.Client.GetSelf(nil)
+	return err
+}
+
+func (c *twitterClient) UploadMediaFromUrl(mediaUrl, altText string) (*MediaData, error) {
+	api, err := c.apiV1()
+	if err != nil {
+		return nil, err
+	}
+	return api.UploadMediaFromUrl(mediaUrl, altText)
+}
+
+func (c *twitterClient) GetMediaUploadStatus(mediaId string) (*MediaData, error) {
+	api, err := c.apiV1()
+	if err != nil {
+		return nil, err
+	}
+	return api.GetMediaUploadStatus(mediaId)
+}
@@ -1,6 +1,8 @@
 package twitterapi
 
 import (
+	"fmt"
+
 	"github.com/g8rswimmer/go-twitter/v2"
 )
 
@@ -20,3 +22,19 @@ type TWResponseMeta struct {
 	NextToken     string `json:"next_token"`
 	PreviousToken string `json:"previous_token"`
 }
+
+// ErrTokenResponse is the error returned by the oauth2 token endpoint
+type ErrTokenResponse struct {
+	Code        string
+	Description string
+}
+
+func (e *ErrTokenResponse) Error() string {
+	return fmt.Sprintf("refresh token error %s", e.Description)
+}
+
+// IsTokenInvalid tell whether the grant is dead, the account has to be authorized again,
+// invalid_request is a malformed request of the client rather than a dead grant
+func (e *ErrTokenResponse) IsTokenInvalid() bool {
+	return e.Code == "invalid_grant"
+}
@@ -0,0 +1,267 @@
+package faketwitter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/g8rswimmer/go-twitter/v2"
+)
+
+const (
+	mediaExpireSecs = 86400
+
+	MediaStatePending    = "pending"
+	MediaStateInProgress = "in_progress"
+	MediaStateSucceeded  = "succeeded"
+	MediaStateFailed     = "failed"
+)
+
+type Media struct {
+	Id       string
+	Key      string
+	Type     string // photo, animated_gif or video
+	MimeType string
+	Category string
+	Url      string
+	AltText  string
+	Variants []*twitter.MediaVariantObj
+	// Fail makes the processing fail
+	Fail bool
+
+	TotalBytes int
+	Received   int
+	Finalized  bool
+	State      string // empty if the media needs no processing
+	Polls      int
+}
+
+func (m *Media) ready() bool {
+	return m.Finalized && (m.State == "" || m.State == MediaStateSucceeded)
+}
+
+// obj the media with the media_key, the type and the media fields asked for
+func (m *Media) obj(f *fields) *twitter.MediaObj {
+	obj := &twitter.MediaObj{Key: m.Key, Type: m.Type}
+	if f.media["url"] {
+		obj.URL = m.Url
+	}
+	if f.media["preview_image_url"] {
+		obj.PreviewImageURL = m.Url
+	}
+	if f.media["alt_text"] {
+		obj.AltText = m.AltText
+	}
+	if f.media["variants"] {
+		obj.Variants = m.Variants
+	}
+	return obj
+}
+
+// AddMedia add a processed media attached to the tweets added by AddTweet, such as a video with its variants
+func (s *Server) AddMedia(m *Media) *Media {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.addMedia(m)
+	m.Finalized = true
+	return m
+}
+
+func (s *Server) addMedia(m *Media) {
+	if m.Id == "" {
+		m.Id = s.newId()
+	}
+	if m.Key == "" {
+		prefix := "3_"
+		if m.Type != "photo" {
+			prefix = "7_"
+		}
+		m.Key = prefix + m.Id
+	}
+	s.media[m.Id] = m
+}
+
+// GetMedia return the uploaded media
+func (s *Server) GetMedia(id string) *Media {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	return s.media[id]
+}
+
+func (s *Server) mediaByKey(key string) *Media {
+	for _, m := range s.media {
+		if m.Key == key {
+			return m
+		}
+	}
+	return nil
+}
+
+func (s *Server) mediaResp(m *Media) map[string]interface{} {
+	id, _ := strconv.ParseInt(m.Id, 10, 64)
+	resp := map[string]interface{}{
+		"media_id":           id,
+		"media_id_string":    m.Id,
+		"media_key":          m.Key,
+		"size":               m.TotalBytes,
+		"expires_after_secs": mediaExpireSecs,
+	}
+	if m.State != "" {
+		info := map[string]interface{}{"state": m.State}
+		switch m.State {
+		case MediaStatePending, MediaStateInProgress:
+			info["check_after_secs"] = s.MediaCheckAfterSecs
+			info["progress_percent"] = min(100, m.Polls*100/max(s.MediaProcessingPolls, 1))
+		case MediaStateFailed:
+			info["error"] = map[string]interface{}{"code": 1, "name": "InvalidMedia", "message": "Unsupported video format"}
+		}
+		resp["processing_info"] = info
+	}
+	return resp
+}
+
+// uploadMedia handle the simple upload and the INIT, APPEND and FINALIZE commands of the chunked upload
+func (s *Server) uploadMedia(w http.ResponseWriter, r *http.Request, userId string) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var chunk []byte
+	if f, _, err := r.FormFile("media"); err == nil {
+		chunk, _ = io.ReadAll(f)
+		_ = f.Close()
+	}
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		writeError(w, http.StatusForbidden, "the request requires a user context")
+		return
+	}
+
+	switch r.FormValue("command") {
+	case "":
+		if len(chunk) == 0 {
+			writeError(w, http.StatusBadRequest, "media is required")
+			return
+		}
+		m := &Media{Type: "photo", MimeType: http.DetectContentType(chunk), TotalBytes: len(chunk), Received: len(chunk), Finalized: true}
+		s.addMedia(m)
+		writeJson(w, http.StatusOK, s.mediaResp(m))
+	case "INIT":
+		totalBytes, err := strconv.Atoi(r.FormValue("total_bytes"))
+		if err != nil || totalBytes <= 0 {
+			writeError(w, http.StatusBadRequest, "total_bytes is required")
+			return
+		}
+		m := &Media{
+			Type:       mediaType(r.FormValue("media_category"), r.FormValue("media_type")),
+			MimeType:   r.FormValue("media_type"),
+			Category:   r.FormValue("media_category"),
+			TotalBytes: totalBytes,
+		}
+		s.addMedia(m)
+		writeJson(w, http.StatusAccepted, s.mediaResp(m))
+	case "APPEND":
+		m, ok := s.media[r.FormValue("media_id")]
+		if !ok || m.Finalized {
+			writeError(w, http.StatusBadRequest, "media_id is invalid")
+			return
+		}
+		m.Received += len(chunk)
+		w.WriteHeader(http.StatusNoContent)
+	case "FINALIZE":
+		m, ok := s.media[r.FormValue("media_id")]
+		if !ok || m.Finalized {
+			writeError(w, http.StatusBadRequest, "media_id is invalid")
+			return
+		}
+		if m.Received != m.TotalBytes {
+			writeErrorf(w, http.StatusBadRequest, "segments do not add up to the total bytes, %d of %d", m.Received, m.TotalBytes)
+			return
+		}
+		m.Finalized = true
+		if m.Type != "photo" {
+			m.State = MediaStatePending
+		}
+		writeJson(w, http.StatusCreated, s.mediaResp(m))
+	default:
+		writeError(w, http.StatusBadRequest, "command is invalid")
+	}
+}
+
+// mediaStatus handle the STATUS command, the processing advances by a step every call
+func (s *Server) mediaStatus(w http.ResponseWriter, r *http.Request, _ string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	m, ok := s.media[r.URL.Query().Get("media_id")]
+	if !ok || m.State == "" {
+		writeError(w, http.StatusNotFound, "media_id is invalid or needs no processing")
+		return
+	}
+
+	if m.State == MediaStatePending || m.State == MediaStateInProgress {
+		m.Polls++
+		m.State = MediaStateInProgress
+		if m.Polls >= s.MediaProcessingPolls {
+			m.State = MediaStateSucceeded
+			if m.Fail {
+				m.State = MediaStateFailed
+			}
+		}
+	}
+
+	writeJson(w, http.StatusOK, s.mediaResp(m))
+}
+
+// createMediaMetadata handle the alt text of an uploaded media
+func (s *Server) createMediaMetadata(w http.ResponseWriter, r *http.Request, userId string) {
+	req := struct {
+		MediaId string `json:"media_id"`
+		AltText struct {
+			Text string `json:"text"`
+		} `json:"alt_text"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		writeError(w, http.StatusForbidden, "the request requires a user context")
+		return
+	}
+	m, ok := s.media[req.MediaId]
+	if !ok {
+		writeError(w, http.StatusBadRequest, "media_id is invalid")
+		return
+	}
+	if utf8.RuneCountInString(req.AltText.Text) > 1000 {
+		writeError(w, http.StatusBadRequest, "alt_text is too long")
+		return
+	}
+
+	m.AltText = req.AltText.Text
+	w.WriteHeader(http.StatusOK)
+}
+
+func mediaType(category, mimeType string) string {
+	switch {
+	case category == "tweet_gif" || mimeType == "image/gif":
+		return "animated_gif"
+	case category == "tweet_video" || strings.HasPrefix(mimeType, "video/"):
+		return "video"
+	}
+	return "photo"
+}
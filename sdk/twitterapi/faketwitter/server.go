@@ -0,0 +1,301 @@
+// Package faketwitter is an in-memory twitter api served by httptest, it emulates the v2 and v1.1 endpoints
+// used by miko: tweets and threads, chunked media upload with processing states, mentions, user lookup,
+// follows and the rate-limit headers.
+package faketwitter
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/project-miko/miko/sdk/twitterapi"
+)
+
+const (
+	defaultRateLimit      = 900 // per endpoint and token in a window
+	defaultRateLimitReset = 15 * time.Minute
+	defaultUserTweetLimit = 100 // tweets per user in 24 hours
+
+	firstId = 1800000000000000000
+)
+
+type Server struct {
+	*httptest.Server
+
+	// MediaProcessingPolls is the STATUS calls a video or gif stays in_progress before it succeeds
+	MediaProcessingPolls int
+	// MediaCheckAfterSecs is the check_after_secs in the processing info
+	MediaCheckAfterSecs int
+	// UserTweetLimit is the tweets a user can post in 24 hours
+	UserTweetLimit int
+
+	locker      sync.Mutex
+	nextId      int64
+	users       map[string]*User   // user id -> user
+	tokens      map[string]string  // oauth2 token or oauth1 access token -> user id
+	revoked     map[string]bool    // the tokens answered with 401
+	failAt      map[string]int     // endpoint -> the requests to the one answered with 503
+	tweets      map[string]*Tweet  // tweet id -> tweet
+	tweetIds    []string           // the tweet ids in posting order
+	media       map[string]*Media  // media id -> media
+	rateLimits  map[string]int     // endpoint -> limit in a window
+	rateWindows map[string]*window // endpoint and token -> the current window
+	userTweets  map[string]*window // user id -> the tweets posted in 24 hours
+}
+
+type window struct {
+	limit   int
+	used    int
+	resetAt time.Time
+}
+
+// NewServer start a fake twitter server, Close it when done
+func NewServer() *Server {
+	s := &Server{
+		MediaProcessingPolls: 2,
+		MediaCheckAfterSecs:  1,
+		UserTweetLimit:       defaultUserTweetLimit,
+		nextId:               firstId,
+		users:                make(map[string]*User),
+		tokens:               make(map[string]string),
+		revoked:              make(map[string]bool),
+		failAt:               make(map[string]int),
+		tweets:               make(map[string]*Tweet),
+		media:                make(map[string]*Media),
+		rateLimits:           make(map[string]int),
+		rateWindows:          make(map[string]*window),
+		userTweets:           make(map[string]*window),
+	}
+
+	mux := http.NewServeMux()
+	s.handle(mux, "POST /2/tweets", s.createTweet)
+	s.handle(mux, "DELETE /2/tweets/{id}", s.deleteTweet)
+	s.handle(mux, "GET /2/tweets", s.lookupTweets)
+	s.handle(mux, "GET /2/tweets/{id}", s.lookupTweet)
+	s.handle(mux, "GET /2/tweets/search/recent", s.searchTweets)
+	s.handle(mux, "GET /2/users", s.lookupUsers)
+	s.handle(mux, "GET /2/users/{id}", s.lookupUser)
+	s.handle(mux, "GET /2/users/by", s.lookupUserNames)
+	s.handle(mux, "GET /2/users/by/username/{username}", s.lookupUserName)
+	s.handle(mux, "GET /2/users/me", s.lookupMe)
+	s.handle(mux, "GET /2/users/{id}/mentions", s.mentions)
+	s.handle(mux, "GET /2/users/{id}/followers", s.followers)
+	s.handle(mux, "GET /2/users/{id}/following", s.following)
+	s.handle(mux, "POST /2/users/{id}/likes", s.like)
+	s.handle(mux, "POST /2/users/{id}/retweets", s.retweet)
+	s.handle(mux, "GET /1.1/account/verify_credentials.json", s.verifyCredentials)
+	s.handle(mux, "POST /1.1/media/upload.json", s.uploadMedia)
+	s.handle(mux, "GET /1.1/media/upload.json", s.mediaStatus)
+	s.handle(mux, "POST /1.1/media/metadata/create.json", s.createMediaMetadata)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Use point the twitterapi clients to the server, the returned function points them back
+func (s *Server) Use() func() {
+	api, upload := twitterapi.GetHosts()
+	twitterapi.SetHosts(s.URL, s.URL)
+
+	return func() {
+		twitterapi.SetHosts(api, upload)
+	}
+}
+
+// SetRateLimit set the requests allowed in a 15 minutes window of an endpoint, such as "POST /2/tweets"
+func (s *Server) SetRateLimit(endpoint string, limit int) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.rateLimits[endpoint] = limit
+	for k := range s.rateWindows {
+		if strings.HasPrefix(k, endpoint+" ") {
+			delete(s.rateWindows, k)
+		}
+	}
+}
+
+// RevokeToken make the requests with the token answered with 401
+func (s *Server) RevokeToken(token string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.revoked[token] = true
+}
+
+// FailAt make the nth request from now of an endpoint, such as "POST /2/tweets", answered with 503
+func (s *Server) FailAt(endpoint string, n int) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.failAt[endpoint] = n
+}
+
+func (s *Server) newId() string {
+	s.nextId++
+	return strconv.FormatInt(s.nextId, 10)
+}
+
+type handlerFunc func(w http.ResponseWriter, r *http.Request, userId string)
+
+// handle register a handler behind the authorization and the rate limit of the endpoint
+func (s *Server) handle(mux *http.ServeMux, endpoint string, h handlerFunc) {
+	mux.HandleFunc(endpoint, func(w http.ResponseWriter, r *http.Request) {
+		token := requestToken(r)
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+
+		s.locker.Lock()
+		revoked := s.revoked[token]
+		userId := s.tokens[token]
+		ok := s.takeRate(w, endpoint, token)
+		failed := false
+		if n, ok := s.failAt[endpoint]; ok {
+			failed = n <= 1
+			if failed {
+				delete(s.failAt, endpoint)
+			} else {
+				s.failAt[endpoint] = n - 1
+			}
+		}
+		s.locker.Unlock()
+
+		if revoked {
+			writeError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		if failed {
+			writeError(w, http.StatusServiceUnavailable, "Service Unavailable")
+			return
+		}
+		if !ok {
+			writeError(w, http.StatusTooManyRequests, "Too Many Requests")
+			return
+		}
+
+		h(w, r, userId)
+	})
+}
+
+// takeRate count the request in the window of the endpoint and the token, and set the rate-limit headers
+func (s *Server) takeRate(w http.ResponseWriter, endpoint, token string) bool {
+	key := endpoint + " " + token
+	now := time.Now()
+
+	win, ok := s.rateWindows[key]
+	if !ok || now.After(win.resetAt) {
+		limit, ok := s.rateLimits[endpoint]
+		if !ok {
+			limit = defaultRateLimit
+		}
+		win = &window{limit: limit, resetAt: now.Add(defaultRateLimitReset)}
+		s.rateWindows[key] = win
+	}
+
+	allowed := win.used < win.limit
+	if allowed {
+		win.used++
+	}
+
+	w.Header().Set("x-rate-limit-limit", strconv.Itoa(win.limit))
+	w.Header().Set("x-rate-limit-remaining", strconv.Itoa(win.limit-win.used))
+	w.Header().Set("x-rate-limit-reset", strconv.FormatInt(win.resetAt.Unix(), 10))
+
+	return allowed
+}
+
+// requestToken get the bearer token or the oauth1 access token of the request
+func requestToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+		return token
+	}
+
+	params, ok := strings.CutPrefix(auth, "OAuth ")
+	if !ok {
+		return ""
+	}
+	for _, p := range strings.Split(params, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+		if k != "oauth_token" {
+			continue
+		}
+		token, _ := url.QueryUnescape(strings.Trim(v, `"`))
+		if token != "" {
+			return token
+		}
+	}
+
+	// the oauth1 requests without a user token are signed by the consumer only
+	return "consumer"
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, detail string) {
+	writeJson(w, status, map[string]interface{}{
+		"title":  http.StatusText(status),
+		"detail": detail,
+		"type":   "about:blank",
+		"status": status,
+	})
+}
+
+func writeErrorf(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeError(w, status, fmt.Sprintf(format, args...))
+}
+
+// fields the tweet.fields, user.fields, media.fields and expansions of a request, an object has its default fields
+// and the fields asked for only, the includes are only returned for the expansions asked for
+type fields struct {
+	tweet      map[string]bool
+	user       map[string]bool
+	media      map[string]bool
+	expansions map[string]bool
+}
+
+func requestFields(r *http.Request) *fields {
+	q := r.URL.Query()
+	return &fields{
+		tweet:      fieldSet(q.Get("tweet.fields")),
+		user:       fieldSet(q.Get("user.fields")),
+		media:      fieldSet(q.Get("media.fields")),
+		expansions: fieldSet(q.Get("expansions")),
+	}
+}
+
+func fieldSet(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+// page cut a page of n items from the offset carried by the token, the next token is empty on the last page
+func page(total int, token string, maxResults int) (start, end int, next string) {
+	start, _ = strconv.Atoi(token)
+	if maxResults <= 0 {
+		maxResults = 100
+	}
+	start = min(max(start, 0), total)
+	end = min(start+maxResults, total)
+	if end < total {
+		next = strconv.Itoa(end)
+	}
+	return start, end, next
+}
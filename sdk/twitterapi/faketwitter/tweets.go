@@ -0,0 +1,480 @@
+package faketwitter
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Tweet struct {
+	Id               string
+	Text             string
+	AuthorId         string
+	ConversationId   string
+	InReplyToTweetId string
+	InReplyToUserId  string
+	MediaKeys        []string
+	CreatedAt        time.Time
+
+	Impressions int
+	Likes       int
+	Replies     int
+	Retweets    int
+	Quotes      int
+	Bookmarks   int
+}
+
+type tweetMetrics struct {
+	Impressions int `json:"impression_count"`
+	Likes       int `json:"like_count"`
+	Replies     int `json:"reply_count"`
+	Retweets    int `json:"retweet_count"`
+	Quotes      int `json:"quote_count"`
+	Bookmarks   int `json:"bookmark_count"`
+}
+
+type tweetObj struct {
+	Id               string                   `json:"id"`
+	Text             string                   `json:"text"`
+	AuthorId         string                   `json:"author_id,omitempty"`
+	ConversationId   string                   `json:"conversation_id,omitempty"`
+	CreatedAt        string                   `json:"created_at,omitempty"`
+	InReplyToUserId  string                   `json:"in_reply_to_user_id,omitempty"`
+	ReferencedTweets []map[string]string      `json:"referenced_tweets,omitempty"`
+	Attachments      map[string]interface{}   `json:"attachments,omitempty"`
+	PublicMetrics    *tweetMetrics            `json:"public_metrics,omitempty"`
+	NonPublicMetrics map[string]int           `json:"non_public_metrics,omitempty"`
+	Entities         map[string][]interface{} `json:"entities,omitempty"`
+}
+
+var (
+	queryFromReg         = regexp.MustCompile(`from:(\w+)`)
+	queryConversationReg = regexp.MustCompile(`conversation_id:(\d+)`)
+)
+
+// AddTweet add a tweet posted by the user, such as a thread to reconstruct or a mention to answer
+func (s *Server) AddTweet(t *Tweet) *Tweet {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.addTweet(t)
+	return t
+}
+
+func (s *Server) addTweet(t *Tweet) {
+	if t.Id == "" {
+		t.Id = s.newId()
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now().UTC()
+	}
+	t.ConversationId = t.Id
+	if parent, ok := s.tweets[t.InReplyToTweetId]; ok {
+		t.ConversationId = parent.ConversationId
+		t.InReplyToUserId = parent.AuthorId
+		parent.Replies++
+	}
+
+	s.tweets[t.Id] = t
+	s.tweetIds = append(s.tweetIds, t.Id)
+}
+
+// GetTweet return the tweet, nil if it does not exist or is deleted
+func (s *Server) GetTweet(id string) *Tweet {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	return s.tweets[id]
+}
+
+// GetTweetsByUserId return the tweets of the user in posting order
+func (s *Server) GetTweetsByUserId(userId string) []*Tweet {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	results := make([]*Tweet, 0)
+	for _, id := range s.tweetIds {
+		if t, ok := s.tweets[id]; ok && t.AuthorId == userId {
+			results = append(results, t)
+		}
+	}
+	return results
+}
+
+func (s *Server) createTweet(w http.ResponseWriter, r *http.Request, userId string) {
+	req := struct {
+		Text  string `json:"text"`
+		Media *struct {
+			IDs []string `json:"media_ids"`
+		} `json:"media"`
+		Reply *struct {
+			InReplyToTweetID string `json:"in_reply_to_tweet_id"`
+		} `json:"reply"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if _, ok := s.users[userId]; !ok {
+		writeError(w, http.StatusForbidden, "the request requires a user context")
+		return
+	}
+
+	win, ok := s.userTweets[userId]
+	if !ok || time.Now().After(win.resetAt) {
+		win = &window{limit: s.UserTweetLimit, resetAt: time.Now().Add(24 * time.Hour)}
+		s.userTweets[userId] = win
+	}
+	setUserLimitHeaders := func() {
+		w.Header().Set("x-user-limit-24hour-limit", strconv.Itoa(win.limit))
+		w.Header().Set("x-user-limit-24hour-remaining", strconv.Itoa(win.limit-win.used))
+		w.Header().Set("x-user-limit-24hour-reset", strconv.FormatInt(win.resetAt.Unix(), 10))
+	}
+	if win.used >= win.limit {
+		setUserLimitHeaders()
+		writeError(w, http.StatusTooManyRequests, "Too Many Requests")
+		return
+	}
+
+	t := &Tweet{Text: req.Text, AuthorId: userId}
+	if req.Media != nil {
+		for _, id := range req.Media.IDs {
+			m, ok := s.media[id]
+			if !ok || !m.ready() {
+				writeError(w, http.StatusBadRequest, "Your media IDs are invalid.")
+				return
+			}
+			t.MediaKeys = append(t.MediaKeys, m.Key)
+		}
+	}
+	if t.Text == "" && len(t.MediaKeys) == 0 {
+		writeError(w, http.StatusBadRequest, "text or media is required")
+		return
+	}
+	if req.Reply != nil {
+		if _, ok := s.tweets[req.Reply.InReplyToTweetID]; !ok {
+			writeError(w, http.StatusBadRequest, "the tweet replied to is not visible or deleted")
+			return
+		}
+		t.InReplyToTweetId = req.Reply.InReplyToTweetID
+	}
+	for _, v := range s.tweets {
+		if v.AuthorId == userId && t.Text != "" && v.Text == t.Text {
+			writeError(w, http.StatusForbidden, "You are not allowed to create a Tweet with duplicate content.")
+			return
+		}
+	}
+
+	s.addTweet(t)
+	win.used++
+	setUserLimitHeaders()
+
+	writeJson(w, http.StatusCreated, map[string]interface{}{
+		"data": map[string]string{"id": t.Id, "text": t.Text},
+	})
+}
+
+func (s *Server) deleteTweet(w http.ResponseWriter, r *http.Request, userId string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	t, ok := s.tweets[r.PathValue("id")]
+	if !ok {
+		writeJson(w, http.StatusOK, map[string]interface{}{"data": map[string]bool{"deleted": false}})
+		return
+	}
+	if t.AuthorId != userId {
+		writeError(w, http.StatusForbidden, "You are not authorized to delete this Tweet.")
+		return
+	}
+
+	delete(s.tweets, t.Id)
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": map[string]bool{"deleted": true}})
+}
+
+// tweetObj the tweet with the id, the text and the tweet fields asked for
+func (s *Server) tweetObj(t *Tweet, userId string, f *fields) *tweetObj {
+	obj := &tweetObj{Id: t.Id, Text: t.Text}
+	if f.tweet["author_id"] {
+		obj.AuthorId = t.AuthorId
+	}
+	if f.tweet["conversation_id"] {
+		obj.ConversationId = t.ConversationId
+	}
+	if f.tweet["created_at"] {
+		obj.CreatedAt = t.CreatedAt.Format(time.RFC3339)
+	}
+	if f.tweet["in_reply_to_user_id"] {
+		obj.InReplyToUserId = t.InReplyToUserId
+	}
+	if f.tweet["public_metrics"] {
+		obj.PublicMetrics = &tweetMetrics{
+			Impressions: t.Impressions,
+			Likes:       t.Likes,
+			Replies:     t.Replies,
+			Retweets:    t.Retweets,
+			Quotes:      t.Quotes,
+			Bookmarks:   t.Bookmarks,
+		}
+	}
+	if f.tweet["referenced_tweets"] && t.InReplyToTweetId != "" {
+		obj.ReferencedTweets = []map[string]string{{"type": "replied_to", "id": t.InReplyToTweetId}}
+	}
+	if f.tweet["attachments"] && len(t.MediaKeys) > 0 {
+		obj.Attachments = map[string]interface{}{"media_keys": t.MediaKeys}
+	}
+	// the non public metrics are only visible to the author
+	if f.tweet["non_public_metrics"] && t.AuthorId == userId {
+		obj.NonPublicMetrics = map[string]int{"impression_count": t.Impressions}
+	}
+	if !f.tweet["entities"] {
+		return obj
+	}
+
+	mentions := make([]interface{}, 0)
+	for _, word := range strings.Fields(t.Text) {
+		if name, ok := strings.CutPrefix(word, "@"); ok && name != "" {
+			mentions = append(mentions, map[string]string{"username": strings.Trim(name, ".,:;!?")})
+		}
+	}
+	if len(mentions) > 0 {
+		obj.Entities = map[string][]interface{}{"mentions": mentions}
+	}
+
+	return obj
+}
+
+// tweetsResp build the data and the includes of the tweets, the includes are empty if no expansion is asked for,
+// they are left out of the response then
+func (s *Server) tweetsResp(tweets []*Tweet, userId string, f *fields) (data []*tweetObj, includes map[string]interface{}) {
+	data = make([]*tweetObj, 0, len(tweets))
+	media := make([]interface{}, 0)
+	users := make([]interface{}, 0)
+	seenUsers := make(map[string]bool)
+	for _, t := range tweets {
+		data = append(data, s.tweetObj(t, userId, f))
+		if f.expansions["attachments.media_keys"] {
+			for _, key := range t.MediaKeys {
+				if m := s.mediaByKey(key); m != nil {
+					media = append(media, m.obj(f))
+				}
+			}
+		}
+		if u, ok := s.users[t.AuthorId]; ok && f.expansions["author_id"] && !seenUsers[u.Id] {
+			seenUsers[u.Id] = true
+			users = append(users, s.userObj(u, f))
+		}
+	}
+
+	includes = make(map[string]interface{})
+	if len(users) > 0 {
+		includes["users"] = users
+	}
+	if len(media) > 0 {
+		includes["media"] = media
+	}
+	return data, includes
+}
+
+func (s *Server) lookupTweets(w http.ResponseWriter, r *http.Request, userId string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	tweets := make([]*Tweet, 0)
+	errs := make([]map[string]string, 0)
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if t, ok := s.tweets[id]; ok {
+			tweets = append(tweets, t)
+		} else {
+			errs = append(errs, map[string]string{"value": id, "detail": "Could not find tweet with ids: [" + id + "].", "title": "Not Found Error"})
+		}
+	}
+
+	data, includes := s.tweetsResp(tweets, userId, requestFields(r))
+	resp := map[string]interface{}{"data": data}
+	if len(includes) > 0 {
+		resp["includes"] = includes
+	}
+	if len(errs) > 0 {
+		resp["errors"] = errs
+	}
+	writeJson(w, http.StatusOK, resp)
+}
+
+func (s *Server) lookupTweet(w http.ResponseWriter, r *http.Request, userId string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	t, ok := s.tweets[r.PathValue("id")]
+	if !ok {
+		writeErrorf(w, http.StatusNotFound, "Could not find tweet with id: [%s].", r.PathValue("id"))
+		return
+	}
+
+	data, includes := s.tweetsResp([]*Tweet{t}, userId, requestFields(r))
+	resp := map[string]interface{}{"data": data[0]}
+	if len(includes) > 0 {
+		resp["includes"] = includes
+	}
+	writeJson(w, http.StatusOK, resp)
+}
+
+// searchTweets support the from:, conversation_id: and -is:reply operators, the newest first
+func (s *Server) searchTweets(w http.ResponseWriter, r *http.Request, userId string) {
+	q := r.URL.Query()
+	query := q.Get("query")
+	startTime, _ := time.Parse(time.RFC3339, q.Get("start_time"))
+	endTime, _ := time.Parse(time.RFC3339, q.Get("end_time"))
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	authors := make(map[string]bool)
+	for _, m := range queryFromReg.FindAllStringSubmatch(query, -1) {
+		authors[m[1]] = true
+		if u := s.userByName(m[1]); u != nil {
+			authors[u.Id] = true
+		}
+	}
+	conversationId := ""
+	if m := queryConversationReg.FindStringSubmatch(query); m != nil {
+		conversationId = m[1]
+	}
+	excludeReplies := strings.Contains(query, "-is:reply")
+
+	matched := make([]*Tweet, 0)
+	for i := len(s.tweetIds) - 1; i >= 0; i-- {
+		t, ok := s.tweets[s.tweetIds[i]]
+		if !ok {
+			continue
+		}
+		if len(authors) > 0 && !authors[t.AuthorId] {
+			continue
+		}
+		if conversationId != "" && t.ConversationId != conversationId {
+			continue
+		}
+		if excludeReplies && t.InReplyToTweetId != "" {
+			continue
+		}
+		if !startTime.IsZero() && t.CreatedAt.Before(startTime) {
+			continue
+		}
+		if !endTime.IsZero() && !t.CreatedAt.Before(endTime) {
+			continue
+		}
+		matched = append(matched, t)
+	}
+
+	s.writeTweetPage(w, r, matched, q.Get("next_token"), userId)
+}
+
+func (s *Server) mentions(w http.ResponseWriter, r *http.Request, userId string) {
+	q := r.URL.Query()
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	u, ok := s.users[r.PathValue("id")]
+	if !ok {
+		writeErrorf(w, http.StatusNotFound, "Could not find user with id: [%s].", r.PathValue("id"))
+		return
+	}
+
+	mention := "@" + strings.ToLower(u.UserName)
+	matched := make([]*Tweet, 0)
+	for i := len(s.tweetIds) - 1; i >= 0; i-- {
+		t, ok := s.tweets[s.tweetIds[i]]
+		if !ok || t.AuthorId == u.Id {
+			continue
+		}
+		if sinceId := q.Get("since_id"); sinceId != "" && compareId(t.Id, sinceId) <= 0 {
+			continue
+		}
+		if strings.Contains(strings.ToLower(t.Text), mention) {
+			matched = append(matched, t)
+		}
+	}
+
+	s.writeTweetPage(w, r, matched, q.Get("pagination_token"), userId)
+}
+
+func (s *Server) writeTweetPage(w http.ResponseWriter, r *http.Request, tweets []*Tweet, token string, userId string) {
+	n, _ := strconv.Atoi(r.URL.Query().Get("max_results"))
+	start, end, next := page(len(tweets), token, n)
+	tweets = tweets[start:end]
+
+	data, includes := s.tweetsResp(tweets, userId, requestFields(r))
+	meta := map[string]interface{}{"result_count": len(data)}
+	if len(tweets) > 0 {
+		ids := make([]string, 0, len(tweets))
+		for _, t := range tweets {
+			ids = append(ids, t.Id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return compareId(ids[i], ids[j]) < 0 })
+		meta["oldest_id"] = ids[0]
+		meta["newest_id"] = ids[len(ids)-1]
+	}
+	if next != "" {
+		meta["next_token"] = next
+	}
+
+	resp := map[string]interface{}{"meta": meta}
+	if len(data) > 0 {
+		resp["data"] = data
+	}
+	if len(includes) > 0 {
+		resp["includes"] = includes
+	}
+	writeJson(w, http.StatusOK, resp)
+}
+
+// compareId compare two numeric ids
+func compareId(a, b string) int {
+	if len(a) != len(b) {
+		return len(a) - len(b)
+	}
+	return strings.Compare(a, b)
+}
+
+func (s *Server) like(w http.ResponseWriter, r *http.Request, userId string) {
+	s.engage(w, r, userId, "liked", func(t *Tweet) { t.Likes++ })
+}
+
+func (s *Server) retweet(w http.ResponseWriter, r *http.Request, userId string) {
+	s.engage(w, r, userId, "retweeted", func(t *Tweet) { t.Retweets++ })
+}
+
+func (s *Server) engage(w http.ResponseWriter, r *http.Request, userId string, field string, f func(t *Tweet)) {
+	req := struct {
+		TweetID string `json:"tweet_id"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if r.PathValue("id") != userId {
+		writeError(w, http.StatusForbidden, "You are not permitted to perform this action.")
+		return
+	}
+	t, ok := s.tweets[req.TweetID]
+	if !ok {
+		writeErrorf(w, http.StatusNotFound, "Could not find tweet with id: [%s].", req.TweetID)
+		return
+	}
+
+	f(t)
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": map[string]bool{field: true}})
+}
@@ -0,0 +1,254 @@
+package faketwitter
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ChimeraCoder/anaconda"
+	"github.com/g8rswimmer/go-twitter/v2"
+)
+
+type User struct {
+	Id              string
+	Name            string
+	UserName        string
+	ProfileImageUrl string
+	Followers       []string // the ids of the followers, the newest first
+	Following       []string // the ids of the followed users, the newest first
+}
+
+// AddUser add a user, the tokens are the oauth2 token and the oauth1 access token authorizing it
+func (s *Server) AddUser(u *User, tokens ...string) *User {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if u.Id == "" {
+		u.Id = s.newId()
+	}
+	s.users[u.Id] = u
+	for _, token := range tokens {
+		s.tokens[token] = u.Id
+		delete(s.revoked, token)
+	}
+
+	return u
+}
+
+// Follow make the follower follow the user
+func (s *Server) Follow(followerId, userId string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if u, ok := s.users[userId]; ok {
+		u.Followers = append([]string{followerId}, u.Followers...)
+	}
+	if f, ok := s.users[followerId]; ok {
+		f.Following = append([]string{userId}, f.Following...)
+	}
+}
+
+// Unfollow make the follower stop following the user
+func (s *Server) Unfollow(followerId, userId string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	if u, ok := s.users[userId]; ok {
+		u.Followers = removeId(u.Followers, followerId)
+	}
+	if f, ok := s.users[followerId]; ok {
+		f.Following = removeId(f.Following, userId)
+	}
+}
+
+func removeId(ids []string, id string) []string {
+	result := make([]string, 0, len(ids))
+	for _, v := range ids {
+		if v != id {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// userObj the user with the id, the name, the username and the user fields asked for
+func (s *Server) userObj(u *User, f *fields) *twitter.UserObj {
+	obj := &twitter.UserObj{ID: u.Id, Name: u.Name, UserName: u.UserName}
+	if f.user["profile_image_url"] {
+		obj.ProfileImageURL = u.ProfileImageUrl
+	}
+	if f.user["public_metrics"] {
+		tweetCount := 0
+		for _, t := range s.tweets {
+			if t.AuthorId == u.Id {
+				tweetCount++
+			}
+		}
+		obj.PublicMetrics = &twitter.UserMetricsObj{
+			Followers: len(u.Followers),
+			Following: len(u.Following),
+			Tweets:    tweetCount,
+		}
+	}
+
+	return obj
+}
+
+func (s *Server) userByName(userName string) *User {
+	for _, u := range s.users {
+		if strings.EqualFold(u.UserName, userName) {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *Server) writeUsers(w http.ResponseWriter, r *http.Request, users []*User, missing []string) {
+	f := requestFields(r)
+	data := make([]*twitter.UserObj, 0, len(users))
+	for _, u := range users {
+		data = append(data, s.userObj(u, f))
+	}
+
+	resp := map[string]interface{}{"data": data}
+	if len(missing) > 0 {
+		errs := make([]map[string]string, 0, len(missing))
+		for _, v := range missing {
+			errs = append(errs, map[string]string{"value": v, "detail": "Could not find user: " + v, "title": "Not Found Error"})
+		}
+		resp["errors"] = errs
+	}
+	writeJson(w, http.StatusOK, resp)
+}
+
+func (s *Server) lookupUsers(w http.ResponseWriter, r *http.Request, _ string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	users := make([]*User, 0)
+	missing := make([]string, 0)
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if u, ok := s.users[id]; ok {
+			users = append(users, u)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	s.writeUsers(w, r, users, missing)
+}
+
+func (s *Server) lookupUser(w http.ResponseWriter, r *http.Request, _ string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	u, ok := s.users[r.PathValue("id")]
+	if !ok {
+		writeErrorf(w, http.StatusNotFound, "Could not find user with id: [%s].", r.PathValue("id"))
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": s.userObj(u, requestFields(r))})
+}
+
+func (s *Server) lookupUserNames(w http.ResponseWriter, r *http.Request, _ string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	users := make([]*User, 0)
+	missing := make([]string, 0)
+	for _, name := range strings.Split(r.URL.Query().Get("usernames"), ",") {
+		if u := s.userByName(name); u != nil {
+			users = append(users, u)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	s.writeUsers(w, r, users, missing)
+}
+
+func (s *Server) lookupUserName(w http.ResponseWriter, r *http.Request, _ string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	u := s.userByName(r.PathValue("username"))
+	if u == nil {
+		writeErrorf(w, http.StatusNotFound, "Could not find user with username: [%s].", r.PathValue("username"))
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": s.userObj(u, requestFields(r))})
+}
+
+func (s *Server) lookupMe(w http.ResponseWriter, r *http.Request, userId string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	u, ok := s.users[userId]
+	if !ok {
+		writeError(w, http.StatusForbidden, "the request requires a user context")
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": s.userObj(u, requestFields(r))})
+}
+
+func (s *Server) followers(w http.ResponseWriter, r *http.Request, _ string) {
+	s.writeFollows(w, r, func(u *User) []string { return u.Followers })
+}
+
+func (s *Server) following(w http.ResponseWriter, r *http.Request, _ string) {
+	s.writeFollows(w, r, func(u *User) []string { return u.Following })
+}
+
+func (s *Server) writeFollows(w http.ResponseWriter, r *http.Request, ids func(u *User) []string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	u, ok := s.users[r.PathValue("id")]
+	if !ok {
+		writeErrorf(w, http.StatusNotFound, "Could not find user with id: [%s].", r.PathValue("id"))
+		return
+	}
+
+	all := ids(u)
+	maxResults, _ := strconv.Atoi(r.URL.Query().Get("max_results"))
+	start, end, next := page(len(all), r.URL.Query().Get("pagination_token"), maxResults)
+
+	f := requestFields(r)
+	data := make([]*twitter.UserObj, 0, end-start)
+	for _, id := range all[start:end] {
+		if v, ok := s.users[id]; ok {
+			data = append(data, s.userObj(v, f))
+		}
+	}
+
+	meta := map[string]interface{}{"result_count": len(data)}
+	if next != "" {
+		meta["next_token"] = next
+	}
+	if start > 0 {
+		meta["previous_token"] = strconv.Itoa(max(start-maxResults, 0))
+	}
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": data, "meta": meta})
+}
+
+func (s *Server) verifyCredentials(w http.ResponseWriter, r *http.Request, userId string) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	u, ok := s.users[userId]
+	if !ok {
+		writeJson(w, http.StatusUnauthorized, map[string]interface{}{
+			"errors": []map[string]interface{}{{"code": 89, "message": "Invalid or expired token."}},
+		})
+		return
+	}
+
+	id, _ := strconv.ParseInt(u.Id, 10, 64)
+	writeJson(w, http.StatusOK, &anaconda.User{
+		Id:              id,
+		IdStr:           u.Id,
+		Name:            u.Name,
+		ScreenName:      u.UserName,
+		FollowersCount:  len(u.Followers),
+		FriendsCount:    len(u.Following),
+		ProfileImageURL: u.ProfileImageUrl,
+	})
+}
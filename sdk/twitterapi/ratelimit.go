@@ -0,0 +1,260 @@
+package twitterapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/log"
+)
+
+const (
+	// AppOwner is the owner of the rate limits of the app token and the app level caps
+	AppOwner = "app"
+
+	// the endpoints paced by the callers, see normalizeEndpoint
+	EndpointTweetCreate   = "POST /2/tweets"
+	EndpointTweetDelete   = "DELETE /2/tweets/:id"
+	EndpointTweetsLookup  = "GET /2/tweets"
+	EndpointSearchRecent  = "GET /2/tweets/search/recent"
+	EndpointUsersLookup   = "GET /2/users"
+	EndpointUserMentions  = "GET /2/users/:id/mentions"
+	EndpointUserFollowers = "GET /2/users/:id/followers"
+
+	// EndpointUser24Hour and EndpointApp24Hour are the 24 hours post caps, they are not bound to an endpoint
+	EndpointUser24Hour = "24hour user"
+	EndpointApp24Hour  = "24hour app"
+
+	rateLimitWindow     = 15 * 60      // unit: second
+	rateLimit24HourWind = 24 * 60 * 60 // unit: second
+)
+
+var (
+	ErrRateLimitWaitTooLong = fmt.Errorf("the rate limit resets later than the max wait")
+	ErrRateLimitWaitStopped = fmt.Errorf("the rate limit wait is stopped by the shutdown")
+
+	stopWait     = make(chan struct{})
+	stopWaitOnce sync.Once
+)
+
+// RateLimitInfo is the rate limit of an endpoint for an owner, the app or a user id
+type RateLimitInfo struct {
+	Owner     string `json:"owner"`
+	Endpoint  string `json:"endpoint"`
+	Limit     int    `json:"limit"`
+	Remaining int    `json:"remaining"`
+	Reset     int64  `json:"reset"`      // unit: second
+	Window    int64  `json:"window"`     // unit: second
+	UpdatedAt int64  `json:"updated_at"` // unit: millisecond
+}
+
+// ProjectExhaustAt project when the remaining runs out at the pace used so far in the window, 0 if not before the reset
+func (l *RateLimitInfo) ProjectExhaustAt(now time.Time) int64 {
+	if l.Remaining <= 0 {
+		return now.Unix()
+	}
+
+	start := l.Reset - l.Window
+	elapsed := now.Unix() - start
+	used := l.Limit - l.Remaining
+	if elapsed <= 0 || used <= 0 {
+		return 0
+	}
+
+	exhaustAt := now.Unix() + int64(l.Remaining)*elapsed/int64(used)
+	if exhaustAt >= l.Reset {
+		return 0
+	}
+	return exhaustAt
+}
+
+// rateLimitTransport records the rate-limit headers of the responses for the owner of the client
+type rateLimitTransport struct {
+	owner string
+	base  http.RoundTripper
+}
+
+func newRateLimitTransport(owner string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &rateLimitTransport{owner: owner, base: base}
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if e := recordRateLimit(t.owner, normalizeEndpoint(req.Method, req.URL.Path), resp.Header); e != nil {
+		log.Error("", "recordRateLimit() error %s", e.Error())
+	}
+
+	return resp, nil
+}
+
+// normalizeEndpoint replace the ids and the user names in the path, eg: GET /2/users/:id/mentions,
+// the version that leads the path is kept
+func normalizeEndpoint(method, path string) string {
+	segs := strings.Split(path, "/")
+	for i := 2; i < len(segs); i++ {
+		if _, err := strconv.ParseUint(segs[i], 10, 64); err == nil || segs[i-1] == "username" {
+			segs[i] = ":id"
+		}
+	}
+	return method + " " + strings.Join(segs, "/")
+}
+
+func recordRateLimit(owner, endpoint string, header http.Header) error {
+	items := []struct {
+		prefix   string
+		owner    string
+		endpoint string
+		window   int64
+	}{
+		{"x-rate-limit-", owner, endpoint, rateLimitWindow},
+		{"x-user-limit-24hour-", owner, EndpointUser24Hour, rateLimit24HourWind},
+		{"x-app-limit-24hour-", AppOwner, EndpointApp24Hour, rateLimit24HourWind},
+	}
+
+	for _, v := range items {
+		limit, err1 := strconv.Atoi(header.Get(v.prefix + "limit"))
+		remaining, err2 := strconv.Atoi(header.Get(v.prefix + "remaining"))
+		reset, err3 := strconv.ParseInt(header.Get(v.prefix+"reset"), 10, 64)
+		if err1 != nil || err2 != nil || err3 != nil {
+			continue
+		}
+
+		err := SaveRateLimit(&RateLimitInfo{
+			Owner:     v.owner,
+			Endpoint:  v.endpoint,
+			Limit:     limit,
+			Remaining: remaining,
+			Reset:     reset,
+			Window:    v.window,
+			UpdatedAt: tools.GetMillisecond(time.Now()),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// SaveRateLimit save the rate limit of an endpoint for an owner
+func SaveRateLimit(l *RateLimitInfo) error {
+	return store.SaveRateLimit(l)
+}
+
+// GetRateLimit get the rate limit of an endpoint for an owner, nil if it is unknown or its window has reset
+func GetRateLimit(owner, endpoint string) (*RateLimitInfo, error) {
+	l, err := store.GetRateLimit(owner, endpoint)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	if time.Now().Unix() >= l.Reset {
+		return nil, nil
+	}
+
+	return l, nil
+}
+
+// GetRateLimitList get the rate limits of the windows not reset yet, the ones left the least first,
+// the entries reset a day ago are cleaned up
+func GetRateLimitList(owner string) ([]*RateLimitInfo, error) {
+	all, err := store.GetRateLimitList()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	results := make([]*RateLimitInfo, 0)
+	expired := make([]*RateLimitInfo, 0)
+	for _, l := range all {
+		if now >= l.Reset {
+			if now-l.Reset > rateLimit24HourWind {
+				expired = append(expired, l)
+			}
+			continue
+		}
+		if owner != "" && l.Owner != owner {
+			continue
+		}
+		results = append(results, l)
+	}
+	if len(expired) > 0 {
+		if err = store.DelRateLimits(expired); err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		ri := float64(results[i].Remaining) / float64(max(results[i].Limit, 1))
+		rj := float64(results[j].Remaining) / float64(max(results[j].Limit, 1))
+		if ri != rj {
+			return ri < rj
+		}
+		return results[i].Reset < results[j].Reset
+	})
+
+	return results, nil
+}
+
+// Reserve take a request of an endpoint for an owner, return how long to wait if the window is exhausted,
+// an unknown window is not limited. a post takes a request of the 24 hours post caps of the user and the app as well,
+// nothing is taken unless all the windows have a request left
+func Reserve(owner, endpoint string) (time.Duration, error) {
+	fields := []string{RateLimitField(owner, endpoint)}
+	if endpoint == EndpointTweetCreate {
+		fields = append(fields, RateLimitField(owner, EndpointUser24Hour), RateLimitField(AppOwner, EndpointApp24Hour))
+	}
+
+	secs, err := store.ReserveRateLimits(fields, time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(secs) * time.Second, nil
+}
+
+// StopWait wake the waits for the rate limits with ErrRateLimitWaitStopped at shutdown, the later ones fail
+// instead of waiting as well
+func StopWait() {
+	stopWaitOnce.Do(func() {
+		close(stopWait)
+	})
+}
+
+// Wait block until a request of an endpoint for an owner is available, it fails at once if the wait exceeds maxWait,
+// and with ErrRateLimitWaitStopped once StopWait is called
+func Wait(owner, endpoint string, maxWait time.Duration) error {
+	for {
+		d, err := Reserve(owner, endpoint)
+		if err != nil {
+			return err
+		}
+		if d <= 0 {
+			return nil
+		}
+		if d > maxWait {
+			return ErrRateLimitWaitTooLong
+		}
+
+		log.Info("", "rate limit of %s %s is exhausted, wait %s", owner, endpoint, d)
+		timer := time.NewTimer(d)
+		select {
+		case <-stopWait:
+			timer.Stop()
+			return ErrRateLimitWaitStopped
+		case <-timer.C:
+		}
+		maxWait -= d
+	}
+}
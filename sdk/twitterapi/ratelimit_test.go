@@ -0,0 +1,87 @@
+package twitterapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeEndpoint(t *testing.T) {
+	cases := []struct {
+		method, path string
+		want         string
+	}{
+		{"POST", "/2/tweets", EndpointTweetCreate},
+		{"DELETE", "/2/tweets/1790000000000000000", EndpointTweetDelete},
+		{"GET", "/2/users/123/mentions", EndpointUserMentions},
+		{"GET", "/2/users/123/followers", EndpointUserFollowers},
+		{"GET", "/2/users/by/username/miko", "GET /2/users/by/username/:id"},
+		{"GET", "/2/tweets/search/recent", EndpointSearchRecent},
+	}
+	for _, c := range cases {
+		if got := normalizeEndpoint(c.method, c.path); got != c.want {
+			t.Fatalf("%s %s is %q, want %q", c.method, c.path, got, c.want)
+		}
+	}
+}
+
+func TestProjectExhaustAt(t *testing.T) {
+	now := time.Unix(10_000, 0)
+	cases := []struct {
+		name  string
+		limit RateLimitInfo
+		want  int64
+	}{
+		{"exhausted", RateLimitInfo{Limit: 100, Remaining: 0, Reset: 10_600, Window: 900}, 10_000},
+		{"none used", RateLimitInfo{Limit: 100, Remaining: 100, Reset: 10_600, Window: 900}, 0},
+		{"window not started", RateLimitInfo{Limit: 100, Remaining: 50, Reset: 11_000, Window: 900}, 0},
+		// 300s elapsed for 50 used, the 50 left run out in another 300s
+		{"runs out before the reset", RateLimitInfo{Limit: 100, Remaining: 50, Reset: 10_600, Window: 900}, 10_300},
+		{"lasts until the reset", RateLimitInfo{Limit: 100, Remaining: 90, Reset: 10_600, Window: 900}, 0},
+	}
+	for _, c := range cases {
+		if got := c.limit.ProjectExhaustAt(now); got != c.want {
+			t.Fatalf("%s: exhaust at %d, want %d", c.name, got, c.want)
+		}
+	}
+}
+
+func TestReserveTweetCreate(t *testing.T) {
+	old := store
+	SetStore(newMemoryStore())
+	defer SetStore(old)
+
+	reset := time.Now().Unix() + 3600
+	save := func(owner, endpoint string, remaining int) {
+		err := SaveRateLimit(&RateLimitInfo{Owner: owner, Endpoint: endpoint, Limit: 100, Remaining: remaining, Reset: reset, Window: rateLimit24HourWind})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	remaining := func(owner, endpoint string) int {
+		l, err := store.GetRateLimit(owner, endpoint)
+		if err != nil || l == nil {
+			t.Fatalf("rate limit of %s %s: %v, %v", owner, endpoint, l, err)
+		}
+		return l.Remaining
+	}
+
+	save("1", EndpointTweetCreate, 10)
+	save("1", EndpointUser24Hour, 5)
+	save(AppOwner, EndpointApp24Hour, 0)
+
+	// the app cap is exhausted, none of the windows is taken
+	if d, err := Reserve("1", EndpointTweetCreate); err != nil || d <= 0 {
+		t.Fatalf("reserve with the app cap exhausted: %s, %v", d, err)
+	}
+	if remaining("1", EndpointTweetCreate) != 10 || remaining("1", EndpointUser24Hour) != 5 {
+		t.Fatal("a window is taken while another is exhausted")
+	}
+
+	save(AppOwner, EndpointApp24Hour, 3)
+	if d, err := Reserve("1", EndpointTweetCreate); err != nil || d != 0 {
+		t.Fatalf("reserve: %s, %v", d, err)
+	}
+	if remaining("1", EndpointTweetCreate) != 9 || remaining("1", EndpointUser24Hour) != 4 || remaining(AppOwner, EndpointApp24Hour) != 2 {
+		t.Fatal("a request is not taken from every window")
+	}
+}
@@ -0,0 +1,152 @@
+package twitterapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrStateNotFound = fmt.Errorf("the state does not exist or is expired")
+
+	// store keeps the state shared by the processes, it is replaced by SetStore at start
+	store Store = newMemoryStore()
+)
+
+// Store keeps the pending authorizations between the authorization url and its callback,
+// and the rate limits reported by the responses
+type Store interface {
+	// SaveState save the value by the key for expire seconds
+	SaveState(key, value string, expire int64) error
+	// TakeState get the value of the key and delete it, ErrStateNotFound if it does not exist
+	TakeState(key string) (string, error)
+
+	SaveRateLimit(l *RateLimitInfo) error
+	// GetRateLimit get the rate limit of an endpoint for an owner, nil if it is not saved
+	GetRateLimit(owner, endpoint string) (*RateLimitInfo, error)
+	GetRateLimitList() ([]*RateLimitInfo, error)
+	DelRateLimits(list []*RateLimitInfo) error
+	// ReserveRateLimits take a request from the remaining of each window of the fields not reset at now, a unix time,
+	// see RateLimitField. none is taken if any of them has none left, the seconds to wait for all of them are returned then
+	ReserveRateLimits(fields []string, now int64) (int64, error)
+}
+
+// SetStore set the store of the package, the default one keeps the state in the process only
+func SetStore(s Store) {
+	store = s
+}
+
+type memoryState struct {
+	value    string
+	expireAt time.Time
+}
+
+// memoryStore a Store in the process, for the tests and the tools run without redis
+type memoryStore struct {
+	locker     sync.Mutex
+	states     map[string]*memoryState
+	rateLimits map[string]*RateLimitInfo
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		states:     make(map[string]*memoryState),
+		rateLimits: make(map[string]*RateLimitInfo),
+	}
+}
+
+func (s *memoryStore) SaveState(key, value string, expire int64) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	s.states[key] = &memoryState{value: value, expireAt: time.Now().Add(time.Duration(expire) * time.Second)}
+	return nil
+}
+
+func (s *memoryStore) TakeState(key string) (string, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	v, ok := s.states[key]
+	delete(s.states, key)
+	if !ok || time.Now().After(v.expireAt) {
+		return "", ErrStateNotFound
+	}
+
+	return v.value, nil
+}
+
+func (s *memoryStore) SaveRateLimit(l *RateLimitInfo) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	v := *l
+	s.rateLimits[RateLimitField(l.Owner, l.Endpoint)] = &v
+	return nil
+}
+
+func (s *memoryStore) GetRateLimit(owner, endpoint string) (*RateLimitInfo, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	l, ok := s.rateLimits[RateLimitField(owner, endpoint)]
+	if !ok {
+		return nil, nil
+	}
+	v := *l
+	return &v, nil
+}
+
+func (s *memoryStore) GetRateLimitList() ([]*RateLimitInfo, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	results := make([]*RateLimitInfo, 0, len(s.rateLimits))
+	for _, l := range s.rateLimits {
+		v := *l
+		results = append(results, &v)
+	}
+	return results, nil
+}
+
+func (s *memoryStore) DelRateLimits(list []*RateLimitInfo) error {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	for _, l := range list {
+		delete(s.rateLimits, RateLimitField(l.Owner, l.Endpoint))
+	}
+	return nil
+}
+
+func (s *memoryStore) ReserveRateLimits(fields []string, now int64) (int64, error) {
+	s.locker.Lock()
+	defer s.locker.Unlock()
+
+	var wait int64
+	limits := make([]*RateLimitInfo, 0, len(fields))
+	for _, field := range fields {
+		l, ok := s.rateLimits[field]
+		if !ok || now >= l.Reset {
+			continue
+		}
+		if l.Remaining <= 0 {
+			wait = max(wait, l.Reset-now)
+			continue
+		}
+		limits = append(limits, l)
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	for _, l := range limits {
+		l.Remaining--
+	}
+	return 0, nil
+}
+
+// RateLimitField the key of the rate limit of an endpoint for an owner
+func RateLimitField(owner, endpoint string) string {
+	return owner + "|" + endpoint
+}
@@ -2,11 +2,14 @@ package twitterapi
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/g8rswimmer/go-twitter/v2"
@@ -17,23 +20,38 @@ import (
 )
 
 const (
-	twitterDomain = "https://api.twitter.com"
+	authorizeUri  = "https://twitter.com/i/oauth2/authorize"
+	authTokenPath = "/2/oauth2/token"
 
-	authorizeUri = "https://twitter.com/i/oauth2/authorize"
-	authTokenUri = "https://api.twitter.com/2/oauth2/token"
+	codeChallengeMethod = "S256"
+	authStateExpire     = 10 * 60 // unit: second
 )
 
 var (
-	redirectUri = ""
+	// the hosts of the twitter api, they are configurable to run against the fake twitter server
+	apiHost    = "https://api.twitter.com"
+	uploadHost = "https://upload.twitter.com"
 
-	codeChallengeMethod = ""
+	redirectUri = ""
 
 	clientId     = ""
 	clientSecret = ""
+)
 
-	challengeKey = ""
+var (
+	ErrInvalidAuthState = fmt.Errorf("the oauth2 state is invalid or expired")
 )
 
+// AuthState is a pending oauth2 authorization, it is consumed by the callback carrying the state
+type AuthState struct {
+	State        string `json:"state"`
+	CodeVerifier string `json:"code_verifier"`
+	Scope        string `json:"scope"`
+	AuthType     int64  `json:"auth_type"`
+	Initiator    string `json:"initiator"` // the admin or the end user who started the authorization
+	CreatedAt    int64  `json:"created_at"`
+}
+
 type Authorize struct {
 	Token string
 }
@@ -50,24 +68,64 @@ func GetGoTwitterClient(token string) *twitter.Client {
 		Client: &http.Client{
 			Timeout: defaultTimeOut * time.Second,
 		},
-		Host: twitterDomain,
+		Host: apiHost,
 	}
 	return client
 }
 
 func InitConfig() {
+	SetHosts(conf.GetConfigString("twitter", "api_host"), conf.GetConfigString("twitter", "upload_host"))
 	redirectUri = conf.GetConfigString("twitter", "redirect_uri")
-	challengeKey = conf.GetConfigString("twitter", "challenge_key")
-	codeChallengeMethod = conf.GetConfigString("twitter", "code_challenge_method")
 	clientId = conf.GetConfigString("twitter", "client_id")
 	clientSecret = conf.GetConfigString("twitter", "client_secret")
 }
 
-func GetAuthCodeUrl(scope string) string {
-	responseType := "code"
-	state := tools.GetRandStr(16)
-	// todo sha256
-	codeChallenge := challengeKey
+// GetHosts return the hosts of the twitter api
+func GetHosts() (api, upload string) {
+	return apiHost, uploadHost
+}
+
+// SetHosts change the hosts of the twitter api, an empty host keeps the current one
+func SetHosts(api, upload string) {
+	if api != "" {
+		apiHost = strings.TrimSuffix(api, "/")
+	}
+	if upload != "" {
+		uploadHost = strings.TrimSuffix(upload, "/")
+	}
+}
+
+// GetAuthCodeUrl build the authorization url with a new state and a S256 code challenge,
+// the code verifier is kept in the store by the state until the callback
+func GetAuthCodeUrl(scope string, authType int64, initiator string) (string, error) {
+	state, err := randUrlSafeStr(32)
+	if err != nil {
+		return "", err
+	}
+	codeVerifier, err := randUrlSafeStr(32)
+	if err != nil {
+		return "", err
+	}
+
+	authState := &AuthState{
+		State:        state,
+		CodeVerifier: codeVerifier,
+		Scope:        scope,
+		AuthType:     authType,
+		Initiator:    initiator,
+		CreatedAt:    tools.GetMillisecond(time.Now()),
+	}
+	b, err := json.Marshal(authState)
+	if err != nil {
+		return "", err
+	}
+	err = store.SaveState(fmt.Sprintf(conf.AISERTwOAuth2State, state), string(b), authStateExpire)
+	if err != nil {
+		return "", fmt.Errorf("save oauth2 state error %s", err.Error())
+	}
+
+	sum := sha256.Sum256([]byte(codeVerifier))
+	codeChallenge := base64.RawURLEncoding.EncodeToString(sum[:])
 
 	u := url.Values{}
 	u.Add("scope", scope)
@@ -75,15 +133,53 @@ func GetAuthCodeUrl(scope string) string {
 	u.Add("client_id", clientId)
 	u.Add("redirect_uri", redirectUri)
 	u.Add("code_challenge", codeChallenge)
-	u.Add("response_type", responseType)
+	u.Add("response_type", "code")
 	u.Add("code_challenge_method", codeChallengeMethod)
 	authCodeReqUrl := fmt.Sprintf("%s?%s", authorizeUri, u.Encode())
-	return authCodeReqUrl
+	return authCodeReqUrl, nil
 }
 
-func GetAuthToken(code, codeVerifier string) (*TWResponse, error) {
+// consumeAuthState load the pending authorization of the state and delete it, so a state is used only once
+func consumeAuthState(state string) (*AuthState, error) {
+	if state == "" {
+		return nil, ErrInvalidAuthState
+	}
+
+	str, err := store.TakeState(fmt.Sprintf(conf.AISERTwOAuth2State, state))
+	if err == ErrStateNotFound {
+		return nil, ErrInvalidAuthState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	authState := new(AuthState)
+	if err = json.Unmarshal([]byte(str), authState); err != nil {
+		return nil, err
+	}
+	if authState.State != state {
+		return nil, ErrInvalidAuthState
+	}
+
+	return authState, nil
+}
+
+func randUrlSafeStr(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// GetAuthToken validate and consume the state of the callback, then exchange the code for the tokens
+func GetAuthToken(code, state string) (*TWResponse, *AuthState, error) {
 	if code == "" {
-		return nil, fmt.Errorf("the parameter code can not be null")
+		return nil, nil, fmt.Errorf("the parameter code can not be null")
+	}
+	authState, err := consumeAuthState(state)
+	if err != nil {
+		return nil, nil, err
 	}
 	grantType := "authorization_code"
 
@@ -92,8 +188,8 @@ func GetAuthToken(code, codeVerifier string) (*TWResponse, error) {
 	u.Add("client_id", clientId)
 	u.Add("grant_type", grantType)
 	u.Add("redirect_uri", redirectUri)
-	u.Add("code_verifier", codeVerifier)
-	reqUrl := fmt.Sprintf("%s?%s", authTokenUri, u.Encode())
+	u.Add("code_verifier", authState.CodeVerifier)
+	reqUrl := fmt.Sprintf("%s%s?%s", apiHost, authTokenPath, u.Encode())
 
 	req := netutils.NewHttpRequest(reqUrl)
 
@@ -107,7 +203,7 @@ func GetAuthToken(code, codeVerifier string) (*TWResponse, error) {
 
 	resp, _, err := req.Exec(time.Second * 10)
 	if err != nil {
-		return nil, fmt.Errorf("send get token request error %s", err.Error())
+		return nil, nil, fmt.Errorf("send get token request error %s", err.Error())
 	}
 
 	twResponse := new(TWResponse)
@@ -124,14 +220,14 @@ func GetAuthToken(code, codeVerifier string) (*TWResponse, error) {
 			log.Error("", "json.Unmarshal: the raw response map is %v", result)
 		}
 
-		return nil, fmt.Errorf("json.Unmarshal: the byte transfer to struct TWResponse error %s", err.Error())
+		return nil, nil, fmt.Errorf("json.Unmarshal: the byte transfer to struct TWResponse error %s", err.Error())
 	}
 
 	if len(twResponse.Error) > 0 {
-		return nil, fmt.Errorf("refresh token error %s", twResponse.ErrorDescription)
+		return nil, nil, fmt.Errorf("refresh token error %s", twResponse.ErrorDescription)
 	}
 
-	return twResponse, nil
+	return twResponse, authState, nil
 }
 
 // cursor pagination
@@ -162,7 +258,7 @@ func RefreshToken(refreshToken string) (*TWResponse, error) {
 	u := url.Values{}
 	u.Add("grant_type", "refresh_token")
 	u.Add("refresh_token", refreshToken)
-	reqUrl := fmt.Sprintf("%s?%s", authTokenUri, u.Encode())
+	reqUrl := fmt.Sprintf("%s%s?%s", apiHost, authTokenPath, u.Encode())
 	req := netutils.NewHttpRequest(reqUrl)
 
 	basicHeader := fmt.Sprintf("%s:%s", clientId, clientSecret)
@@ -195,7 +291,7 @@ func RefreshToken(refreshToken string) (*TWResponse, error) {
 	}
 
 	if len(twResponse.Error) > 0 {
-		return nil, fmt.Errorf("refresh token error %s", twResponse.ErrorDescription)
+		return nil, &ErrTokenResponse{Code: twResponse.Error, Description: twResponse.ErrorDescription}
 	}
 
 	return twResponse, nil
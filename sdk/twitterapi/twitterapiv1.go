@@ -11,12 +11,14 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/ChimeraCoder/anaconda"
 	"github.com/garyburd/go-oauth/oauth"
 	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/tools"
+	"github.com/project-miko/miko/tools/byteutils"
 	"github.com/project-miko/miko/tools/log"
 	"github.com/project-miko/miko/tools/mediautils"
 )
@@ -31,9 +33,24 @@ const (
 	UploadMediaSucceeded  = "succeeded"
 	UploadMediaFailed     = "failed"
 
-	uploadEndpoint = "https://upload.twitter.com/1.1/media/upload.json"
+	uploadPath         = "/1.1/media/upload.json"
+	metadataCreatePath = "/1.1/media/metadata/create.json"
+
+	MediaCategoryImage = "tweet_image"
+	MediaCategoryGif   = "tweet_gif"
+	MediaCategoryVideo = "tweet_video"
+
+	// the size limits of twitter by media category, MaxMediaSize is the largest one
+	MaxImageSize = 5 * byteutils.MB
+	MaxGifSize   = 15 * byteutils.MB
+	MaxVideoSize = 512 * byteutils.MB
+	MaxMediaSize = MaxVideoSize
+
+	MaxAltTextLength = 1000
 
 	maxRetryCount = 3
+
+	requestTokenExpire = 10 * 60 // unit: second
 )
 
 var (
@@ -41,16 +58,76 @@ var (
 	consumerSecret = ""
 
 	callbackUrl = ""
-	tempAuthMap = sync.Map{}
 
 	oauthCredentials oauth.Credentials
+
+	mediaCategoryMaxSize = map[string]int64{
+		MediaCategoryImage: MaxImageSize,
+		MediaCategoryGif:   MaxGifSize,
+		MediaCategoryVideo: MaxVideoSize,
+	}
 )
 
+// GetMediaCategory the media category of a sniffed mime type, empty if twitter does not accept it
+func GetMediaCategory(mimeType string) string {
+	switch mimeType {
+	case mediautils.MimeImageJpeg, mediautils.MimeImagePng, mediautils.MimeImageWebp:
+		return MediaCategoryImage
+	case mediautils.MimeImageGif:
+		return MediaCategoryGif
+	case mediautils.MimeVideoMp4, mediautils.MimeVideoQuicktime:
+		return MediaCategoryVideo
+	}
+	return ""
+}
+
+// GetMediaMaxSize the size limit of the media category of a sniffed mime type, it is a mediautils.MaxSizeFunc
+func GetMediaMaxSize(mimeType string) (int64, error) {
+	maxSize, ok := mediaCategoryMaxSize[GetMediaCategory(mimeType)]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", mediautils.ErrUnsupportedMediaType, mimeType)
+	}
+	return maxSize, nil
+}
+
+// CheckMediaSize check the size against the limit of the media category
+func CheckMediaSize(category string, size int64) error {
+	maxSize, ok := mediaCategoryMaxSize[category]
+	if !ok {
+		return mediautils.ErrUnsupportedMediaType
+	}
+	if size > maxSize {
+		return &mediautils.ErrMaxFileSizeExceeded{FileSize: size, MaxSize: maxSize}
+	}
+	return nil
+}
+
 type V1 struct {
 	Client      *anaconda.TwitterApi
 	OauthClient *oauth.Client
 }
 
+var (
+	ErrInvalidRequestToken = fmt.Errorf("the oauth1 request token is invalid or expired")
+)
+
+// RequestToken is a pending oauth1 authorization, it is consumed by the callback carrying the token
+type RequestToken struct {
+	Token     string `json:"token"`
+	Secret    string `json:"secret"`
+	Initiator string `json:"initiator"` // the admin or the end user who started the authorization
+	CreatedAt int64  `json:"created_at"`
+}
+
+// AccessCredentials is the result of an oauth1 authorization
+type AccessCredentials struct {
+	Token      string
+	Secret     string
+	UserId     string
+	ScreenName string
+	Initiator  string
+}
+
 type ProcessingInfo struct {
 	State           string `json:"state"`
 	CheckAfterSecs  int    `json:"check_after_secs"`
@@ -86,6 +163,7 @@ type MediaData struct {
 	ExpiresAfterSecs int64           `json:"expires_after_secs,omitempty"`
 	Video            *VideoInfo      `json:"video,omitempty"`
 	ProcessingInfo   *ProcessingInfo `json:"processing_info"`
+	Category         string          `json:"-"` // the media category it is uploaded by
 }
 
 func InitTwitterAPIV1() {
@@ -112,6 +190,7 @@ func InitTwitterAPIV1() {
 
 func NewTwitterAPIV1(accessToken string, accessSecret string) *V1 {
 	client := anaconda.NewTwitterApi(accessToken, accessSecret)
+	client.SetBaseUrl(apiHost + "/1.1")
 
 	client.HttpClient = &http.Client{
 		Timeout: defaultTimeOut * time.Second,
@@ -131,53 +210,104 @@ func (ta *V1) SetCredentials(accessToken string, accessSecret string) {
 	ta.Client.Credentials.Secret = accessSecret
 }
 
-func (ta *V1) GetAuthorizationUrl() (string, error) {
+// GetAuthorizationUrl request a token for the authorization, its secret is kept in the store until the callback
+func (ta *V1) GetAuthorizationUrl(initiator string) (string, error) {
 	authUrl, tempCred, err := ta.Client.AuthorizationURL(callbackUrl)
 	if err != nil {
 		return "", err
 	}
 
-	tempAuthMap.Store(tempCred.Token, tempCred.Secret)
+	requestToken := &RequestToken{
+		Token:     tempCred.Token,
+		Secret:    tempCred.Secret,
+		Initiator: initiator,
+		CreatedAt: tools.GetMillisecond(time.Now()),
+	}
+	b, err := json.Marshal(requestToken)
+	if err != nil {
+		return "", err
+	}
+	err = store.SaveState(fmt.Sprintf(conf.AISERTwOAuth1RequestToken, tempCred.Token), string(b), requestTokenExpire)
+	if err != nil {
+		return "", fmt.Errorf("save oauth1 request token error %s", err.Error())
+	}
 
 	return authUrl, nil
 }
 
-func (ta *V1) ExchangeAccessToken(requestToken, verifier string) (string, string, error) {
-	val, ok := tempAuthMap.Load(requestToken)
-	if !ok {
-		return "", "", fmt.Errorf("get requestSecret by requestToken from temp auth map error")
+// consumeRequestToken load the pending authorization of the token and delete it, so a token is used only once
+func consumeRequestToken(token string) (*RequestToken, error) {
+	if token == "" {
+		return nil, ErrInvalidRequestToken
+	}
+
+	str, err := store.TakeState(fmt.Sprintf(conf.AISERTwOAuth1RequestToken, token))
+	if err == ErrStateNotFound {
+		return nil, ErrInvalidRequestToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	requestToken := new(RequestToken)
+	if err = json.Unmarshal([]byte(str), requestToken); err != nil {
+		return nil, err
+	}
+	if requestToken.Token != token {
+		return nil, ErrInvalidRequestToken
 	}
 
-	requestSecret := val.(string)
+	return requestToken, nil
+}
+
+func (ta *V1) ExchangeAccessToken(token, verifier string) (*AccessCredentials, error) {
+	requestToken, err := consumeRequestToken(token)
+	if err != nil {
+		return nil, err
+	}
 
 	cred := &oauth.Credentials{
-		Token:  requestToken,
-		Secret: requestSecret,
+		Token:  requestToken.Token,
+		Secret: requestToken.Secret,
 	}
 
-	cred, _, err := ta.Client.GetCredentials(cred, verifier)
+	cred, values, err := ta.Client.GetCredentials(cred, verifier)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return cred.Token, cred.Secret, err
+	return &AccessCredentials{
+		Token:      cred.Token,
+		Secret:     cred.Secret,
+		UserId:     values.Get("user_id"),
+		ScreenName: values.Get("screen_name"),
+		Initiator:  requestToken.Initiator,
+	}, nil
 }
 
-func (ta *V1) UploadMediaFromUrl(mediaUrl string) (string, error) {
+// UploadMediaFromUrl download the media and upload it, the processing info of a gif or a video is to be polled by GetMediaUploadStatus
+func (ta *V1) UploadMediaFromUrl(mediaUrl, altText string) (*MediaData, error) {
 	log.Info("", "UploadMediaFromUrl start, media url: %s", mediaUrl)
-	contentType, d, err := mediautils.DownloadFileFromURL(mediaUrl)
+	f, err := mediautils.DownloadFileFromURL(mediaUrl, GetMediaMaxSize)
 	if err != nil {
-		return "", fmt.Errorf("mediautils.DownloadFileFromURL() error %s", err.Error())
+		return nil, fmt.Errorf("mediautils.DownloadFileFromURL() error %w", err)
 	}
-	log.Info("", "download file success, length of bytes: %d", len(d))
+	defer f.Remove()
+	log.Info("", "download file success, mime type: %s, size: %d", f.MimeType, f.Size)
 
-	mediaId, err := ta.UploadMediaBinary(contentType, d)
+	media, err := ta.UploadMediaFile(f)
 	if err != nil {
-		return "", fmt.Errorf("twitterapi.UploadMediaBinary() error %w. mediaUrl: %s", err, mediaUrl)
+		return nil, fmt.Errorf("twitterapi.UploadMediaFile() error %w. mediaUrl: %s", err, mediaUrl)
+	}
+	log.Info("", "upload media success, mediaId:%s", media.MediaIDString)
+
+	if len(altText) != 0 {
+		if err = ta.CreateMediaMetadata(media.MediaIDString, altText); err != nil {
+			return nil, fmt.Errorf("twitterapi.CreateMediaMetadata() error %w. mediaId: %s", err, media.MediaIDString)
+		}
 	}
-	log.Info("", "upload media success, mediaId:%s", mediaId)
 
-	return mediaId, nil
+	return media, nil
 }
 
 func (ta *V1) UploadMediaBase64(mediaData []byte) (string, error) {
@@ -194,7 +324,7 @@ func (ta *V1) UploadMediaBase64(mediaData []byte) (string, error) {
 		return media.MediaIDString, nil
 	} else if strings.HasPrefix(mimeType, MimePrefixVideo) {
 		// initialize video upload
-		videoInit, err := ta.UploadVideoInit(len(mediaData), mimeType)
+		videoInit, err := ta.UploadVideoInit(len(mediaData), mimeType, MediaCategoryVideo)
 		if err != nil {
 			return "", err
 		}
@@ -233,67 +363,116 @@ func (ta *V1) UploadMediaBase64(mediaData []byte) (string, error) {
 	}
 }
 
+// UploadMediaBinary upload the media in memory, the type is sniffed from the magic bytes and checked against contentType
 func (ta *V1) UploadMediaBinary(contentType string, mediaData []byte) (string, error) {
-	// DetectContentType always returns a valid MIME type: if it cannot determine a more specific one,
-	// it returns "application/octet-stream"
-	mimeType := http.DetectContentType(mediaData)
-	//const trimIdx = 4
-	//// if the mime type detected by the standard library does not match the mime type in the http response header,
-	//// then use the mime type in the http response header as the mime type
-	//if len(contentType) > trimIdx && len(mimeType) > trimIdx && contentType[:trimIdx] != mimeType[:trimIdx] {
-	//	mimeType = contentType
-	//}
-
-	if strings.HasPrefix(mimeType, MimePrefixImage) { // image
+	mimeType := mediautils.SniffMediaType(mediaData)
+	if err := mediautils.CheckDeclaredType(contentType, mimeType); err != nil {
+		return "", err
+	}
+
+	media, err := ta.uploadMedia(mimeType, int64(len(mediaData)), bytes.NewReader(mediaData))
+	if err != nil {
+		return "", err
+	}
+
+	return media.MediaIDString, nil
+}
+
+// UploadMediaFile upload the media downloaded into a temp file, the file is read by chunks
+func (ta *V1) UploadMediaFile(f *mediautils.File) (*MediaData, error) {
+	rd, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rd.Close()
+
+	return ta.uploadMedia(f.MimeType, f.Size, rd)
+}
+
+// uploadMedia upload an image by the simple upload, a gif or a video by the chunked upload of its media category
+func (ta *V1) uploadMedia(mimeType string, size int64, r io.Reader) (*MediaData, error) {
+	category := GetMediaCategory(mimeType)
+	if category == "" {
+		return nil, &ErrUnsupportedMimeType{MimeType: mimeType}
+	}
+	if err := CheckMediaSize(category, size); err != nil {
+		return nil, err
+	}
+
+	if category == MediaCategoryImage {
+		mediaData, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+
 		media, err := ta.UploadImage(mediaData)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
 		log.Info("", "upload image success. mediaId:%s", media.MediaIDString)
-		return media.MediaIDString, nil
+		media.Category = category
+		return media, nil
 	}
 
-	const MimeTypeOctetStream = "application/octet-stream" // use binary stream type
-	// initialize video upload
-	videoInit, err := ta.UploadVideoInit(len(mediaData), MimeTypeOctetStream)
+	mediaInit, err := ta.UploadVideoInit(int(size), mimeType, category)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	mediaId := videoInit.MediaIDString
-	log.Info("", "upload video success. command:init, mediaId:%s, totalBytes:%d", mediaId, len(mediaData))
+	mediaId := mediaInit.MediaIDString
+	log.Info("", "upload media success. command:init, mediaId:%s, category:%s, totalBytes:%d", mediaId, category, size)
 
-	segmentIdx := 0
-	for i := 0; i < len(mediaData); i += maxChunkSize {
-		end := i + maxChunkSize
-		if end > len(mediaData) {
-			end = len(mediaData)
+	chunk := make([]byte, maxChunkSize)
+	for segmentIdx := 0; ; segmentIdx++ {
+		n, err := io.ReadFull(r, chunk)
+		if n > 0 {
+			if e := ta.UploadVideoAppend(mediaId, segmentIdx, chunk[:n]); e != nil {
+				return nil, e
+			}
+			log.Info("", "upload media success. command:append, mediaId:%s, segmentIndex:%d", mediaId, segmentIdx)
 		}
-
-		chunk := mediaData[i:end]
-		if err = ta.UploadVideoAppend(mediaId, segmentIdx, chunk); err != nil {
-			return "", err
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
 		}
-
-		log.Info("", "upload video success. command:append, mediaId:%s, segmentIndex:%d", mediaId, segmentIdx)
-		segmentIdx++
 	}
 
-	videoFinalize, err := ta.UploadVideoFinalize(mediaId)
+	mediaFinalize, err := ta.UploadVideoFinalize(mediaId)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	log.Info("", "upload media success. command:finalize, mediaId:%s", mediaId)
+	mediaFinalize.Category = category
+
+	return mediaFinalize, nil
+}
+
+// CreateMediaMetadata set the alt text of an uploaded image or gif
+func (ta *V1) CreateMediaMetadata(mediaId, altText string) error {
+	if utf8.RuneCountInString(altText) > MaxAltTextLength {
+		return fmt.Errorf("the alt text is longer than %d characters", MaxAltTextLength)
 	}
 
-	log.Info("", "upload video success. command:finalize, mediaId:%s", mediaId)
+	body, err := json.Marshal(map[string]interface{}{
+		"media_id": mediaId,
+		"alt_text": map[string]string{"text": altText},
+	})
+	if err != nil {
+		return err
+	}
 
-	return videoFinalize.MediaIDString, nil
+	return ta.doRequest(http.MethodPost, metadataCreatePath, "application/json", nil, bytes.NewReader(body), nil)
 }
 
-func (ta *V1) UploadImage(mediaData []byte) (*anaconda.Media, error) {
+func (ta *V1) UploadImage(mediaData []byte) (*MediaData, error) {
 	v := url.Values{}
+	v.Set("media_category", MediaCategoryImage)
 
-	media := new(anaconda.Media)
+	media := new(MediaData)
 	if err := ta.doFormRequest(v, mediaData, media); err != nil {
 		return nil, err
 	}
@@ -301,14 +480,14 @@ func (ta *V1) UploadImage(mediaData []byte) (*anaconda.Media, error) {
 	return media, nil
 }
 
-func (ta *V1) UploadVideoInit(totalBytes int, mimeType string) (*anaconda.ChunkedMedia, error) {
+func (ta *V1) UploadVideoInit(totalBytes int, mimeType, category string) (*anaconda.ChunkedMedia, error) {
 	// initialize video upload
 	chunkMedia := new(anaconda.ChunkedMedia)
 	v := url.Values{}
 	v.Set("total_bytes", strconv.Itoa(totalBytes))
 	v.Set("command", "INIT")
 	v.Set("media_type", mimeType)
-	v.Set("media_category", "tweet_video")
+	v.Set("media_category", category)
 
 	if err := ta.doFormRequest(v, nil, chunkMedia); err != nil {
 		return nil, err
@@ -353,7 +532,7 @@ func (ta *V1) GetMediaUploadStatus(mediaId string) (*MediaData, error) {
 
 	mediaResponse := new(MediaData)
 
-	if err := ta.doRequest(http.MethodGet, "", &v, nil, mediaResponse); err != nil {
+	if err := ta.doRequest(http.MethodGet, uploadPath, "", &v, nil, mediaResponse); err != nil {
 		return nil, err
 	}
 
@@ -367,11 +546,12 @@ func (ta *V1) doFormRequest(fields url.Values, b []byte, data interface{}) error
 		return err
 	}
 
-	return ta.doRequest(http.MethodPost, contentType, nil, body, data)
+	return ta.doRequest(http.MethodPost, uploadPath, contentType, nil, body, data)
 }
 
-func (ta *V1) doRequest(method string, contentType string, params *url.Values, body io.Reader, data interface{}) error {
-	reqUrl := uploadEndpoint
+// doRequest send a signed request to the upload host, the response is not decoded if data is nil
+func (ta *V1) doRequest(method, path, contentType string, params *url.Values, body io.Reader, data interface{}) error {
+	reqUrl := uploadHost + path
 	if params != nil {
 		reqUrl = reqUrl + "?" + params.Encode()
 	}
@@ -406,6 +586,9 @@ func (ta *V1) doRequest(method string, contentType string, params *url.Values, b
 	} else if resp.StatusCode != 200 {
 		return anaconda.NewApiError(resp)
 	}
+	if data == nil {
+		return nil
+	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
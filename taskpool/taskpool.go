@@ -1,69 +1,562 @@
 package taskpool
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/gomodule/redigo/redis"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/tools"
 	"github.com/project-miko/miko/tools/log"
+	"github.com/project-miko/miko/tools/strutils"
 )
 
 const (
 	taskWorkerNum = 10
+
+	defaultMaxAttempts       = 5
+	defaultVisibilityTimeout = 5 * time.Minute
+
+	// the first retry waits retryBaseDelay, doubled every attempt up to retryMaxDelay
+	retryBaseDelay = 10 * time.Second
+	retryMaxDelay  = 30 * time.Minute
+
+	pollInterval    = 500 * time.Millisecond
+	reapInterval    = time.Second
+	reapBatchSize   = 100
+	restartInterval = time.Second
 )
 
 var (
-	// task worker channel
-	workerChs [taskWorkerNum]chan *Task
-	// load balancing: average strategy
-	workBalanceCounter = 0
-	workBalanceLocker  sync.Mutex
+	handlers       = make(map[string]*handlerConf)
+	handlersLocker sync.RWMutex
+
+	// closed to stop the workers, each worker finishes its current task first
+	stopCh    = make(chan struct{})
+	stopOnce  sync.Once
+	workersWg sync.WaitGroup
+
+	// move the head task of the ready list to the processing zset
+	fetchScript = redis.NewScript(2, `
+local task = redis.call('RPOP', KEYS[1])
+if task then
+	redis.call('ZADD', KEYS[2], ARGV[1], task)
+end
+return task`)
+
+	// remove the task from the processing zset, then put it to the delayed zset or the dead-letter list
+	finishScript = redis.NewScript(2, `
+if redis.call('ZREM', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if ARGV[2] == 'retry' then
+	redis.call('ZADD', KEYS[2], ARGV[4], ARGV[3])
+elseif ARGV[2] == 'dead' then
+	redis.call('LPUSH', KEYS[2], ARGV[3])
+end
+return 1`)
+
+	// redeliver the tasks whose visibility timeout is reached and the delayed tasks that are due
+	reapScript = redis.NewScript(2, `
+local moved = 0
+local expired = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[4])
+for _, raw in ipairs(expired) do
+	redis.call('ZREM', KEYS[1], raw)
+	local task = cjson.decode(raw)
+	task['attempts'] = (task['attempts'] or 0) + 1
+	task['last_error'] = 'visibility timeout'
+	if task['attempts'] >= (task['max_attempts'] or 1) then
+		redis.call('LPUSH', ARGV[3] .. task['type'], cjson.encode(task))
+	else
+		redis.call('LPUSH', ARGV[2] .. task['type'], cjson.encode(task))
+	end
+	moved = moved + 1
+end
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, ARGV[4])
+for _, raw in ipairs(due) do
+	redis.call('ZREM', KEYS[2], raw)
+	local task = cjson.decode(raw)
+	redis.call('LPUSH', ARGV[2] .. task['type'], raw)
+	moved = moved + 1
+end
+return moved`)
+
+	// move the dead tasks back to the ready list with the attempts reset, a task that can not be decoded stays dead
+	requeueScript = redis.NewScript(2, `
+local moved = 0
+local bad = {}
+local raw = redis.call('RPOP', KEYS[1])
+while raw do
+	local ok, task = pcall(cjson.decode, raw)
+	if ok and type(task) == 'table' then
+		task['attempts'] = 0
+		task['last_error'] = ''
+		redis.call('LPUSH', KEYS[2], cjson.encode(task))
+		moved = moved + 1
+	else
+		table.insert(bad, raw)
+	end
+	raw = redis.call('RPOP', KEYS[1])
+end
+for _, v in ipairs(bad) do
+	redis.call('LPUSH', KEYS[1], v)
+end
+return {moved, #bad}`)
 )
 
+// Task a unit of work of a task type, the payload is a json string
 type Task struct {
-	CbFunc func(msg interface{})
-	Msg    interface{}
+	Id          string `json:"id"`
+	Type        string `json:"type"`
+	Payload     string `json:"payload"`
+	Attempts    int    `json:"attempts"`
+	MaxAttempts int    `json:"max_attempts"`
+	LastError   string `json:"last_error"`
+	EnqueuedAt  int64  `json:"enqueued_at"`
+}
+
+// Unmarshal parse the payload of the task into v
+func (t *Task) Unmarshal(v interface{}) error {
+	return json.Unmarshal([]byte(t.Payload), v)
+}
+
+// Handler handle a task, the task is retried with backoff if an error is returned
+type Handler func(task *Task) error
+
+type handlerConf struct {
+	handler           Handler
+	maxAttempts       int
+	visibilityTimeout time.Duration
+
+	succeeded int64
+	failed    int64
+	dead      int64
+}
+
+// ErrRetryAfter returned by a handler to run the task again after the duration, the attempt is not counted
+type ErrRetryAfter struct {
+	After  time.Duration
+	Reason string
+}
+
+func (e *ErrRetryAfter) Error() string {
+	return fmt.Sprintf("retry after %s, %s", e.After, e.Reason)
+}
+
+func RetryAfter(after time.Duration, reason string) error {
+	return &ErrRetryAfter{After: after, Reason: reason}
+}
+
+type QueueStat struct {
+	Type           string `json:"type"`
+	Ready          int    `json:"ready"`
+	DeadLetter     int    `json:"dead_letter"`
+	OldestReadyAge int64  `json:"oldest_ready_age"` // ms, how long the oldest ready task has been waiting
+	Succeeded      int64  `json:"succeeded"`        // since the process started
+	Failed         int64  `json:"failed"`           // since the process started
+	Dead           int64  `json:"dead"`             // since the process started
+}
+
+type QueueStats struct {
+	Processing int          `json:"processing"`
+	Delayed    int          `json:"delayed"`
+	Queues     []*QueueStat `json:"queues"`
+}
+
+// RegisterHandler register the handler of the task type, maxAttempts and visibilityTimeout use the defaults if they are zero
+func RegisterHandler(taskType string, handler Handler, maxAttempts int, visibilityTimeout time.Duration) {
+	if maxAttempts <= 0 {
+		maxAttempts = defaultMaxAttempts
+	}
+	if visibilityTimeout <= 0 {
+		visibilityTimeout = defaultVisibilityTimeout
+	}
+
+	handlersLocker.Lock()
+	defer handlersLocker.Unlock()
+	handlers[taskType] = &handlerConf{
+		handler:           handler,
+		maxAttempts:       maxAttempts,
+		visibilityTimeout: visibilityTimeout,
+	}
+}
+
+func getHandlerConf(taskType string) *handlerConf {
+	handlersLocker.RLock()
+	defer handlersLocker.RUnlock()
+	return handlers[taskType]
 }
 
-// task load balancing atomic counter
-func getBalanceNum() int {
-	workBalanceLocker.Lock()
-	defer workBalanceLocker.Unlock()
-	workBalanceCounter++
-	if workBalanceCounter >= taskWorkerNum {
-		workBalanceCounter = 0
+func getTaskTypes() []string {
+	handlersLocker.RLock()
+	defer handlersLocker.RUnlock()
+
+	taskTypes := make([]string, 0, len(handlers))
+	for k := range handlers {
+		taskTypes = append(taskTypes, k)
 	}
-	return workBalanceCounter
+	sort.Strings(taskTypes)
+	return taskTypes
+}
+
+// Enqueue add a task of the type to run as soon as possible
+func Enqueue(taskType string, payload interface{}) error {
+	return EnqueueAt(taskType, payload, time.Time{})
 }
 
-func AddPushTask(t *Task) {
-	wid := getBalanceNum()
-	workerChs[wid] <- t
+// EnqueueAt add a task of the type to run at the time
+func EnqueueAt(taskType string, payload interface{}, runAt time.Time) error {
+	hc := getHandlerConf(taskType)
+	if hc == nil {
+		return fmt.Errorf("no handler registered for task type %s", taskType)
+	}
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	task := &Task{
+		Id:          strutils.GetUUID(),
+		Type:        taskType,
+		Payload:     string(b),
+		MaxAttempts: hc.maxAttempts,
+		EnqueuedAt:  tools.GetMillisecond(time.Now()),
+	}
+	raw, err := json.Marshal(task)
+	if err != nil {
+		return err
+	}
+
+	client := models.GetRdbInst().Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if runAt.After(time.Now()) {
+		_, err = client.Do("ZADD", conf.AISERTaskDelayed, tools.GetMillisecond(runAt), raw)
+	} else {
+		_, err = client.Do("LPUSH", fmt.Sprintf(conf.AISERTaskQueue, taskType), raw)
+	}
+
+	return err
 }
 
 // initialize task worker
 func InitTaskListeners() {
-
 	for i := 0; i < taskWorkerNum; i++ {
+		index := i
+		supervise(fmt.Sprintf("task worker %d", index), func() {
+			runWorker(index)
+		})
+	}
+
+	supervise("task reaper", runReaper)
+}
+
+// Stop stop fetching tasks and wait for the tasks being handled, the tasks left stay in redis for the next start
+func Stop(ctx context.Context) error {
+	stopOnce.Do(func() {
+		close(stopCh)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		workersWg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// supervise run fn in a goroutine, and run it again if it panics
+func supervise(name string, fn func()) {
+	workersWg.Add(1)
+	go func() {
+		defer workersWg.Done()
+		for runSafely(name, fn) {
+			time.Sleep(restartInterval)
+		}
+	}()
+}
+
+func runSafely(name string, fn func()) (panicked bool) {
+	defer func() {
+		if x := recover(); x != nil {
+			log.Error("", "%s panic %v, restart", name, x)
+			log.PrintPanicStack()
+			panicked = true
+		}
+	}()
+
+	fn()
+	return false
+}
+
+// sleepOrStop wait for the duration, false is returned if the pool is stopped meanwhile
+func sleepOrStop(d time.Duration) bool {
+	select {
+	case <-stopCh:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func runWorker(index int) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		if !fetchAndHandle(index) && !sleepOrStop(pollInterval) {
+			return
+		}
+	}
+}
+
+func runReaper() {
+	for {
+		if err := reap(); err != nil {
+			log.Error("", "taskpool reap() error %s", err.Error())
+		}
+		if !sleepOrStop(reapInterval) {
+			return
+		}
+	}
+}
+
+func reap() error {
+	client := models.GetRdbInst().Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	queuePrefix := strings.Replace(conf.AISERTaskQueue, "%s", "", 1)
+	deadLetterPrefix := strings.Replace(conf.AISERTaskDeadLetter, "%s", "", 1)
+	_, err := reapScript.Do(client, conf.AISERTaskProcessing, conf.AISERTaskDelayed,
+		tools.GetMillisecond(time.Now()), queuePrefix, deadLetterPrefix, reapBatchSize)
+	return err
+}
+
+// fetchAndHandle handle a task of the first task type having one, the task types are polled from a different start for every worker
+func fetchAndHandle(index int) bool {
+	taskTypes := getTaskTypes()
+	for i := range taskTypes {
+		taskType := taskTypes[(index+i)%len(taskTypes)]
+		raw, err := fetch(taskType)
+		if err != nil {
+			log.Error("", "taskpool fetch() error %s, task type: %s", err.Error(), taskType)
+			continue
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		handle(raw)
+		return true
+	}
+
+	return false
+}
+
+func fetch(taskType string) (string, error) {
+	hc := getHandlerConf(taskType)
+	if hc == nil {
+		return "", nil
+	}
+
+	client := models.GetRdbInst().Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	deadline := tools.GetMillisecond(time.Now().Add(hc.visibilityTimeout))
+	raw, err := redis.String(fetchScript.Do(client, fmt.Sprintf(conf.AISERTaskQueue, taskType), conf.AISERTaskProcessing, deadline))
+	if err == redis.ErrNil {
+		return "", nil
+	}
+
+	return raw, err
+}
+
+func handle(raw string) {
+	task := new(Task)
+	if err := json.Unmarshal([]byte(raw), task); err != nil {
+		log.Error("", "taskpool unmarshal task error %s, task: %s", err.Error(), raw)
+		finish(raw, "ack", "", nil, 0)
+		return
+	}
+
+	hc := getHandlerConf(task.Type)
+	if hc == nil { // the handler may be registered by another process, the task is redelivered after the visibility timeout
+		log.Error("", "taskpool no handler registered for task type %s", task.Type)
+		return
+	}
+
+	err := callHandler(hc.handler, task)
+	if err == nil {
+		atomic.AddInt64(&hc.succeeded, 1)
+		finish(raw, "ack", "", nil, 0)
+		return
+	}
+
+	if e, ok := err.(*ErrRetryAfter); ok {
+		finish(raw, "retry", conf.AISERTaskDelayed, task, tools.GetMillisecond(time.Now().Add(e.After)))
+		return
+	}
+
+	atomic.AddInt64(&hc.failed, 1)
+	task.Attempts++
+	task.LastError = err.Error()
+	log.Error("", "taskpool task %s of type %s failed, attempts: %d, error %s", task.Id, task.Type, task.Attempts, err.Error())
+
+	if task.Attempts >= task.MaxAttempts {
+		atomic.AddInt64(&hc.dead, 1)
+		finish(raw, "dead", fmt.Sprintf(conf.AISERTaskDeadLetter, task.Type), task, 0)
+		return
+	}
+
+	finish(raw, "retry", conf.AISERTaskDelayed, task, tools.GetMillisecond(time.Now().Add(retryDelay(task.Attempts))))
+}
+
+// callHandler call the handler, a panic is returned as an error
+func callHandler(handler Handler, task *Task) (err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			log.PrintPanicStack()
+			err = fmt.Errorf("panic %v", x)
+		}
+	}()
+
+	return handler(task)
+}
+
+// finish ack the task, or move it to the target key as the mode says
+func finish(raw, mode, targetKey string, task *Task, score int64) {
+	newRaw := ""
+	if task != nil {
+		b, err := json.Marshal(task)
+		if err != nil {
+			log.Error("", "taskpool marshal task error %s", err.Error())
+			return
+		}
+		newRaw = string(b)
+	}
+	if len(targetKey) == 0 {
+		targetKey = conf.AISERTaskProcessing
+	}
+
+	client := models.GetRdbInst().Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	if _, err := finishScript.Do(client, conf.AISERTaskProcessing, targetKey, raw, mode, newRaw, score); err != nil {
+		log.Error("", "taskpool finish task error %s, mode: %s", err.Error(), mode)
+	}
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < attempts && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+
+	return delay
+}
 
-		go func(index int) {
-			defer log.PrintPanicStackError()
-			workerChs[index] = make(chan *Task, 10000)
-
-			for {
-				select {
-				case task := <-workerChs[index]:
-					l := len(workerChs[index])
-					if l > 5000 {
-						log.Warning("", "task pool channel(%d) overstock (greater then 5k)", l)
-					}
-					// discard if greater than 8000
-					if l > 8000 {
-						log.Error("", "task pool channel(%d) overstock (greater then 8k)", l)
-						continue
-					}
-
-					task.CbFunc(task.Msg)
-				}
+// GetQueueStats the depth and the age of the queues of the registered task types
+func GetQueueStats() (*QueueStats, error) {
+	client := models.GetRdbInst().Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	stats := &QueueStats{
+		Queues: make([]*QueueStat, 0),
+	}
+
+	var err error
+	stats.Processing, err = redis.Int(client.Do("ZCARD", conf.AISERTaskProcessing))
+	if err != nil {
+		return nil, err
+	}
+	stats.Delayed, err = redis.Int(client.Do("ZCARD", conf.AISERTaskDelayed))
+	if err != nil {
+		return nil, err
+	}
+
+	now := tools.GetMillisecond(time.Now())
+	for _, taskType := range getTaskTypes() {
+		hc := getHandlerConf(taskType)
+		stat := &QueueStat{
+			Type:      taskType,
+			Succeeded: atomic.LoadInt64(&hc.succeeded),
+			Failed:    atomic.LoadInt64(&hc.failed),
+			Dead:      atomic.LoadInt64(&hc.dead),
+		}
+
+		queueKey := fmt.Sprintf(conf.AISERTaskQueue, taskType)
+		stat.Ready, err = redis.Int(client.Do("LLEN", queueKey))
+		if err != nil {
+			return nil, err
+		}
+		stat.DeadLetter, err = redis.Int(client.Do("LLEN", fmt.Sprintf(conf.AISERTaskDeadLetter, taskType)))
+		if err != nil {
+			return nil, err
+		}
+
+		// tasks are pushed to the left and popped from the right, the oldest one is the last
+		oldest, err := redis.String(client.Do("LINDEX", queueKey, -1))
+		if err != nil && err != redis.ErrNil {
+			return nil, err
+		}
+		if len(oldest) != 0 {
+			task := new(Task)
+			if e := json.Unmarshal([]byte(oldest), task); e == nil {
+				stat.OldestReadyAge = now - task.EnqueuedAt
 			}
-		}(i)
+		}
+
+		stats.Queues = append(stats.Queues, stat)
+	}
+
+	return stats, nil
+}
+
+// RequeueDeadLetter move the dead tasks of the type back to its queue with the attempts reset in one step,
+// return the number of tasks moved. the tasks that can not be decoded are kept in the dead-letter list
+func RequeueDeadLetter(taskType string) (int, error) {
+	client := models.GetRdbInst().Get()
+	defer func() {
+		_ = client.Close()
+	}()
+
+	counts, err := redis.Ints(requeueScript.Do(client, fmt.Sprintf(conf.AISERTaskDeadLetter, taskType), fmt.Sprintf(conf.AISERTaskQueue, taskType)))
+	if err != nil {
+		return 0, err
 	}
+	if counts[1] > 0 {
+		log.Error("", "taskpool %d dead tasks of type %s can not be decoded, they are kept", counts[1], taskType)
+	}
+
+	return counts[0], nil
 }
@@ -0,0 +1,26 @@
+package taskpool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	cases := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, retryBaseDelay},
+		{1, retryBaseDelay},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{8, 1280 * time.Second},
+		{9, retryMaxDelay},
+		{1000, retryMaxDelay},
+	}
+	for _, c := range cases {
+		if got := retryDelay(c.attempts); got != c.want {
+			t.Fatalf("delay of attempt %d is %s, want %s", c.attempts, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/g8rswimmer/go-twitter/v2"
+	"github.com/project-miko/miko/conf"
+	"github.com/project-miko/miko/core"
+	"github.com/project-miko/miko/models"
+	"github.com/project-miko/miko/models/data"
+	"github.com/project-miko/miko/sdk/twitterapi"
+	"github.com/project-miko/miko/sdk/twitterapi/faketwitter"
+	"github.com/project-miko/miko/tools/logger"
+	"github.com/project-miko/miko/tools/mediautils"
+	"github.com/project-miko/miko/tools/netutils"
+)
+
+// initFakeTwitterTester start a fake twitter server, it needs no config, database or redis
+func initFakeTwitterTester(t *testing.T) *faketwitter.Server {
+	logger.InitLogger(t.TempDir(), "20060102")
+
+	server := faketwitter.NewServer()
+	restore := server.Use()
+	t.Cleanup(func() {
+		restore()
+		server.Close()
+	})
+
+	return server
+}
+
+var initCoreOnce sync.Once
+
+// initCoreTester start a fake twitter server for the tests of the core logic, they need the database and redis
+// of ../build/conf.ini, a database for the tests as the jobs in it are loaded, and are skipped without it
+func initCoreTester(t *testing.T) *faketwitter.Server {
+	if _, err := os.Stat("../build/conf.ini"); err != nil {
+		t.Skip("../build/conf.ini is not found")
+	}
+	initCoreOnce.Do(func() {
+		initTester()
+		core.InitScheduler()
+	})
+
+	return initFakeTwitterTester(t)
+}
+
+// newTestTwUserId an id of a twitter user not used by the former runs, the ids of the fake server start at 1.8e18
+func newTestTwUserId() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func TestFakeTwitterThread(t *testing.T) {
+	server := initFakeTwitterTester(t)
+
+	miko := server.AddUser(&faketwitter.User{Name: "Miko", UserName: "miko"}, "oauth2-token", "oauth1-token")
+	client := twitterapi.NewTwitterClient("miko", "oauth2-token", "oauth1-token", "oauth1-secret")
+
+	texts := []string{"thread 1/3", "thread 2/3", "thread 3/3"}
+	tweetIds := make([]string, 0)
+	for _, text := range texts {
+		req := &twitter.CreateTweetRequest{Text: text}
+		if len(tweetIds) > 0 {
+			req.Reply = &twitter.CreateTweetReply{InReplyToTweetID: tweetIds[len(tweetIds)-1]}
+		}
+		resp, err := client.CreateTweet(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.RateLimit == nil || resp.RateLimit.Remaining != 100-len(tweetIds)-1 {
+			t.Fatalf("unexpected user rate limit %+v", resp.RateLimit)
+		}
+		tweetIds = append(tweetIds, resp.Tweet.ID)
+	}
+
+	if _, err := client.CreateTweet(&twitter.CreateTweetRequest{Text: texts[0]}); err == nil {
+		t.Fatal("duplicate content is not rejected")
+	}
+
+	query := "conversation_id:" + tweetIds[0] + " from:miko"
+	raw, meta, err := client.SearchTweets(query, twitter.TweetRecentSearchOpts{
+		TweetFields: []twitter.TweetField{twitter.TweetFieldAuthorID, twitter.TweetFieldConversationID},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.ResultCount != len(texts) {
+		t.Fatalf("conversation has %d tweets, want %d", meta.ResultCount, len(texts))
+	}
+	for _, v := range raw.Tweets {
+		if v.AuthorID != miko.Id || v.ConversationID != tweetIds[0] || v.PublicMetrics != nil {
+			t.Fatalf("unexpected tweet %+v", v)
+		}
+	}
+
+	// the tweets only have the id and the text unless more fields are asked for
+	raw, _, err = client.SearchTweets(query, twitter.TweetRecentSearchOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := raw.Tweets[0]; v.AuthorID != "" || v.ConversationID != "" || v.Text == "" || raw.Includes != nil {
+		t.Fatalf("unexpected tweet without fields %+v", v)
+	}
+
+	lookup, err := client.TweetLookup(tweetIds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lookup.Raw.Tweets) != len(texts) || lookup.Raw.Tweets[0].PublicMetrics.Replies != 1 {
+		t.Fatalf("unexpected lookup %+v", lookup.Raw.Tweets)
+	}
+
+	// the thread is deleted the last tweet first, a tweet deleted already is reported not deleted
+	for i := len(tweetIds) - 1; i >= 0; i-- {
+		deleted, err := client.DeleteTweet(tweetIds[i])
+		if err != nil || !deleted {
+			t.Fatalf("delete tweet %s, deleted %v, err %v", tweetIds[i], deleted, err)
+		}
+		if server.GetTweet(tweetIds[i]) != nil {
+			t.Fatalf("tweet %s is not deleted", tweetIds[i])
+		}
+	}
+	if deleted, err := client.DeleteTweet(tweetIds[0]); err != nil || deleted {
+		t.Fatalf("delete a deleted tweet, deleted %v, err %v", deleted, err)
+	}
+}
+
+func TestFakeTwitterMediaProcessing(t *testing.T) {
+	server := initFakeTwitterTester(t)
+
+	server.AddUser(&faketwitter.User{Name: "Miko", UserName: "miko"}, "oauth2-token", "oauth1-token")
+	client := twitterapi.NewTwitterClient("miko", "oauth2-token", "oauth1-token", "oauth1-secret")
+
+	video := append([]byte{0, 0, 0, 0x18, 'f', 't', 'y', 'p', 'm', 'p', '4', '2'}, bytes.Repeat([]byte{1}, 6<<20)...)
+	mediaId, err := twitterapi.NewTwitterAPIV1("oauth1-token", "oauth1-secret").UploadMediaBinary("video/mp4", video)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = client.CreateTweet(&twitter.CreateTweetRequest{Media: &twitter.CreateTweetMedia{IDs: []string{mediaId}}}); err == nil {
+		t.Fatal("media still processing is accepted")
+	}
+
+	state := ""
+	for i := 0; i < server.MediaProcessingPolls; i++ {
+		status, err := client.GetMediaUploadStatus(mediaId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		state = status.ProcessingInfo.State
+	}
+	if state != twitterapi.UploadMediaSucceeded {
+		t.Fatalf("media state %s, want %s", state, twitterapi.UploadMediaSucceeded)
+	}
+
+	if _, err = client.CreateTweet(&twitter.CreateTweetRequest{Media: &twitter.CreateTweetMedia{IDs: []string{mediaId}}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFakeTwitterTweetMetrics(t *testing.T) {
+	server := initFakeTwitterTester(t)
+
+	miko := server.AddUser(&faketwitter.User{Name: "Miko", UserName: "miko"}, "oauth2-token", "oauth1-token")
+	other := server.AddUser(&faketwitter.User{Name: "Other", UserName: "other"})
+	client := twitterapi.NewTwitterClient("miko", "oauth2-token", "", "")
+
+	own := server.AddTweet(&faketwitter.Tweet{Text: "mine", AuthorId: miko.Id, Impressions: 120, Likes: 5, Bookmarks: 3})
+	theirs := server.AddTweet(&faketwitter.Tweet{Text: "theirs", AuthorId: other.Id, Impressions: 80, Quotes: 2})
+
+	lookup, err := client.TweetMetricsLookup([]string{own.Id, theirs.Id, "404"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lookup.Tweets) != 2 || len(lookup.Errors) != 1 || lookup.Errors[0].Value != "404" {
+		t.Fatalf("unexpected lookup %+v", lookup)
+	}
+	for _, v := range lookup.Tweets {
+		switch v.ID {
+		case own.Id:
+			// the non public metrics are only returned to the author
+			if v.NonPublicMetrics == nil || v.NonPublicMetrics.Impressions != 120 || v.PublicMetrics.Bookmarks != 3 {
+				t.Fatalf("unexpected metrics of own tweet %+v %+v", v.PublicMetrics, v.NonPublicMetrics)
+			}
+		case theirs.Id:
+			if v.NonPublicMetrics != nil || v.PublicMetrics.Quotes != 2 {
+				t.Fatalf("unexpected metrics of other tweet %+v %+v", v.PublicMetrics, v.NonPublicMetrics)
+			}
+		}
+	}
+}
+
+func TestFakeTwitterFollowers(t *testing.T) {
+	server := initFakeTwitterTester(t)
+
+	miko := server.AddUser(&faketwitter.User{Name: "Miko", UserName: "miko"}, "oauth2-token")
+	client := twitterapi.NewTwitterClient("miko", "oauth2-token", "", "")
+
+	const followerCount = 1200
+	for i := 0; i < followerCount; i++ {
+		fan := server.AddUser(&faketwitter.User{Name: "Fan", UserName: "fan" + strconv.Itoa(i)})
+		server.Follow(fan.Id, miko.Id)
+	}
+
+	pages := 0
+	followers, err := client.GetAllFollowerList(miko.Id, func() error {
+		pages++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(followers) != followerCount || pages != 2 {
+		t.Fatalf("%d followers in %d pages, want %d in 2", len(followers), pages, followerCount)
+	}
+	if followers[0].UserName == "" || followers[0].PublicMetrics == nil || followers[0].ProfileImageURL != "" {
+		t.Fatalf("unexpected follower %+v", followers[0])
+	}
+}
+
+func TestFakeTwitterFollowerSnapshot(t *testing.T) {
+	server := initCoreTester(t)
+	conf.TwitterAPIToken = "app-token"
+	interval, notable := conf.TwFollowerSnapshotInterval, conf.TwNotableFollowerCount
+	conf.TwFollowerSnapshotInterval, conf.TwNotableFollowerCount = 0, 2
+	t.Cleanup(func() {
+		conf.TwFollowerSnapshotInterval, conf.TwNotableFollowerCount = interval, notable
+	})
+
+	watched := server.AddUser(&faketwitter.User{Id: newTestTwUserId(), Name: "Watched", UserName: "watched"})
+	fans := make([]*faketwitter.User, 0)
+	for i := 0; i < 3; i++ {
+		fan := server.AddUser(&faketwitter.User{Name: "Fan", UserName: "fan" + strconv.Itoa(i)})
+		server.Follow(fan.Id, watched.Id)
+		fans = append(fans, fan)
+	}
+	// star has 2 followers, it is notable when it follows
+	star := server.AddUser(&faketwitter.User{Name: "Star", UserName: "star"})
+	server.Follow(fans[1].Id, star.Id)
+	server.Follow(fans[2].Id, star.Id)
+
+	if _, err := core.AddTwFollowerWatch("watched"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = core.RemoveTwFollowerWatch(watched.Id)
+	})
+
+	if err := core.SnapshotTwFollowers(); err != nil {
+		t.Fatal(err)
+	}
+	server.Unfollow(fans[0].Id, watched.Id)
+	server.Follow(star.Id, watched.Id)
+	if err := core.SnapshotTwFollowers(); err != nil {
+		t.Fatal(err)
+	}
+
+	watch, err := models.GetTwFollowerWatchByUserId(watched.Id)
+	if err != nil || watch == nil || watch.LastError != "" {
+		t.Fatalf("unexpected watch %+v, err %v", watch, err)
+	}
+	snapshot, err := models.GetLatestTwFollowerSnapshot(watched.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.FollowerCount != 3 || snapshot.FollowCount != 1 || snapshot.UnfollowCount != 1 {
+		t.Fatalf("unexpected snapshot %d followers, %d followed, %d unfollowed",
+			snapshot.FollowerCount, snapshot.FollowCount, snapshot.UnfollowCount)
+	}
+
+	// the first snapshot is the base, the changes are the ones between the two snapshots
+	_, changes, err := models.GetTwFollowerChangeList(watched.Id, 0, false, 0, 0, false, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatalf("%d changes, want 2", len(changes))
+	}
+	for _, v := range changes {
+		switch v.Type {
+		case models.TwFollowerChangeTypeFollow:
+			if v.FollowerId != star.Id || v.FollowerAccount != "star" || v.FollowerCount != 2 || !v.Notable {
+				t.Fatalf("unexpected follow %+v", v)
+			}
+		case models.TwFollowerChangeTypeUnfollow:
+			// the follower gone is looked up for its name
+			if v.FollowerId != fans[0].Id || v.FollowerAccount != "fan0" || v.Notable {
+				t.Fatalf("unexpected unfollow %+v", v)
+			}
+		}
+	}
+}
+
+func TestFakeTwitterPostQueueQuota(t *testing.T) {
+	initCoreTester(t)
+	limit := conf.TwUser24HourPostLimit
+	t.Cleanup(func() {
+		conf.TwUser24HourPostLimit = limit
+	})
+
+	userId := newTestTwUserId()
+	slotItem := func(at time.Time) *data.TwPostingSlotItem {
+		weekDay := int(at.Weekday())
+		if weekDay == 0 {
+			weekDay = 7
+		}
+		return &data.TwPostingSlotItem{WeekDay: weekDay, Hour: at.Hour(), Minute: at.Minute()}
+	}
+	now := time.Now().In(conf.NewTimeZone)
+	first, second := now.Add(2*time.Hour).Truncate(time.Minute), now.Add(3*time.Hour).Truncate(time.Minute)
+	if err := core.SetTwPostingSlots(userId, []*data.TwPostingSlotItem{slotItem(first), slotItem(second)}); err != nil {
+		t.Fatal(err)
+	}
+
+	// threads of 3, 1 and 1 tweets, the third one is slotted a week later
+	queueIds := make([]int64, 0)
+	for i, n := range []int{3, 1, 1} {
+		threadList := make([]*data.TwAddTweetScheduleReqItem, 0)
+		for j := 0; j < n; j++ {
+			threadList = append(threadList, &data.TwAddTweetScheduleReqItem{
+				SortId: strconv.Itoa(j + 1),
+				Text:   "queued " + strconv.Itoa(i) + " tweet " + strconv.Itoa(j),
+			})
+		}
+		item, err := core.AddTwPostQueueItem(userId, nil, threadList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		queueIds = append(queueIds, item.Id)
+	}
+	t.Cleanup(func() {
+		for _, id := range queueIds {
+			_ = core.RemoveTwPostQueueItem(userId, id)
+		}
+		_ = core.SetTwPostingSlots(userId, nil)
+	})
+
+	checkSlots := func(queueIds []int64) {
+		queue, err := core.GetTwPostQueue(userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []time.Time{first, second, first.AddDate(0, 0, 7)}
+		for i, v := range queue {
+			if v.Id != queueIds[i] || v.SlotAt != want[i].UnixMilli() {
+				t.Fatalf("item %d of the queue is %d at %d, want %d at %d", i, v.Id, v.SlotAt, queueIds[i], want[i].UnixMilli())
+			}
+		}
+	}
+	checkProjected := func(want int) {
+		quota, err := core.CheckTwPostQuota(userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quota.Projected24Hour != want || (want > conf.TwUser24HourPostLimit && quota.Feasible) {
+			t.Fatalf("%d posts projected, feasible %v, want %d against the cap %d", quota.Projected24Hour, quota.Feasible, want, conf.TwUser24HourPostLimit)
+		}
+	}
+
+	conf.TwUser24HourPostLimit = 3
+	checkSlots(queueIds)
+	checkProjected(4)
+
+	// the last item moves to the first slot, the thread of 3 tweets moves out of the 24 hours
+	reordered := []int64{queueIds[2], queueIds[1], queueIds[0]}
+	if err := core.ReorderTwPostQueue(userId, reordered); err != nil {
+		t.Fatal(err)
+	}
+	checkSlots(reordered)
+	checkProjected(2)
+}
+
+func TestFakeTwitterMediaCategory(t *testing.T) {
+	server := initFakeTwitterTester(t)
+
+	server.AddUser(&faketwitter.User{Name: "Miko", UserName: "miko"}, "oauth2-token", "oauth1-token")
+	api := twitterapi.NewTwitterAPIV1("oauth1-token", "oauth1-secret")
+
+	gif := append([]byte("GIF89a"), bytes.Repeat([]byte{1}, 1024)...)
+	if _, err := api.UploadMediaBinary("image/png", gif); err == nil {
+		t.Fatal("a gif declared as png is accepted")
+	}
+
+	mediaId, err := api.UploadMediaBinary("image/gif", gif)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = api.CreateMediaMetadata(mediaId, "a cat jumping"); err != nil {
+		t.Fatal(err)
+	}
+	if m := server.GetMedia(mediaId); m.Category != twitterapi.MediaCategoryGif || m.AltText != "a cat jumping" {
+		t.Fatalf("unexpected media %+v", m)
+	}
+
+	// the cap of a gif is applied, not the one of a video
+	bigGif := append([]byte("GIF89a"), bytes.Repeat([]byte{1}, twitterapi.MaxGifSize)...)
+	var sizeErr *mediautils.ErrMaxFileSizeExceeded
+	if _, err = mediautils.SaveToTempFile(bytes.NewReader(bigGif), "image/gif", twitterapi.GetMediaMaxSize); !errors.As(err, &sizeErr) || sizeErr.MaxSize != twitterapi.MaxGifSize {
+		t.Fatalf("a gif over the cap of gifs is not rejected, error %v", err)
+	}
+	f, err := mediautils.SaveToTempFile(bytes.NewReader(gif), "image/gif", twitterapi.GetMediaMaxSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Remove()
+	if f.MimeType != mediautils.MimeImageGif || f.Size != int64(len(gif)) {
+		t.Fatalf("unexpected file %+v", f)
+	}
+
+	// heic and avif share the container of mp4 but are not videos
+	for _, brand := range []string{"heic", "avif", "mif1"} {
+		if v := mediautils.SniffMediaType([]byte("\x00\x00\x00\x18ftyp" + brand)); v != "" {
+			t.Fatalf("the brand %s is sniffed as %s", brand, v)
+		}
+	}
+
+	// the fake server listens on the loopback
+	if _, err = mediautils.DownloadFileFromURL(server.URL+"/cat.gif", twitterapi.GetMediaMaxSize); !errors.Is(err, netutils.ErrNonPublicAddress) {
+		t.Fatalf("a private destination is not rejected, error %v", err)
+	}
+}
+
+func TestFakeTwitterThreadReconstruction(t *testing.T) {
+	server := initFakeTwitterTester(t)
+	conf.TwitterAPIToken = "app-token"
+
+	miko := server.AddUser(&faketwitter.User{Name: "Miko", UserName: "miko"})
+	fan := server.AddUser(&faketwitter.User{Name: "Fan", UserName: "fan"})
+
+	video := server.AddMedia(&faketwitter.Media{
+		Type: "video",
+		Url:  "https://pbs.example.com/video_thumb.jpg",
+		Variants: []*twitter.MediaVariantObj{
+			{ContentType: "application/x-mpegURL", URL: "https://video.example.com/video.m3u8"},
+			{ContentType: "video/mp4", BitRate: 832000, URL: "https://video.example.com/video_832.mp4"},
+			{ContentType: "video/mp4", BitRate: 2176000, URL: "https://video.example.com/video_2176.mp4"},
+		},
+	})
+
+	root := server.AddTweet(&faketwitter.Tweet{AuthorId: miko.Id, Text: "thread 1/3", MediaKeys: []string{video.Key}})
+	second := server.AddTweet(&faketwitter.Tweet{AuthorId: miko.Id, Text: "thread 2/3", InReplyToTweetId: root.Id})
+	reply := server.AddTweet(&faketwitter.Tweet{AuthorId: fan.Id, Text: "nice thread", InReplyToTweetId: second.Id})
+	server.AddTweet(&faketwitter.Tweet{AuthorId: miko.Id, Text: "thanks", InReplyToTweetId: reply.Id})
+	server.AddTweet(&faketwitter.Tweet{AuthorId: miko.Id, Text: "thread 3/3", InReplyToTweetId: second.Id})
+
+	thread, err := core.GetTwThreadWithTweetUrlByApi("https://x.com/miko/status/"+second.Id, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"thread 1/3", "thread 2/3", "thread 3/3"}
+	if len(thread.Tweets) != len(want) {
+		t.Fatalf("thread %v, want %v", thread.Tweets, want)
+	}
+	for i, v := range want {
+		if thread.Tweets[i] != v {
+			t.Fatalf("thread %v, want %v", thread.Tweets, want)
+		}
+	}
+	if thread.Account != "miko" || thread.ConversationId != root.Id {
+		t.Fatalf("unexpected thread %+v", thread)
+	}
+	if media := thread.Items[0].Media; len(media) != 1 || media[0].Url != "https://video.example.com/video_2176.mp4" {
+		t.Fatalf("unexpected media %+v", media)
+	}
+
+	if _, err = core.GetTwThreadWithTweetUrlByApi("https://example.com/miko/status/1", ""); err != core.ErrInvalidTweetUrl {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestFakeTwitterPostCap(t *testing.T) {
+	server := initFakeTwitterTester(t)
+	server.UserTweetLimit = 2
+
+	server.AddUser(&faketwitter.User{Name: "Capped", UserName: "capped"}, "capped-token")
+	client := twitterapi.NewTwitterClient("capped", "capped-token", "", "")
+
+	for i := 0; i < server.UserTweetLimit; i++ {
+		if d, err := twitterapi.Reserve("capped", twitterapi.EndpointTweetCreate); err != nil || d > 0 {
+			t.Fatalf("post %d is not available, wait %s, err %v", i, d, err)
+		}
+		if _, err := client.CreateTweet(&twitter.CreateTweetRequest{Text: "post " + strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the 15 minutes window of the endpoint has requests left, the 24 hours cap of the user has none
+	if d, err := twitterapi.Reserve("capped", twitterapi.EndpointTweetCreate); err != nil || d <= 0 {
+		t.Fatalf("post over the 24 hours cap is not held, wait %s, err %v", d, err)
+	}
+	if err := twitterapi.Wait("capped", twitterapi.EndpointTweetCreate, time.Minute); !errors.Is(err, twitterapi.ErrRateLimitWaitTooLong) {
+		t.Fatalf("wait over the 24 hours cap, err %v", err)
+	}
+	if d, err := twitterapi.Reserve("capped", twitterapi.EndpointTweetDelete); err != nil || d > 0 {
+		t.Fatalf("delete is held by the post cap, wait %s, err %v", d, err)
+	}
+}
+
+func TestFakeTwitterThreadResume(t *testing.T) {
+	server := initCoreTester(t)
+	undoWindow, staleTime := conf.TwPostUndoWindow, conf.TwThreadPostStaleTime
+	conf.TwPostUndoWindow = 0
+	t.Cleanup(func() {
+		conf.TwPostUndoWindow, conf.TwThreadPostStaleTime = undoWindow, staleTime
+	})
+
+	userId := newTestTwUserId()
+	server.AddUser(&faketwitter.User{Id: userId, Name: "Miko", UserName: "miko"}, "oauth2-"+userId, "oauth1-"+userId)
+	now := time.Now()
+	account := &models.TwAccount{UserId: userId, Name: "Miko", Account: "miko", AccessToken: "oauth2-" + userId,
+		ExpiredAt: now.Add(24 * time.Hour).UnixMilli(), CreatedAt: now.UnixMilli()}
+	oauth1 := &models.TwOAuth1{UserId: userId, Name: "Miko", Account: "miko", AccessToken: "oauth1-" + userId,
+		AccessSecret: "oauth1-secret", CreatedAt: now.UnixMilli()}
+	if err := account.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := oauth1.Save(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = account.Del()
+		_ = oauth1.Del()
+	})
+
+	checkPost := func(status, postedCount int) (*models.TwThreadPost, []*models.TwThreadPostItem) {
+		_, list, err := models.GetTwThreadPostList(userId, 0, 1, 1)
+		if err != nil || len(list) != 1 {
+			t.Fatalf("%d thread posts, err %v", len(list), err)
+		}
+		post := list[0]
+		if post.Status != status || post.PostedCount != postedCount {
+			t.Fatalf("thread post is %d with %d posted, want %d with %d posted", post.Status, post.PostedCount, status, postedCount)
+		}
+		items, err := models.GetTwThreadPostItemList(post.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return post, items
+	}
+
+	// tweet 3 of 5 fails, the first two are live
+	req := &data.CreateTweetReq{UserId: userId}
+	for i := 0; i < 5; i++ {
+		req.Tweets = append(req.Tweets, &data.CreateTweetItem{SortId: strconv.Itoa(i + 1), Text: "resumed " + strconv.Itoa(i)})
+	}
+	server.FailAt("POST /2/tweets", 3)
+	if _, err := core.CreateTweet(req); err == nil {
+		t.Fatal("the thread is posted with a failed tweet")
+	}
+	post, _ := checkPost(models.TwThreadPostStatusPartial, 2)
+
+	// a post taken by another worker is not resumed again, it is once it is stale
+	claimed, err := models.ClaimTwThreadPost(post.Id, []int{models.TwThreadPostStatusPartial}, 0, time.Now().UnixMilli())
+	if err != nil || !claimed {
+		t.Fatalf("thread post is not claimed, err %v", err)
+	}
+	if _, err = core.ResumeTwThreadPost(post.Id); !errors.Is(err, core.ErrTwThreadPostNotResumable) {
+		t.Fatalf("thread post being posted is resumed, err %v", err)
+	}
+	conf.TwThreadPostStaleTime = 0
+	time.Sleep(10 * time.Millisecond)
+
+	// the resume posts tweet 3 as a reply to tweet 2, tweet 4 fails then
+	server.FailAt("POST /2/tweets", 2)
+	if _, err = core.ResumeTwThreadPost(post.Id); err == nil {
+		t.Fatal("the thread is resumed with a failed tweet")
+	}
+	_, items := checkPost(models.TwThreadPostStatusPartial, 3)
+	tweet := server.GetTweet(items[2].TweetId)
+	if tweet == nil || tweet.InReplyToTweetId != items[1].TweetId {
+		t.Fatalf("tweet 3 %+v does not reply to tweet 2 %s", tweet, items[1].TweetId)
+	}
+
+	if _, err = core.RollbackTwThreadPost(post.Id); err != nil {
+		t.Fatal(err)
+	}
+	_, items = checkPost(models.TwThreadPostStatusRolledBack, 0)
+	for i, v := range items {
+		want := models.TwThreadPostItemStatusDeleted
+		if i >= 3 {
+			want = models.TwThreadPostItemStatusFailed
+			if i > 3 {
+				want = models.TwThreadPostItemStatusPending
+			}
+		}
+		if v.Status != want {
+			t.Fatalf("item %d is %d, want %d", i, v.Status, want)
+		}
+		if want == models.TwThreadPostItemStatusDeleted && server.GetTweet(v.TweetId) != nil {
+			t.Fatalf("tweet %d is not deleted", i)
+		}
+	}
+	// the thread deleted by its posted tweets is rolled back too
+	detail, err := core.CreateTweet(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = core.DeletePostedTweet(detail.Items[4].TweetId); err != nil {
+		t.Fatal(err)
+	}
+	checkPost(models.TwThreadPostStatusPosted, 4)
+	if _, err = core.DeletePostedThread(detail.Items[0].TweetId); err != nil {
+		t.Fatal(err)
+	}
+	_, items = checkPost(models.TwThreadPostStatusRolledBack, 0)
+	for i, v := range items {
+		if v.Status != models.TwThreadPostItemStatusDeleted || server.GetTweet(v.TweetId) != nil {
+			t.Fatalf("item %d is %d, its tweet is not deleted", i, v.Status)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultStopTimeout = 30 * time.Second
+)
+
+var (
+	hooks       = make([]*Hook, 0)
+	hooksLocker sync.Mutex
+	started     = make([]*Hook, 0)
+)
+
+// Hook the start and stop functions of a subsystem
+// hooks are started in ascending Order and stopped in the reverse order, only the started ones are stopped
+type Hook struct {
+	Name    string
+	Order   int
+	Start   func() error
+	Stop    func(ctx context.Context) error
+	Timeout time.Duration // max time to wait for Stop, 30s by default
+}
+
+func Register(h *Hook) {
+	hooksLocker.Lock()
+	defer hooksLocker.Unlock()
+	hooks = append(hooks, h)
+}
+
+// Start run the start functions of the hooks in order, it stops at the first error
+func Start() error {
+	hooksLocker.Lock()
+	defer hooksLocker.Unlock()
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Order < hooks[j].Order
+	})
+
+	for _, h := range hooks {
+		if h.Start != nil {
+			fmt.Printf("lifecycle: start %s\n", h.Name)
+			if err := h.Start(); err != nil {
+				return fmt.Errorf("start %s error %s", h.Name, err.Error())
+			}
+		}
+		started = append(started, h)
+	}
+
+	return nil
+}
+
+// Stop run the stop functions of the started hooks in reverse order, a hook not stopped in its timeout is left behind
+// output goes to stdout, the logger is one of the subsystems being stopped
+func Stop() {
+	hooksLocker.Lock()
+	defer hooksLocker.Unlock()
+
+	for i := len(started) - 1; i >= 0; i-- {
+		h := started[i]
+		if h.Stop == nil {
+			continue
+		}
+
+		timeout := h.Timeout
+		if timeout <= 0 {
+			timeout = defaultStopTimeout
+		}
+
+		fmt.Printf("lifecycle: stop %s\n", h.Name)
+		begin := time.Now()
+		if err := stopHook(h, timeout); err != nil {
+			fmt.Printf("lifecycle: stop %s error %s\n", h.Name, err.Error())
+			continue
+		}
+		fmt.Printf("lifecycle: %s stopped in %s\n", h.Name, time.Since(begin))
+	}
+
+	started = started[:0]
+}
+
+func stopHook(h *Hook, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			if x := recover(); x != nil {
+				errCh <- fmt.Errorf("panic %v", x)
+			}
+		}()
+		errCh <- h.Stop(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timeout after %s", timeout)
+	}
+}
@@ -21,6 +21,10 @@ type Logger struct {
 	basePath     string
 	members      *sync.Map // map[string]*IO
 	dateSplitFmt string
+
+	locker    sync.RWMutex   // guards the buffers being closed while messages are sent to them
+	destroyed bool           // messages are printed to stdout once the logger is destroyed
+	writers   sync.WaitGroup // the running writerAwaiter goroutines
 }
 
 var (
@@ -40,8 +44,15 @@ func InitLogger(basePath string, dateFmt string) {
 	}
 }
 
+// DestroyLogger close the buffers and wait for the messages in them to be written to the files
 func DestroyLogger() {
 	fmt.Println("dl called")
+	logger.locker.Lock()
+	if logger.destroyed {
+		logger.locker.Unlock()
+		return
+	}
+	logger.destroyed = true
 	logger.members.Range(func(key, value interface{}) bool {
 		prefix := key.(string)
 		fmt.Println("prefix ", prefix, "will be destroy")
@@ -49,9 +60,20 @@ func DestroyLogger() {
 		close(logio.buffer)
 		return true
 	})
+	logger.locker.Unlock()
+
+	logger.writers.Wait()
 }
 
 func WLog(prefix, msg string) (err error) {
+	logger.locker.RLock()
+	defer logger.locker.RUnlock()
+
+	if logger.destroyed {
+		fmt.Println(msg)
+		return nil
+	}
+
 	val, ok := logger.members.Load(prefix)
 	var io *IO
 	if !ok {
@@ -68,6 +90,7 @@ func WLog(prefix, msg string) (err error) {
 
 		logger.members.Store(prefix, io)
 
+		logger.writers.Add(1)
 		go writerAwaiter(io)
 
 	} else {
@@ -83,6 +106,8 @@ func writerAwaiter(io *IO) {
 	//@todo deal with panic
 
 	defer func() {
+		defer logger.writers.Done()
+
 		err := io.fp.Close()
 		if err != nil {
 			fmt.Println("Err: close file failed ", err)
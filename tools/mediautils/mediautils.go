@@ -1,43 +1,104 @@
 package mediautils
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
-	"strconv"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
-	"github.com/project-miko/miko/tools/byteutils"
+	"github.com/project-miko/miko/tools/netutils"
 )
 
 const (
-	defaultTimeOut = 60 // s
+	defaultTimeOut = 300 // s, large enough for a video
 
-	MaxFileSize = 20 * byteutils.MB
+	// the bytes read to sniff the media type
+	sniffLen = 512
+
+	MimeImageJpeg      = "image/jpeg"
+	MimeImagePng       = "image/png"
+	MimeImageGif       = "image/gif"
+	MimeImageWebp      = "image/webp"
+	MimeVideoMp4       = "video/mp4"
+	MimeVideoQuicktime = "video/quicktime"
+	MimeOctetStream    = "application/octet-stream"
 )
 
 var (
 	mimeToExt = map[string]string{
-		"image/jpeg":     ".jpg",
-		"image/png":      ".png",
-		"image/gif":      ".gif",
-		"image/svg+xml":  ".svg",
-		"image/bmp":      ".bmp",
-		"image/webp":     ".webp",
-		"image/tiff":     ".tiff",
-		"image/x-icon":   ".ico",
-		"image/jp2":      ".jp2",
-		"image/x-ms-bmp": ".bmp",
-		"video/mp4":      ".mp4",
+		"image/jpeg":      ".jpg",
+		"image/png":       ".png",
+		"image/gif":       ".gif",
+		"image/svg+xml":   ".svg",
+		"image/bmp":       ".bmp",
+		"image/webp":      ".webp",
+		"image/tiff":      ".tiff",
+		"image/x-icon":    ".ico",
+		"image/jp2":       ".jp2",
+		"image/x-ms-bmp":  ".bmp",
+		"video/mp4":       ".mp4",
+		"video/quicktime": ".mov",
+	}
+
+	// the declared types that are the same as the sniffed ones
+	mimeAliases = map[string]string{
+		"image/jpg":   MimeImageJpeg,
+		"image/pjpeg": MimeImageJpeg,
+		"video/x-m4v": MimeVideoMp4,
 	}
+
+	// the major brands of the iso base media files that are mp4 videos, heic and avif share the container but are images
+	mp4Brands = map[string]struct{}{
+		"isom": {}, "iso2": {}, "iso4": {}, "iso5": {}, "iso6": {},
+		"mp41": {}, "mp42": {}, "avc1": {}, "M4V ": {}, "M4VH": {}, "M4VP": {},
+		"dash": {}, "mmp4": {}, "MSNV": {},
+	}
+
+	ErrUnsupportedMediaType = fmt.Errorf("the media type is not supported")
 )
 
+// MaxSizeFunc the size limit of a sniffed media type, an error if the type is not accepted
+type MaxSizeFunc func(mimeType string) (int64, error)
+
 type ErrMaxFileSizeExceeded struct {
-	FileSize int
+	FileSize int64
+	MaxSize  int64
 }
 
 func (e *ErrMaxFileSizeExceeded) Error() string {
-	return fmt.Sprintf("the download file has exceeded the maximum allowed file size, file size: %d", e.FileSize)
+	return fmt.Sprintf("the download file has exceeded the maximum allowed file size, file size: %d, max size: %d", e.FileSize, e.MaxSize)
+}
+
+type ErrMediaTypeMismatch struct {
+	Declared string
+	Sniffed  string
+}
+
+func (e *ErrMediaTypeMismatch) Error() string {
+	return fmt.Sprintf("the content is %s but declared as %s", e.Sniffed, e.Declared)
+}
+
+// File is a media downloaded into a temp file, Remove it once it is used
+type File struct {
+	Path string
+	// the type sniffed from the magic bytes
+	MimeType string
+	// the type declared by the Content-Type of the response
+	DeclaredType string
+	Size         int64
+}
+
+func (f *File) Open() (*os.File, error) {
+	return os.Open(f.Path)
+}
+
+func (f *File) Remove() {
+	_ = os.Remove(f.Path)
 }
 
 func GetExtFromMIME(mime string) (string, bool) {
@@ -45,28 +106,123 @@ func GetExtFromMIME(mime string) (string, bool) {
 	return ext, ok
 }
 
-func DownloadFileFromURL(url string) (string, []byte, error) {
-	client := &http.Client{
-		Timeout: defaultTimeOut * time.Second,
+// SniffMediaType get the media type by the magic bytes, empty if it is not a supported image or video
+func SniffMediaType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return MimeImageJpeg
+	case bytes.HasPrefix(head, []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A}):
+		return MimeImagePng
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return MimeImageGif
+	case len(head) >= 12 && bytes.Equal(head[:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WEBP")):
+		return MimeImageWebp
+	case len(head) >= 12 && bytes.Equal(head[4:8], []byte("ftyp")):
+		// the major brand of the iso base media file
+		brand := string(head[8:12])
+		if brand == "qt  " {
+			return MimeVideoQuicktime
+		}
+		if _, ok := mp4Brands[brand]; ok {
+			return MimeVideoMp4
+		}
+	}
+	return ""
+}
+
+// CheckDeclaredType the declared type must agree with the sniffed one, an untyped response is trusted by its content,
+// the iso containers of video share their magic bytes so any video type is accepted for them
+func CheckDeclaredType(declared, sniffed string) error {
+	if sniffed == "" {
+		return fmt.Errorf("%w: %s", ErrUnsupportedMediaType, declared)
+	}
+
+	if t, _, err := mime.ParseMediaType(declared); err == nil {
+		declared = t
+	}
+	if alias, ok := mimeAliases[declared]; ok {
+		declared = alias
+	}
+
+	switch {
+	case declared == "", declared == MimeOctetStream, declared == sniffed:
+		return nil
+	case strings.HasPrefix(declared, "video/") && strings.HasPrefix(sniffed, "video/"):
+		return nil
+	}
+	return &ErrMediaTypeMismatch{Declared: declared, Sniffed: sniffed}
+}
+
+// SaveToTempFile stream the content into a temp file, the type is checked by the magic bytes before the content is read on,
+// it stops at the size limit of the type
+func SaveToTempFile(r io.Reader, declaredType string, maxSize MaxSizeFunc) (*File, error) {
+	return saveToTempFile(r, declaredType, -1, maxSize)
+}
+
+// saveToTempFile fail before the content is read on if the declared length is over the size limit, -1 if it is unknown
+func saveToTempFile(r io.Reader, declaredType string, contentLength int64, maxSize MaxSizeFunc) (*File, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
 	}
-	resp, err := client.Get(url)
+	head = head[:n]
+
+	f := &File{DeclaredType: declaredType, MimeType: SniffMediaType(head)}
+	if err = CheckDeclaredType(f.DeclaredType, f.MimeType); err != nil {
+		return nil, err
+	}
+	limit, err := maxSize(f.MimeType)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
-	defer resp.Body.Close()
+	if contentLength > limit {
+		return nil, &ErrMaxFileSizeExceeded{FileSize: contentLength, MaxSize: limit}
+	}
+
+	tmp, err := os.CreateTemp("", "media-*")
+	if err != nil {
+		return nil, err
+	}
+	f.Path = tmp.Name()
+
+	size, err := io.Copy(tmp, io.LimitReader(io.MultiReader(bytes.NewReader(head), r), limit+1))
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		f.Remove()
+		return nil, err
+	}
+	if size > limit {
+		f.Remove()
+		return nil, &ErrMaxFileSizeExceeded{FileSize: size, MaxSize: limit}
+	}
+	f.Size = size
 
-	_contentLength := resp.Header.Get("Content-Length")
-	contentLength, _ := strconv.Atoi(_contentLength)
-	if contentLength > MaxFileSize { // do not consider the case where the Content-Length response header is not read
-		return "", nil, &ErrMaxFileSizeExceeded{contentLength}
+	return f, nil
+}
+
+// DownloadFileFromURL download the file of a public http(s) url into a temp file, at most the size limit of its sniffed type
+func DownloadFileFromURL(fileUrl string, maxSize MaxSizeFunc) (*File, error) {
+	u, err := url.Parse(fileUrl)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, netutils.ErrUnsupportedScheme
 	}
 
-	// todo read in chunks, report an error if the limit is reached
-	data, err := io.ReadAll(resp.Body)
+	client := netutils.NewPublicHttpClient(defaultTimeOut * time.Second)
+	resp, err := client.Get(fileUrl)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
+	defer resp.Body.Close()
 
-	contentType := resp.Header.Get("Content-Type")
-	return contentType, data, nil
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download file responds %d", resp.StatusCode)
+	}
+	// the declared length fails early, the limit of the copy is the one that counts
+	return saveToTempFile(resp.Body, resp.Header.Get("Content-Type"), resp.ContentLength, maxSize)
 }
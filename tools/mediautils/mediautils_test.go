@@ -0,0 +1,66 @@
+package mediautils
+
+import (
+	"errors"
+	"testing"
+)
+
+func ftyp(brand string) []byte {
+	return append([]byte{0, 0, 0, 0x20, 'f', 't', 'y', 'p'}, brand...)
+}
+
+func TestSniffMediaType(t *testing.T) {
+	cases := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, MimeImageJpeg},
+		{"png", []byte{0x89, 'P', 'N', 'G', 0x0D, 0x0A, 0x1A, 0x0A, 0}, MimeImagePng},
+		{"gif87a", []byte("GIF87a..."), MimeImageGif},
+		{"gif89a", []byte("GIF89a..."), MimeImageGif},
+		{"webp", []byte("RIFF\x00\x00\x00\x00WEBPVP8 "), MimeImageWebp},
+		{"riff not webp", []byte("RIFF\x00\x00\x00\x00WAVEfmt "), ""},
+		{"mp4", ftyp("isom"), MimeVideoMp4},
+		{"m4v", ftyp("M4V "), MimeVideoMp4},
+		{"quicktime", ftyp("qt  "), MimeVideoQuicktime},
+		{"heic", ftyp("heic"), ""},
+		{"avif", ftyp("avif"), ""},
+		{"html", []byte("<!DOCTYPE html>"), ""},
+		{"empty", nil, ""},
+	}
+	for _, c := range cases {
+		if got := SniffMediaType(c.head); got != c.want {
+			t.Fatalf("%s is sniffed as %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCheckDeclaredType(t *testing.T) {
+	cases := []struct {
+		declared, sniffed string
+		mismatch          bool
+	}{
+		{"", MimeImagePng, false},
+		{MimeOctetStream, MimeImageJpeg, false},
+		{"image/jpeg", MimeImageJpeg, false},
+		{"image/jpg", MimeImageJpeg, false},
+		{"image/png; charset=binary", MimeImagePng, false},
+		{"video/x-m4v", MimeVideoMp4, false},
+		{"video/quicktime", MimeVideoMp4, false},
+		{"image/png", MimeImageJpeg, true},
+		{"text/html", MimeImageGif, true},
+		{"image/jpeg", MimeVideoMp4, true},
+	}
+	for _, c := range cases {
+		err := CheckDeclaredType(c.declared, c.sniffed)
+		var mismatch *ErrMediaTypeMismatch
+		if errors.As(err, &mismatch) != c.mismatch || (!c.mismatch && err != nil) {
+			t.Fatalf("declared %q sniffed %q: %v", c.declared, c.sniffed, err)
+		}
+	}
+
+	if err := CheckDeclaredType("image/png", ""); !errors.Is(err, ErrUnsupportedMediaType) {
+		t.Fatalf("an unsniffed type: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package netutils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+const maxRedirects = 5
+
+var (
+	ErrNonPublicAddress  = fmt.Errorf("the destination is not a public address")
+	ErrUnsupportedScheme = fmt.Errorf("only http and https are supported")
+
+	// the ranges not covered by the net.IP methods
+	nonPublicNets = mustParseCIDRs(
+		"0.0.0.0/8",       // this network
+		"100.64.0.0/10",   // carrier-grade nat
+		"192.0.0.0/24",    // ietf protocol assignments
+		"192.0.2.0/24",    // documentation
+		"198.18.0.0/15",   // benchmarking
+		"198.51.100.0/24", // documentation
+		"203.0.113.0/24",  // documentation
+		"240.0.0.0/4",     // reserved
+		"64:ff9b::/96",    // nat64, may map to a private ipv4
+		"2001:db8::/32",   // documentation
+	)
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, v := range cidrs {
+		_, n, err := net.ParseCIDR(v)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// IsPublicIP report whether the ip is routable on the internet, loopback, private, link-local and reserved ones are not
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, n := range nonPublicNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// publicOnlyControl reject the connection before it is made if the resolved address is not public,
+// it is checked on every dial so the redirects and the dns rebinding are covered as well
+func publicOnlyControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if !IsPublicIP(net.ParseIP(host)) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, host)
+	}
+	return nil
+}
+
+// NewPublicHttpClient create a client which connects to the public addresses only, used to fetch the urls given by users
+func NewPublicHttpClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   publicOnlyControl,
+	}
+
+	transport := &http.Transport{
+		// no proxy, the proxy would connect to the destination on behalf of the client
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedScheme
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,48 @@
+package netutils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	cases := []struct {
+		ip   string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"104.244.42.1", true},
+		{"2606:4700:4700::1111", true},
+		{"0.0.0.0", false},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"192.0.0.8", false},
+		{"192.0.2.1", false},
+		{"198.18.0.1", false},
+		{"198.51.100.1", false},
+		{"203.0.113.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"fc00::1", false},
+		{"fe80::1", false},
+		{"64:ff9b::a9fe:a9fe", false},
+		{"2001:db8::1", false},
+	}
+	for _, c := range cases {
+		if got := IsPublicIP(net.ParseIP(c.ip)); got != c.want {
+			t.Fatalf("%s is public: %v, want %v", c.ip, got, c.want)
+		}
+	}
+
+	if IsPublicIP(nil) {
+		t.Fatal("nil is public")
+	}
+}